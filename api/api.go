@@ -1,51 +1,145 @@
 package api
 
 import (
-	"log"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/VadimRight/GraphQLOzon/graph"
 	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/httpcache"
 	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/notify"
+	"github.com/VadimRight/GraphQLOzon/internal/persisted"
+	"github.com/VadimRight/GraphQLOzon/internal/querylimit"
+	"github.com/VadimRight/GraphQLOzon/internal/ratelimit"
 	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/internal/tracing"
 	"github.com/VadimRight/GraphQLOzon/internal/usecase"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
 	"github.com/VadimRight/GraphQLOzon/storage"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 // Функция инициализации сервера
 func InitServer(cfg *config.Config, storage storage.Storage) {
 	gin.SetMode(cfg.Server.RunMode)
-	r := gin.Default()
+	r := gin.New()
+	// Без явного списка gin доверяет X-Forwarded-For от любого клиента, и ограничение частоты
+	// по IP обходится подменой заголовка. Пустой список - IP берется из соединения
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	r.Use(gin.Recovery())
+	// Проверки живости и готовности регистрируются до трассировки и логирования запросов,
+	// чтобы частые опросы оркестратора не засоряли трейсы
+	r.GET("/healthz", livenessHandler)
+	r.GET("/readyz", readinessHandler(storage))
+	// Серверный HTTP-спан, родителем которого становится входящий заголовок traceparent
+	r.Use(otelgin.Middleware(cfg.Tracing.ServiceName))
+	// Идентификатор запроса и логгер запроса в контексте
+	r.Use(middleware.RequestID(slog.Default()))
+	r.Use(middleware.ClientIP())
 
 	// Инициализация сервисов и middleware
-	authService := service.NewAuthService()
-	authMiddleware := middleware.NewAuthMiddleware(authService)
+	authService := service.NewAuthService(cfg.Server.JWTSecret, cfg.Server.TokenTTL)
+	authMiddleware := middleware.NewAuthMiddleware(authService, storage.IsUserDisabled)
 	r.Use(authMiddleware.Handler())
 
-	r.POST("/graphql", graphqlHandler(storage, authService))
+	// GET поддерживается только для запросов: мутации по GET отклоняет транспорт gqlgen
+	gh := graphqlHandler(cfg, storage, authService)
+	r.POST("/graphql", gh)
+	r.GET("/graphql", gh)
 	r.GET("/", playgroundHandler())
 
-	log.Println("connect to http://localhost:8000/ for GraphQL playground")
-	log.Fatal(r.Run(":8000"))
+	slog.Info("connect to http://localhost:8000/ for GraphQL playground")
+	if err := r.Run(":8000"); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
 }
 
 // Хэндлер для непосредственно нашей схемы GraphQL
-func graphqlHandler(storage storage.Storage, authService service.AuthService) gin.HandlerFunc {
-	postUsecase := usecase.NewPostUsecase(storage)
-	commentUsecase := usecase.NewCommentUsecase(storage)
-	userUsecase := usecase.NewUserUsecase(storage, commentUsecase, service.NewPasswordService(), authService)
+func graphqlHandler(cfg *config.Config, storage storage.Storage, authService service.AuthService) gin.HandlerFunc {
+	validator := validation.NewValidator(cfg.Validation)
+	markdownUsecase := usecase.NewMarkdownUsecase(cfg.Markdown)
+	postUsecase := usecase.NewPostUsecase(storage, markdownUsecase, validator)
+	commentUsecase := usecase.NewCommentUsecase(storage, markdownUsecase, validator)
+	userUsecase := usecase.NewUserUsecase(storage, commentUsecase, service.NewPasswordService(), authService, validator)
+	searchUsecase := usecase.NewSearchUsecase(storage)
+	reactionUsecase := usecase.NewReactionUsecase(storage)
+	followUsecase := usecase.NewFollowUsecase(storage)
+	notificationUsecase := usecase.NewNotificationUsecase(storage, notify.NewBroker())
+	mentionUsecase := usecase.NewMentionUsecase(storage)
 
-	h := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{
+	schema := graph.NewExecutableSchema(graph.Config{
 		Resolvers: &graph.Resolver{
-			UserUsecase:    userUsecase,
-			PostUsecase:    postUsecase,
-			CommentUsecase: commentUsecase,
+			UserUsecase:         userUsecase,
+			PostUsecase:         postUsecase,
+			CommentUsecase:      commentUsecase,
+			SearchUsecase:       searchUsecase,
+			ReactionUsecase:     reactionUsecase,
+			FollowUsecase:       followUsecase,
+			NotificationUsecase: notificationUsecase,
+			MentionUsecase:      mentionUsecase,
+			MarkdownUsecase:     markdownUsecase,
 		},
-	}))
+	})
+	// Стоимость полей для анализа сложности: списки умножают стоимость вложенной выборки на limit
+	h := handler.New(querylimit.WithCosts(schema, cfg.Query))
+	// Браузер не может передать заголовок Authorization при открытии websocket, поэтому токен
+	// для подписок также принимается в payload сообщения connection_init
+	h.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              middleware.NewAuthMiddleware(authService, storage.IsUserDisabled).WebsocketInit,
+	})
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.GET{})
+	h.AddTransport(transport.POST{})
+	h.AddTransport(transport.MultipartForm{})
+	h.SetQueryCache(lru.New(1000))
+	h.SetErrorPresenter(graph.ErrorPresenter)
+	h.Use(extension.Introspection{})
+	usePersistedQueries(h, cfg.Persisted)
+	h.Use(tracing.Extension{})
+	h.Use(querylimit.NewLimit(cfg.Query))
+	h.Use(ratelimit.NewExtension(ratelimit.NewMemoryStore(), cfg.RateLimit))
+	h.Use(validation.Extension{})
+	h.Use(middleware.AccessLog{})
+	// Cache-Control по подсказкам @cacheControl, ETag считается уже по готовому ответу
+	h.Use(&httpcache.Extension{})
+	cached := httpcache.ETag(h)
 	return func(c *gin.Context) {
-		h.ServeHTTP(c.Writer, c.Request)
+		c.Request = c.Request.WithContext(httpcache.WithResponseHeader(c.Request.Context(), c.Writer.Header()))
+		cached.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Подключение Automatic Persisted Queries и, если задан каталог одобренных операций, списка разрешенных запросов
+func usePersistedQueries(h *handler.Server, cfg *config.PersistedQueriesConfig) {
+	cache := persisted.NewCache(cfg.CacheSize)
+	if cfg.AllowListDir == "" {
+		h.Use(extension.AutomaticPersistedQuery{Cache: cache})
+		return
+	}
+
+	allowList, err := persisted.LoadAllowList(cfg.AllowListDir)
+	if err != nil {
+		slog.Error("failed to load persisted queries", "dir", cfg.AllowListDir, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("persisted queries loaded", "dir", cfg.AllowListDir, "count", allowList.Len(), "allow_list_only", cfg.AllowListOnly)
+
+	h.Use(extension.AutomaticPersistedQuery{Cache: allowList.Cache(cache, cfg.AllowListOnly)})
+	if cfg.AllowListOnly {
+		h.Use(persisted.Enforcer{List: allowList})
 	}
 }
 
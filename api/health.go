@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Время, за которое хранилище должно ответить на проверку готовности
+const readinessTimeout = 2 * time.Second
+
+// livenessHandler отвечает, пока процесс жив. База данных не проверяется: при ее недоступности
+// перезапуск приложения не поможет
+func livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readinessHandler отвечает 503, пока хранилище недоступно, чтобы балансировщик
+// не направлял запросы в экземпляр, который не может их обслужить
+func readinessHandler(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		if err := storage.Ping(ctx, store); err != nil {
+			logger.FromContext(ctx).Warn("storage is not ready", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHTTPCacheServer собирает эндпоинт /graphql так же, как InitServer, поверх хранилища в памяти
+func newHTTPCacheServer(t *testing.T) http.Handler {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := config.NewLoader(fs)
+	require.NoError(t, fs.Parse([]string{"-env-file", filepath.Join(t.TempDir(), ".env"), "-server.jwt_secret", "secret", "-storage.type", "memory"}))
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	author, err := store.UserCreate(ctx, "author", "hash")
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-1", "Test post", author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	gh := graphqlHandler(cfg, store, service.NewAuthService(cfg.Server.JWTSecret, cfg.Server.TokenTTL))
+	r.GET("/graphql", gh)
+	r.POST("/graphql", gh)
+	return r
+}
+
+func getGraphQL(h http.Handler, query string, header http.Header, claim *service.JwtCustomClaim) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if claim != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthKey, claim))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGETQueryIsCacheable(t *testing.T) {
+	h := newHTTPCacheServer(t)
+
+	rec := getGraphQL(h, `{ posts { id text authorPost { username } } }`, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Test post")
+	// posts: 30, Post: 60, User: 60 - берется минимум
+	assert.Equal(t, "max-age=30, public", rec.Header().Get("Cache-Control"))
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	notModified := getGraphQL(h, `{ posts { id text authorPost { username } } }`, http.Header{"If-None-Match": {etag}}, nil)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+	assert.Equal(t, etag, notModified.Header().Get("ETag"))
+}
+
+func TestGETQueryCachePolicyFromHints(t *testing.T) {
+	h := newHTTPCacheServer(t)
+
+	private := getGraphQL(h, `{ users { id password } }`, nil, nil)
+	require.Equal(t, http.StatusOK, private.Code)
+	assert.Equal(t, "no-store", private.Header().Get("Cache-Control"))
+
+	authenticated := getGraphQL(h, `{ posts { id } }`, nil, &service.JwtCustomClaim{ID: "1"})
+	assert.Equal(t, "max-age=30, private", authenticated.Header().Get("Cache-Control"))
+}
+
+func TestGETRejectsMutations(t *testing.T) {
+	h := newHTTPCacheServer(t)
+
+	rec := getGraphQL(h, `mutation { loginUser(username: "u", password: "p") { token } }`, nil, nil)
+
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
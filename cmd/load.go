@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/loadgen"
+)
+
+var loadCommand = &command{
+	name:       "load",
+	summary:    "replay a mix of GraphQL queries and mutations against a running server and report latency percentiles",
+	standalone: true,
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		opts := loadgen.Options{Mix: loadgen.DefaultMix}
+		fs.StringVar(&opts.URL, "url", "http://localhost:8000/graphql", "GraphQL endpoint")
+		fs.DurationVar(&opts.Duration, "duration", 30*time.Second, "how long to run")
+		fs.IntVar(&opts.Concurrency, "concurrency", 8, "number of concurrent clients")
+		fs.IntVar(&opts.Users, "users", 10, "number of seeded users to log in as, 0 runs anonymous queries only; mutations need the server's rate limits off or raised (RATE_LIMIT_ENABLED, RATE_LIMITS)")
+		fs.StringVar(&opts.Password, "password", "password", "password of the seeded users")
+		fs.Uint64Var(&opts.Seed, "seed", 1, "random seed of the request sequence")
+		fs.Func("mix", "operation weights as name=weight,... (default posts=40,postsPage=20,post=25,createPost=5,createComment=7,react=3)", func(s string) error {
+			mix, err := loadgen.ParseMix(s)
+			opts.Mix = mix
+			return err
+		})
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			client := &http.Client{Timeout: 30 * time.Second}
+			report, err := loadgen.Run(ctx, client, opts)
+			if err != nil {
+				return err
+			}
+			return report.Write(os.Stdout)
+		}
+	},
+}
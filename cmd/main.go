@@ -1,18 +1,166 @@
 package main
 
 import (
-	"github.com/VadimRight/GraphQLOzon/api"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
 	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
 	"github.com/VadimRight/GraphQLOzon/storage"
 )
 
+// command - подкоманда CLI. setup регистрирует флаги команды и возвращает функцию,
+// которая выполняет команду с загруженной конфигурацией и оставшимися аргументами
+type command struct {
+	name    string
+	args    string
+	summary string
+	// standalone - команда не работает с хранилищем и не загружает конфигурацию, cfg равен nil
+	standalone bool
+	setup      func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = []*command{
+	serveCommand,
+	migrateCommand,
+	seedCommand,
+	userCreateCommand,
+	userDisableCommand,
+	userEnableCommand,
+	userSetRoleCommand,
+	userSetPasswordCommand,
+	tokenIssueCommand,
+	exportCommand,
+	importCommand,
+	loadCommand,
+	configPrintCommand,
+}
+
+// configPrintCommand выполняется в main, потому что печатает конфигурацию и при ошибках загрузки
+var configPrintCommand = &command{
+	name:    "config print",
+	summary: "print the effective configuration with the source of every value",
+}
+
 func main() {
-	cfg := config.LoadConfig()
-	storageType := storage.StorageType(cfg)
-	defer func() {
-		if postgresStorage, ok := storageType.(*storage.PostgresStorage); ok {
-			postgresStorage.ClosePostgres()
+	if len(os.Args) == 2 && os.Args[1] == "help" {
+		usage()
+		return
+	}
+	cmd, args, ok := lookup(os.Args[1:])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", strings.Join(os.Args[1:], " "))
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	var loader *config.Loader
+	if !cmd.standalone {
+		loader = config.NewLoader(fs)
+	}
+	var run func(ctx context.Context, cfg *config.Config, args []string) error
+	if cmd.setup != nil {
+		run = cmd.setup(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", os.Args[0], cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cmd.standalone {
+		if err := run(ctx, nil, fs.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+	cfg, err := loader.Load()
+
+	if cmd == configPrintCommand {
+		// Конфигурация выводится и при ошибках, чтобы было видно, откуда взялось неверное значение
+		if err := loader.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-	}()
-	api.InitServer(cfg, storageType)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cmd != serveCommand {
+		// Сервер пишет логи в stdout, а служебные команды - в stderr, чтобы не смешивать их с выводом
+		slog.SetDefault(logger.New(os.Stderr, cfg.Log))
+	}
+
+	if err := run(ctx, cfg, fs.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// lookup находит команду по первым аргументам. Без команды запускается сервер,
+// поэтому прежний запуск с одними флагами продолжает работать
+func lookup(args []string) (*command, []string, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serveCommand, args, true
+	}
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return nil, nil, false
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags] [args]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command. Without a command the server is started.\n", os.Args[0])
+}
+
+// openStorage открывает хранилище из конфигурации. Изменения в памяти пропадают вместе
+// с процессом, поэтому для изменяющих команд выводится предупреждение
+func openStorage(cfg *config.Config, modifies bool) (storage.Storage, func()) {
+	if modifies && cfg.Storage.StorageType == "memory" {
+		slog.Warn("storage.type is memory, changes will be lost when the command exits")
+	}
+	store := storage.StorageType(cfg)
+	return store, func() { closeStorage(store) }
+}
+
+// closeStorage закрывает соединения с Postgres, если хранилище с ним работает
+func closeStorage(store storage.Storage) {
+	if cachedStorage, ok := store.(*storage.CachedStorage); ok {
+		store = cachedStorage.Unwrap()
+	}
+	if postgresStorage, ok := store.(*storage.PostgresStorage); ok {
+		postgresStorage.ClosePostgres()
+	}
+}
+
+// requireArgs проверяет число позиционных аргументов команды
+func requireArgs(args []string, names ...string) error {
+	if len(args) != len(names) {
+		return fmt.Errorf("expected arguments: %s", strings.Join(names, " "))
+	}
+	return nil
 }
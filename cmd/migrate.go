@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+var migrateCommand = &command{
+	name:    "migrate",
+	summary: "apply pending Postgres migrations and print the schema version",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			if cfg.Storage.StorageType != "postgres" {
+				return errors.New("migrations apply only to storage.type=postgres")
+			}
+			// Миграции применяются при открытии хранилища
+			cfg.Cache.Enabled = false
+			store, closeStore := openStorage(cfg, false)
+			defer closeStore()
+			version, err := storage.SchemaVersion(ctx, store.(*storage.PostgresStorage).DB)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("schema version %d\n", version)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/seed"
+)
+
+var seedCommand = &command{
+	name:    "seed",
+	summary: "fill the storage with deterministic fake users, posts and comment threads",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		var opts seed.Options
+		fs.Uint64Var(&opts.Seed, "seed", 1, "random seed, the same seed produces the same data")
+		fs.IntVar(&opts.Users, "users", 100, "number of users")
+		fs.IntVar(&opts.Posts, "posts", 500, "total number of posts")
+		fs.Float64Var(&opts.PostsExponent, "posts-exponent", 1.1, "power-law exponent of posts per author, 0 spreads posts evenly")
+		fs.IntVar(&opts.ThreadsPerPost, "threads-per-post", 3, "number of top-level comments of every post")
+		fs.IntVar(&opts.ThreadDepth, "thread-depth", 3, "max depth of replies")
+		fs.IntVar(&opts.FanOut, "fan-out", 2, "max number of replies to a comment")
+		fs.StringVar(&opts.Password, "password", "password", "password of every generated user")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			stats, err := seed.Run(ctx, store, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("created %d users, %d posts, %d comments\n", stats.Users, stats.Posts, stats.Comments)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/VadimRight/GraphQLOzon/api"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/VadimRight/GraphQLOzon/internal/tracing"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "start the GraphQL server (default command)",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			serve(cfg)
+			return nil
+		}
+	},
+}
+
+// serve запускает GraphQL сервер
+func serve(cfg *config.Config) {
+	slog.SetDefault(logger.New(os.Stdout, cfg.Log))
+	slog.Info("config loaded", "config", cfg)
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("failed to init tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	storageType := storage.StorageType(cfg)
+	defer closeStorage(storageType)
+	api.InitServer(cfg, storageType)
+}
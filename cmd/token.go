@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+)
+
+var tokenIssueCommand = &command{
+	name:    "token issue",
+	args:    "<username|id>",
+	summary: "issue a JWT for a user without their password, for debugging",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		ttl := fs.Duration("ttl", 0, "lifetime of the token (default server.token_ttl)")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args, "<username|id>"); err != nil {
+				return err
+			}
+			store, closeStore := openStorage(cfg, false)
+			defer closeStore()
+			user, err := findUser(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if user.Disabled {
+				slog.Warn("user is disabled, the server will reject the token", "user", user.Username)
+			}
+			if *ttl == 0 {
+				*ttl = cfg.Server.TokenTTL
+			}
+			token, err := service.NewAuthService(cfg.Server.JWTSecret, *ttl).GenerateToken(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			fmt.Println(token)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/transfer"
+)
+
+var exportCommand = &command{
+	name:    "export",
+	summary: "write users, posts and comments as versioned JSON Lines",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		output := fs.String("o", "-", "output file, - for stdout")
+		var opts transfer.ExportOptions
+		fs.BoolVar(&opts.Passwords, "passwords", false, "include password hashes, keep such dumps as secret as the database")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			store, closeStore := openStorage(cfg, false)
+			defer closeStore()
+
+			var w io.Writer = os.Stdout
+			if *output != "-" {
+				f, err := os.Create(*output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			buffered := bufio.NewWriter(w)
+			stats, err := transfer.Export(ctx, store, buffered, opts)
+			if err != nil {
+				return err
+			}
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			slog.Info("export finished", "users", stats.Users, "posts", stats.Posts, "comments", stats.Comments)
+			return nil
+		}
+	},
+}
+
+var importCommand = &command{
+	name:    "import",
+	summary: "load JSON Lines written by export, objects with existing IDs are skipped",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		input := fs.String("i", "-", "input file, - for stdin")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args); err != nil {
+				return err
+			}
+			var r io.Reader = os.Stdin
+			if *input != "-" {
+				f, err := os.Open(*input)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			stats, err := transfer.Import(ctx, store, r)
+			slog.Info("import finished", "users", stats.Users, "posts", stats.Posts, "comments", stats.Comments, "skipped", stats.Skipped)
+			return err
+		}
+	},
+}
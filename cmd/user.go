@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/google/uuid"
+)
+
+var userCreateCommand = &command{
+	name:    "user create",
+	args:    "<username>",
+	summary: "create a user; the password is read from the first line of stdin unless -password is set",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		password := fs.String("password", "", "password of the user, prefer stdin to keep it out of the shell history")
+		role := fs.String("role", string(model.UserRoleUser), "role of the user: USER or ADMIN")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args, "<username>"); err != nil {
+				return err
+			}
+			userRole := model.UserRole(strings.ToUpper(*role))
+			if !userRole.IsValid() {
+				return fmt.Errorf("unknown role %q", *role)
+			}
+			var errs validation.Errors
+			username := validation.NewValidator(cfg.Validation).Username(&errs, "username", args[0])
+			if err := errs.Err(); err != nil {
+				return err
+			}
+			hashedPassword, err := hashPassword(*password)
+			if err != nil {
+				return err
+			}
+
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			var user *model.User
+			err = store.WithTx(ctx, func(tx storage.Storage) error {
+				var err error
+				if user, err = tx.UserCreate(ctx, username, hashedPassword); err != nil {
+					return err
+				}
+				if userRole != model.UserRoleUser {
+					user, err = tx.SetUserRole(ctx, user.ID, userRole)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(user.ID)
+			return nil
+		}
+	},
+}
+
+var userSetPasswordCommand = &command{
+	name:    "user set-password",
+	args:    "<username|id>",
+	summary: "set the password of a user, e.g. one imported without password hashes; the password is read from the first line of stdin unless -password is set",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		password := fs.String("password", "", "new password, prefer stdin to keep it out of the shell history")
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args, "<username|id>"); err != nil {
+				return err
+			}
+			hashedPassword, err := hashPassword(*password)
+			if err != nil {
+				return err
+			}
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			user, err := findUser(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := store.SetUserPassword(ctx, user.ID, hashedPassword); err != nil {
+				return err
+			}
+			fmt.Printf("user %s (%s) password updated\n", user.Username, user.ID)
+			return nil
+		}
+	},
+}
+
+// hashPassword хэширует пароль из флага, а если он пуст - из первой строки stdin
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return service.NewPasswordService().HashPassword(password)
+}
+
+var userDisableCommand = &command{
+	name:    "user disable",
+	args:    "<username|id>",
+	summary: "disable a user: they can no longer log in and their tokens are rejected",
+	setup:   setUserDisabled(true),
+}
+
+var userEnableCommand = &command{
+	name:    "user enable",
+	args:    "<username|id>",
+	summary: "enable a previously disabled user",
+	setup:   setUserDisabled(false),
+}
+
+func setUserDisabled(disabled bool) func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+	return func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args, "<username|id>"); err != nil {
+				return err
+			}
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			user, err := findUser(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := store.SetUserDisabled(ctx, user.ID, disabled); err != nil {
+				return err
+			}
+			fmt.Printf("user %s (%s) disabled=%t\n", user.Username, user.ID, disabled)
+			return nil
+		}
+	}
+}
+
+var userSetRoleCommand = &command{
+	name:    "user set-role",
+	args:    "<username|id> <USER|ADMIN>",
+	summary: "change the role of a user",
+	setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string) error {
+		return func(ctx context.Context, cfg *config.Config, args []string) error {
+			if err := requireArgs(args, "<username|id>", "<USER|ADMIN>"); err != nil {
+				return err
+			}
+			role := model.UserRole(strings.ToUpper(args[1]))
+			if !role.IsValid() {
+				return fmt.Errorf("unknown role %q", args[1])
+			}
+			store, closeStore := openStorage(cfg, true)
+			defer closeStore()
+			user, err := findUser(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := store.SetUserRole(ctx, user.ID, role); err != nil {
+				return err
+			}
+			fmt.Printf("user %s (%s) role=%s\n", user.Username, user.ID, role)
+			return nil
+		}
+	},
+}
+
+// findUser ищет пользователя по имени, а если ссылка похожа на UUID и имя не найдено - по ID
+func findUser(ctx context.Context, store storage.Storage, ref string) (*model.User, error) {
+	user, err := store.GetUserByUsername(ctx, validation.NormalizeUsername(ref))
+	if err == nil {
+		return user, nil
+	}
+	if _, parseErr := uuid.Parse(ref); parseErr == nil {
+		if user, err := store.GetUserByID(ctx, ref); err == nil {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user %q not found", ref)
+}
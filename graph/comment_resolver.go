@@ -4,13 +4,14 @@ import (
 	"context"
 	"errors"
 
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
 	"github.com/VadimRight/GraphQLOzon/internal/middleware"
 	"github.com/VadimRight/GraphQLOzon/model"
 )
 
 // Метод получения всех комментариев
-func (r *queryResolver) Comments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error) {
-	comments, err := r.CommentUsecase.GetAllComments(ctx, limit, offset)
+func (r *queryResolver) Comments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error) {
+	comments, err := r.CommentUsecase.GetAllComments(ctx, filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +24,7 @@ func (r *queryResolver) Comments(ctx context.Context, limit, offset *int) ([]*mo
 		}
 
 		// Получаем ответы для каждого комментария
-		comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+		comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -54,7 +55,7 @@ func (r *queryResolver) Comment(ctx context.Context, id string, limit, offset *i
 	}
 
 	// Получаем ответы для комментария с поддержкой пагинации
-	comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+	comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -71,14 +72,56 @@ func (r *queryResolver) Comment(ctx context.Context, id string, limit, offset *i
 }
 
 // Метод создания комментария
-func (r *mutationResolver) CreateComment(ctx context.Context, commentText string, itemId string) (*model.CommentResponse, error) {
+func (r *mutationResolver) CreateComment(ctx context.Context, commentText string, itemId string, format model.TextFormat) (*model.CommentResponse, error) {
 	user := middleware.CtxValue(ctx)
 	if user == nil {
 		return nil, errors.New("unauthorized")
 	}
-	comment, err := r.CommentUsecase.CreateComment(ctx, commentText, itemId, user.ID)
+	comment, err := r.CommentUsecase.CreateComment(ctx, commentText, itemId, user.ID, format)
 	if err != nil {
 		return nil, err
 	}
+	// Комментарий уже создан, поэтому ошибка рассылки уведомлений не возвращается клиенту
+	if err := r.NotificationUsecase.CommentCreated(ctx, comment); err != nil {
+		logger.FromContext(ctx).Error("failed to create notifications", "comment_id", comment.ID, "error", err)
+	}
 	return comment, nil
 }
+
+// Ответы на комментарий. Без аргументов возвращаются ответы, загруженные вместе с комментарием,
+// иначе они запрашиваются заново в нужном порядке и с нужной пагинацией
+func (r *commentResponseResolver) Replies(ctx context.Context, obj *model.CommentResponse, sort *model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	if sort == nil && limit == nil && offset == nil && obj.Replies != nil {
+		return obj.Replies, nil
+	}
+	replies, err := r.CommentUsecase.GetCommentsByParentID(ctx, obj.ID, commentSort(sort), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return r.withAuthors(ctx, replies)
+}
+
+// withAuthors возвращает копии комментариев с заполненными авторами. Ответы в копиях не заполнены -
+// их загрузит резольвер replies, если они запрошены
+func (r *Resolver) withAuthors(ctx context.Context, comments []*model.CommentResponse) ([]*model.CommentResponse, error) {
+	result := make([]*model.CommentResponse, 0, len(comments))
+	for _, comment := range comments {
+		author, err := r.UserUsecase.GetUserByID(ctx, comment.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+		c := *comment
+		c.AuthorComment = author
+		c.Replies = nil
+		result = append(result, &c)
+	}
+	return result, nil
+}
+
+// commentSort возвращает порядок комментариев из аргумента запроса, по умолчанию - от старых к новым
+func commentSort(sort *model.CommentSort) model.CommentSort {
+	if sort == nil {
+		return model.CommentSortOld
+	}
+	return *sort
+}
@@ -23,11 +23,11 @@ func TestComments(t *testing.T) {
 		{ID: "1", Comment: "Test comment", AuthorID: "1"},
 	}
 
-	mockCommentUsecase.On("GetAllComments", ctx, &limit, &offset).Return(expectedComments, nil)
+	mockCommentUsecase.On("GetAllComments", ctx, (*model.CommentFilter)(nil), &limit, &offset).Return(expectedComments, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(&model.User{ID: "1", Username: "user1"}, nil)
-	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", &limit, &offset).Return([]*model.CommentResponse{}, nil)
+	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", model.CommentSortOld, &limit, &offset).Return([]*model.CommentResponse{}, nil)
 
-	comments, err := resolver.Comments(ctx, &limit, &offset)
+	comments, err := resolver.Comments(ctx, nil, &limit, &offset)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedComments, comments)
@@ -49,7 +49,7 @@ func TestComment(t *testing.T) {
 
 	mockCommentUsecase.On("GetCommentByID", ctx, id).Return(expectedComment, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(&model.User{ID: "1", Username: "user1"}, nil)
-	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", &limit, &offset).Return([]*model.CommentResponse{}, nil)
+	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", model.CommentSortOld, &limit, &offset).Return([]*model.CommentResponse{}, nil)
 
 	comment, err := resolver.Comment(ctx, id, &limit, &offset)
 
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentSort(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	var users []string
+	for _, name := range []string{"u1", "u2", "u3"} {
+		user, err := store.UserCreate(ctx, name, "hash")
+		require.NoError(t, err)
+		users = append(users, user.ID)
+	}
+	_, err := store.CreatePost(ctx, "post-1", "Post", users[0], true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	// Комментарии создаются по порядку: c1 самый старый, c4 самый новый
+	names := map[string]string{}
+	var ids []string
+	for _, text := range []string{"c1", "c2", "c3", "c4"} {
+		comment, err := store.CreateComment(ctx, text, "post-1", users[0], model.TextFormatPlain)
+		require.NoError(t, err)
+		names[comment.ID] = text
+		ids = append(ids, comment.ID)
+	}
+	react := func(commentID string, likes, dislikes int) {
+		for i := 0; i < likes; i++ {
+			require.NoError(t, store.React(ctx, commentID, users[i], model.ReactionKindLike))
+		}
+		for i := 0; i < dislikes; i++ {
+			require.NoError(t, store.React(ctx, commentID, users[len(users)-1-i], model.ReactionKindDislike))
+		}
+	}
+	react(ids[0], 1, 0) // рейтинг 1, не спорный
+	react(ids[1], 3, 2) // рейтинг 1, спорность 5^(2/3)
+	react(ids[2], 2, 0) // рейтинг 2, не спорный
+	react(ids[3], 1, 1) // рейтинг 0, спорность 2
+	reply, err := store.CreateComment(ctx, "r1", ids[0], users[1], model.TextFormatPlain)
+	require.NoError(t, err)
+	names[reply.ID] = "r1"
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	order := func(args string) []string {
+		t.Helper()
+		resp := doAsUser(t, h, "", `{ post(id: "post-1") { comments(`+args+`) { id authorComment { username } } } }`)
+		require.Empty(t, resp.Errors)
+		var data struct {
+			Post struct {
+				Comments []struct {
+					ID string `json:"id"`
+				} `json:"comments"`
+			} `json:"post"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+		var result []string
+		for _, comment := range data.Post.Comments {
+			// Ответы тоже относятся к посту, но в проверках порядка нас интересуют только c1..c4
+			if names[comment.ID] != "r1" {
+				result = append(result, names[comment.ID])
+			}
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"c1", "c2", "c3", "c4"}, order("sort: OLD"))
+	assert.Equal(t, []string{"c4", "c3", "c2", "c1"}, order("sort: NEW"))
+	// При равном рейтинге c1 и c2 первым идет более новый комментарий
+	assert.Equal(t, []string{"c3", "c2", "c1", "c4"}, order("sort: TOP"))
+	assert.Equal(t, []string{"c2", "c4", "c3", "c1"}, order("sort: CONTROVERSIAL"))
+	assert.Equal(t, []string{"c2", "c1"}, order("sort: TOP, limit: 2, offset: 1"))
+
+	resp := doAsUser(t, h, "", `{ comment(id: "`+ids[0]+`") { replies(sort: NEW) { comment authorComment { username } } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"comment":{"replies":[{"comment":"r1","authorComment":{"username":"u2"}}]}}`, string(resp.Data))
+}
@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePost(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	resp := doAsUser(t, h, alice.ID, `mutation { createPost(text: "Hi @bob, about #cats", commentable: true) { id } }`)
+	require.Empty(t, resp.Errors)
+	var created struct {
+		CreatePost struct {
+			ID string `json:"id"`
+		} `json:"createPost"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	postID := created.CreatePost.ID
+
+	resp = doAsUser(t, h, bob.ID, `mutation { createComment(comment: "Thanks @alice", itemId: "`+postID+`") { id } }`)
+	require.Empty(t, resp.Errors)
+	resp = doAsUser(t, h, bob.ID, `mutation { react(itemId: "`+postID+`", kind: LIKE) { itemId } }`)
+	require.Empty(t, resp.Errors)
+
+	resp = doAsUser(t, h, alice.ID, `{ unreadNotificationCount }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"unreadNotificationCount":1}`, string(resp.Data))
+
+	resp = doAsUser(t, h, bob.ID, `mutation { deletePost(id: "`+postID+`") }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "only the author can delete the post", resp.Errors[0].Message)
+
+	resp = doAsUser(t, h, alice.ID, `mutation { deletePost(id: "`+postID+`") }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"deletePost":true}`, string(resp.Data))
+
+	// Вместе с постом удалены комментарии, теги, упоминания и уведомления о них
+	resp = doAsUser(t, h, "", `{ posts { id } comments { id } postsByTag(tag: "cats") { edges { cursor } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"posts":[],"comments":[],"postsByTag":{"edges":[]}}`, string(resp.Data))
+	resp = doAsUser(t, h, alice.ID, `{ unreadNotificationCount }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"unreadNotificationCount":0}`, string(resp.Data))
+	resp = doAsUser(t, h, bob.ID, `{ unreadNotificationCount }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"unreadNotificationCount":0}`, string(resp.Data))
+
+	resp = doAsUser(t, h, alice.ID, `mutation { deletePost(id: "`+postID+`") }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "post not found", resp.Errors[0].Message)
+}
@@ -0,0 +1,35 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок, возвращаемые в extensions.code
+const (
+	ErrCodeConflict    = "CONFLICT"
+	ErrCodeUnavailable = "UNAVAILABLE"
+)
+
+// ErrorPresenter дополняет ошибки резольверов кодом в extensions.code, по которому клиент
+// отличает известные ошибки, не разбирая текст сообщения
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	switch {
+	case errors.Is(err, storage.ErrUsernameTaken):
+		errcode.Set(gqlErr, ErrCodeConflict)
+		gqlErr.Extensions["field"] = "username"
+	case storage.IsUnavailable(err):
+		// Текст ошибки драйвера клиенту не нужен, он остается в логе
+		logger.FromContext(ctx).Warn("storage is unavailable", "path", gqlErr.Path.String(), "error", err)
+		gqlErr.Message = "service temporarily unavailable"
+		errcode.Set(gqlErr, ErrCodeUnavailable)
+	}
+	return gqlErr
+}
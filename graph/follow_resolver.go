@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Метод подписки текущего пользователя на другого пользователя
+func (r *mutationResolver) Follow(ctx context.Context, userID string) (*model.User, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.FollowUsecase.Follow(ctx, user.ID, userID)
+}
+
+// Метод отмены подписки текущего пользователя
+func (r *mutationResolver) Unfollow(ctx context.Context, userID string) (*model.User, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.FollowUsecase.Unfollow(ctx, user.ID, userID)
+}
+
+// Лента текущего пользователя - посты тех, на кого он подписан, от новых к старым
+func (r *queryResolver) Feed(ctx context.Context, first *int, after *string) (*model.PostConnection, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	connection, err := r.FollowUsecase.GetFeed(ctx, user.ID, first, after)
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range connection.Edges {
+		edge.Node.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, edge.Node.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return connection, nil
+}
+
+// Подписчики пользователя
+func (r *userResolver) Followers(ctx context.Context, obj *model.User, first *int, after *string) (*model.UserConnection, error) {
+	return r.FollowUsecase.GetFollowers(ctx, obj.ID, first, after)
+}
+
+// Пользователи, на которых подписан пользователь
+func (r *userResolver) Following(ctx context.Context, obj *model.User, first *int, after *string) (*model.UserConnection, error) {
+	return r.FollowUsecase.GetFollowing(ctx, obj.ID, first, after)
+}
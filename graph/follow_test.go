@@ -0,0 +1,121 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowAndFeed(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	carol, err := store.UserCreate(ctx, "carol", "hash")
+	require.NoError(t, err)
+	for _, post := range []struct{ id, author string }{
+		{"p1", bob.ID}, {"p2", carol.ID}, {"p3", alice.ID}, {"p4", carol.ID},
+	} {
+		_, err := store.CreatePost(ctx, post.id, "Post "+post.id, post.author, true, model.TextFormatPlain)
+		require.NoError(t, err)
+	}
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	// Повторная подписка ничего не меняет
+	for _, userID := range []string{bob.ID, carol.ID, carol.ID} {
+		resp := doAsUser(t, h, alice.ID, `mutation { follow(userId: "`+userID+`") { id } }`)
+		require.Empty(t, resp.Errors)
+	}
+	resp := doAsUser(t, h, bob.ID, `mutation { follow(userId: "`+carol.ID+`") { username } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"follow":{"username":"carol"}}`, string(resp.Data))
+
+	type feedPage struct {
+		Feed struct {
+			Edges []struct {
+				Node struct {
+					ID         string `json:"id"`
+					AuthorPost struct {
+						Username string `json:"username"`
+					} `json:"authorPost"`
+				} `json:"node"`
+			} `json:"edges"`
+			PageInfo struct {
+				EndCursor   *string `json:"endCursor"`
+				HasNextPage bool    `json:"hasNextPage"`
+			} `json:"pageInfo"`
+		} `json:"feed"`
+	}
+	feed := func(args string) feedPage {
+		t.Helper()
+		resp := doAsUser(t, h, alice.ID, `{ feed(`+args+`) { edges { node { id authorPost { username } } } pageInfo { endCursor hasNextPage } } }`)
+		require.Empty(t, resp.Errors)
+		var page feedPage
+		require.NoError(t, json.Unmarshal(resp.Data, &page))
+		return page
+	}
+	ids := func(page feedPage) []string {
+		var result []string
+		for _, edge := range page.Feed.Edges {
+			result = append(result, edge.Node.ID)
+		}
+		return result
+	}
+
+	// Собственные посты в ленту не попадают, новые посты идут первыми
+	first := feed("first: 2")
+	assert.Equal(t, []string{"p4", "p2"}, ids(first))
+	assert.Equal(t, "carol", first.Feed.Edges[0].Node.AuthorPost.Username)
+	require.True(t, first.Feed.PageInfo.HasNextPage)
+	// Новый пост не сдвигает уже выданные страницы
+	_, err = store.CreatePost(ctx, "p5", "Post p5", bob.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	second := feed(`first: 2, after: "` + *first.Feed.PageInfo.EndCursor + `"`)
+	assert.Equal(t, []string{"p1"}, ids(second))
+	assert.False(t, second.Feed.PageInfo.HasNextPage)
+
+	resp = doAsUser(t, h, alice.ID, `{ user(id: "`+carol.ID+`") { followers { edges { node { username } } } following { edges { node { username } } } } }`)
+	require.Empty(t, resp.Errors)
+	var user struct {
+		User struct {
+			Followers struct {
+				Edges []struct {
+					Node struct {
+						Username string `json:"username"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"followers"`
+			Following struct {
+				Edges []json.RawMessage `json:"edges"`
+			} `json:"following"`
+		} `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &user))
+	var followers []string
+	for _, edge := range user.User.Followers.Edges {
+		followers = append(followers, edge.Node.Username)
+	}
+	assert.ElementsMatch(t, []string{"alice", "bob"}, followers)
+	assert.Empty(t, user.User.Following.Edges)
+
+	resp = doAsUser(t, h, alice.ID, `mutation { unfollow(userId: "`+carol.ID+`") { id } }`)
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, []string{"p5", "p1"}, ids(feed("first: 10")))
+
+	resp = doAsUser(t, h, alice.ID, `mutation { follow(userId: "`+alice.ID+`") { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "cannot follow yourself", resp.Errors[0].Message)
+
+	resp = doAsUser(t, h, "", `{ feed { edges { cursor } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "unauthorized", resp.Errors[0].Message)
+}
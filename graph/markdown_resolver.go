@@ -0,0 +1,17 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// HTML поста, отрисованный из исходного текста в его формате
+func (r *postResolver) HTML(ctx context.Context, obj *model.Post) (string, error) {
+	return r.MarkdownUsecase.RenderHTML(ctx, obj.Format, obj.Text)
+}
+
+// HTML комментария, отрисованный из исходного текста в его формате
+func (r *commentResponseResolver) HTML(ctx context.Context, obj *model.CommentResponse) (string, error) {
+	return r.MarkdownUsecase.RenderHTML(ctx, obj.Format, obj.Comment)
+}
@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/usecase"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	resp := doAsUser(t, h, alice.ID, `mutation { createPost(text: "# Title\n\n**bold** [link](https://example.com) <script>alert(1)</script>", commentable: true, format: MARKDOWN) { id text format html } }`)
+	require.Empty(t, resp.Errors)
+	var data struct {
+		CreatePost struct {
+			ID     string `json:"id"`
+			Text   string `json:"text"`
+			Format string `json:"format"`
+			HTML   string `json:"html"`
+		} `json:"createPost"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	post := data.CreatePost
+	// Исходный текст хранится как есть, а HTML отрисовывается и очищается
+	assert.Contains(t, post.Text, "<script>")
+	assert.Equal(t, "MARKDOWN", post.Format)
+	assert.Equal(t, "<h3>Title</h3>\n<p><strong>bold</strong> <a href=\"https://example.com\" rel=\"nofollow\">link</a> alert(1)</p>\n", post.HTML)
+
+	// Комментарии по умолчанию - обычный текст, HTML в нем экранируется
+	resp = doAsUser(t, h, alice.ID, `mutation { createComment(comment: "**not bold** <b>", itemId: "`+post.ID+`") { format html } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"createComment":{"format":"PLAIN","html":"<p>**not bold** &lt;b&gt;</p>\n"}}`, string(resp.Data))
+
+	// Смена формата при редактировании меняет и HTML
+	resp = doAsUser(t, h, alice.ID, `mutation { updatePost(id: "`+post.ID+`", format: PLAIN) { format html } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"updatePost":{"format":"PLAIN","html":"<p># Title</p>\n<p>**bold** [link](https://example.com) &lt;script&gt;alert(1)&lt;/script&gt;</p>\n"}}`, string(resp.Data))
+
+	// Слишком глубокая вложенность отклоняется до сохранения
+	deep := strings.Repeat("> ", 50) + "deep"
+	resp = doAsUser(t, h, alice.ID, `mutation { createComment(comment: "`+deep+`", itemId: "`+post.ID+`", format: MARKDOWN) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "markdown nesting is too deep", resp.Errors[0].Message)
+	comments, err := store.GetAllComments(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+}
+
+func TestMarkdownUsecaseWithoutCacheSize(t *testing.T) {
+	var markdownUsecase usecase.MarkdownUsecase
+	require.NotPanics(t, func() {
+		markdownUsecase = usecase.NewMarkdownUsecase(&config.MarkdownConfig{MaxNesting: 20, MaxTableCells: 1000})
+	})
+	html, err := markdownUsecase.RenderHTML(context.Background(), model.TextFormatMarkdown, "**bold**")
+	require.NoError(t, err)
+	assert.Contains(t, html, "<strong>bold</strong>")
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Упоминания пользователей в тексте поста
+func (r *postResolver) Mentions(ctx context.Context, obj *model.Post) ([]*model.Mention, error) {
+	return r.MentionUsecase.GetMentions(ctx, obj.ID)
+}
+
+// Упоминания пользователей в тексте комментария
+func (r *commentResponseResolver) Mentions(ctx context.Context, obj *model.CommentResponse) ([]*model.Mention, error) {
+	return r.MentionUsecase.GetMentions(ctx, obj.ID)
+}
+
+// Упомянутый пользователь. Для удаленного пользователя возвращается null
+func (r *mentionResolver) User(ctx context.Context, obj *model.Mention) (*model.User, error) {
+	if obj.UserID == nil {
+		return nil, nil
+	}
+	return r.UserUsecase.GetUserByID(ctx, *obj.UserID)
+}
+
+// Посты и комментарии, в которых упомянут пользователь
+func (r *userResolver) MentionedIn(ctx context.Context, obj *model.User, first *int, after *string) (*model.MentionConnection, error) {
+	connection, err := r.MentionUsecase.GetMentionedIn(ctx, obj.ID, first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edge := range connection.Edges {
+		// Заполняем автора поста или комментария
+		switch node := edge.Node.(type) {
+		case *model.Post:
+			node.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, node.AuthorID)
+		case *model.CommentResponse:
+			node.AuthorComment, err = r.UserUsecase.GetUserByID(ctx, node.AuthorID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return connection, nil
+}
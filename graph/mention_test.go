@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMentions(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	_, err = store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	// Упоминание несуществующего пользователя остается обычным текстом
+	resp := doAsUser(t, h, alice.ID, `mutation { createPost(text: "Привет, @bob и @ghost", commentable: true) { id mentions { offset length user { username } } } }`)
+	require.Empty(t, resp.Errors)
+	var created struct {
+		CreatePost struct {
+			ID       string          `json:"id"`
+			Mentions json.RawMessage `json:"mentions"`
+		} `json:"createPost"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.JSONEq(t, `[{"offset":8,"length":4,"user":{"username":"bob"}}]`, string(created.CreatePost.Mentions))
+
+	resp = doAsUser(t, h, alice.ID, `mutation { createComment(comment: "@bob, @alice и снова @bob", itemId: "`+created.CreatePost.ID+`") { mentions { offset length user { username } } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"createComment":{"mentions":[
+		{"offset":0,"length":4,"user":{"username":"bob"}},
+		{"offset":6,"length":6,"user":{"username":"alice"}},
+		{"offset":21,"length":4,"user":{"username":"bob"}}
+	]}}`, string(resp.Data))
+
+	const query = `{ userByUsername(username: "bob") { mentionedIn(first: 1%s) {
+		edges { cursor node { __typename ... on Post { text authorPost { username } } ... on CommentResponse { comment authorComment { username } } } }
+		pageInfo { endCursor hasNextPage } } } }`
+	var page struct {
+		UserByUsername struct {
+			MentionedIn struct {
+				Edges []struct {
+					Node json.RawMessage `json:"node"`
+				} `json:"edges"`
+				PageInfo struct {
+					EndCursor   string `json:"endCursor"`
+					HasNextPage bool   `json:"hasNextPage"`
+				} `json:"pageInfo"`
+			} `json:"mentionedIn"`
+		} `json:"userByUsername"`
+	}
+	resp = doAsUser(t, h, "", fmt.Sprintf(query, ""))
+	require.Empty(t, resp.Errors)
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	require.Len(t, page.UserByUsername.MentionedIn.Edges, 1)
+	assert.JSONEq(t, `{"__typename":"CommentResponse","comment":"@bob, @alice и снова @bob","authorComment":{"username":"alice"}}`,
+		string(page.UserByUsername.MentionedIn.Edges[0].Node))
+	require.True(t, page.UserByUsername.MentionedIn.PageInfo.HasNextPage)
+
+	resp = doAsUser(t, h, "", fmt.Sprintf(query, `, after: "`+page.UserByUsername.MentionedIn.PageInfo.EndCursor+`"`))
+	require.Empty(t, resp.Errors)
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	require.Len(t, page.UserByUsername.MentionedIn.Edges, 1)
+	assert.JSONEq(t, `{"__typename":"Post","text":"Привет, @bob и @ghost","authorPost":{"username":"alice"}}`,
+		string(page.UserByUsername.MentionedIn.Edges[0].Node))
+	assert.False(t, page.UserByUsername.MentionedIn.PageInfo.HasNextPage)
+}
+
+func TestMentionOfDeletedUserIsNull(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	post, err := store.CreatePost(ctx, "post-1", "@gone was here", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	// Упоминание пользователя, которого больше нет в хранилище
+	gone := "deleted-user"
+	require.NoError(t, store.SaveMentions(ctx, post.ID, []*model.Mention{{UserID: &gone, Offset: 0, Length: 5}}))
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	resp := doAsUser(t, h, "", `{ post(id: "post-1") { mentions { offset length user { username } } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"post":{"mentions":[{"offset":0,"length":5,"user":null}]}}`, string(resp.Data))
+}
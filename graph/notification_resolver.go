@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Уведомления текущего пользователя от новых к старым
+func (r *queryResolver) Notifications(ctx context.Context, first *int, after *string, unreadOnly *bool) (*model.NotificationConnection, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.NotificationUsecase.GetNotifications(ctx, user.ID, first, after, unreadOnly != nil && *unreadOnly)
+}
+
+// Число непрочитанных уведомлений текущего пользователя
+func (r *queryResolver) UnreadNotificationCount(ctx context.Context) (int, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return 0, errors.New("unauthorized")
+	}
+	return r.NotificationUsecase.CountUnread(ctx, user.ID)
+}
+
+// Метод отметки уведомлений прочитанными
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return 0, errors.New("unauthorized")
+	}
+	return r.NotificationUsecase.MarkRead(ctx, user.ID, ids)
+}
+
+// Подписка на новые уведомления текущего пользователя. Канал закрывается, когда клиент отписывается
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.NotificationUsecase.Subscribe(ctx, user.ID), nil
+}
+
+// Автор действия, вызвавшего уведомление
+func (r *notificationResolver) Actor(ctx context.Context, obj *model.Notification) (*model.User, error) {
+	return r.UserUsecase.GetUserByID(ctx, obj.ActorID)
+}
+
+// Пост, к которому относится уведомление
+func (r *notificationResolver) Post(ctx context.Context, obj *model.Notification) (*model.Post, error) {
+	post, err := r.PostUsecase.GetPostByID(ctx, obj.PostID)
+	if err != nil {
+		return nil, err
+	}
+	p := *post
+	p.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, post.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	p.Comments = nil
+	return &p, nil
+}
+
+// Комментарий, из-за которого пришло уведомление
+func (r *notificationResolver) Comment(ctx context.Context, obj *model.Notification) (*model.CommentResponse, error) {
+	if obj.CommentID == nil {
+		return nil, nil
+	}
+	comment, err := r.CommentUsecase.GetCommentByID(ctx, *obj.CommentID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := r.withAuthors(ctx, []*model.CommentResponse{comment})
+	if err != nil {
+		return nil, err
+	}
+	return comments[0], nil
+}
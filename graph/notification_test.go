@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	carol, err := store.UserCreate(ctx, "carol", "hash")
+	require.NoError(t, err)
+
+	resolver := newInMemoryResolver(store)
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: resolver}))
+
+	subCtx, cancel := context.WithCancel(context.WithValue(ctx, middleware.AuthKey, &service.JwtCustomClaim{ID: carol.ID}))
+	added, err := (&subscriptionResolver{resolver}).NotificationAdded(subCtx)
+	require.NoError(t, err)
+
+	var created struct {
+		CreatePost struct {
+			ID string `json:"id"`
+		} `json:"createPost"`
+		CreateComment struct {
+			ID string `json:"id"`
+		} `json:"createComment"`
+	}
+	resp := doAsUser(t, h, alice.ID, `mutation { createPost(text: "Hello", commentable: true) { id } }`)
+	require.Empty(t, resp.Errors)
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	// Алиса упомянута в комментарии к своему посту, но получает одно уведомление - о комментарии.
+	// Адрес почты не считается упоминанием
+	resp = doAsUser(t, h, bob.ID, `mutation { createComment(comment: "Hi @alice and @carol, mail me at bob@carol.dev", itemId: "`+created.CreatePost.ID+`") { id } }`)
+	require.Empty(t, resp.Errors)
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	resp = doAsUser(t, h, alice.ID, `mutation { createComment(comment: "Thanks, @alice!", itemId: "`+created.CreateComment.ID+`") { id } }`)
+	require.Empty(t, resp.Errors)
+
+	select {
+	case notification := <-added:
+		assert.Equal(t, model.NotificationKindMention, notification.Kind)
+		assert.Equal(t, bob.ID, notification.ActorID)
+	case <-time.After(time.Second):
+		t.Fatal("notification was not delivered to the subscriber")
+	}
+	cancel()
+	_, open := <-added
+	assert.False(t, open)
+
+	const query = `{ notifications { edges { node { kind read actor { username } post { text } comment { comment } } } } unreadNotificationCount }`
+	resp = doAsUser(t, h, alice.ID, query)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"notifications":{"edges":[
+		{"node":{"kind":"COMMENT","read":false,"actor":{"username":"bob"},"post":{"text":"Hello"},"comment":{"comment":"Hi @alice and @carol, mail me at bob@carol.dev"}}}
+	]},"unreadNotificationCount":1}`, string(resp.Data))
+
+	resp = doAsUser(t, h, bob.ID, `{ notifications { edges { node { kind actor { username } comment { comment } } } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"notifications":{"edges":[
+		{"node":{"kind":"REPLY","actor":{"username":"alice"},"comment":{"comment":"Thanks, @alice!"}}}
+	]}}`, string(resp.Data))
+
+	// Неизвестные и некорректные ID пропускаются без ошибки
+	resp = doAsUser(t, h, carol.ID, `mutation { markNotificationsRead(ids: ["not-a-uuid", "00000000-0000-4000-8000-000000000000"]) }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"markNotificationsRead":0}`, string(resp.Data))
+	resp = doAsUser(t, h, carol.ID, `mutation { markNotificationsRead }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"markNotificationsRead":1}`, string(resp.Data))
+	resp = doAsUser(t, h, carol.ID, `{ all: notifications { edges { node { read } } } unread: notifications(unreadOnly: true) { edges { cursor } } unreadNotificationCount }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"all":{"edges":[{"node":{"read":true}}]},"unread":{"edges":[]},"unreadNotificationCount":0}`, string(resp.Data))
+
+	resp = doAsUser(t, h, "", `{ unreadNotificationCount }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "unauthorized", resp.Errors[0].Message)
+}
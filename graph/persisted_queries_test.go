@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/VadimRight/GraphQLOzon/internal/persisted"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const approvedPostsQuery = "query ListPosts {\n  posts {\n    id\n  }\n}\n"
+
+func newAllowListServer(t *testing.T) *handler.Server {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "list_posts.graphql"), []byte(approvedPostsQuery), 0o600))
+	allowList, err := persisted.LoadAllowList(dir)
+	require.NoError(t, err)
+
+	h := handler.New(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(storage.NewInMemoryStorage())}))
+	h.AddTransport(transport.POST{})
+	h.Use(extension.AutomaticPersistedQuery{Cache: allowList.Cache(persisted.NewCache(10), true)})
+	h.Use(persisted.Enforcer{List: allowList})
+	return h
+}
+
+func postGraphQL(t *testing.T, h http.Handler, body map[string]interface{}) limitsResponse {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(raw)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp limitsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestAllowListAcceptsApprovedHashWithoutQuery(t *testing.T) {
+	h := newAllowListServer(t)
+
+	resp := postGraphQL(t, h, map[string]interface{}{
+		"extensions": map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": persisted.Hash(approvedPostsQuery)},
+		},
+	})
+
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"posts":[]}`, string(resp.Data))
+}
+
+func TestAllowListAcceptsApprovedQueryWithDifferentFormatting(t *testing.T) {
+	h := newAllowListServer(t)
+
+	resp := postGraphQL(t, h, map[string]interface{}{"query": "query ListPosts { posts { id } }"})
+
+	assert.Empty(t, resp.Errors)
+}
+
+func TestAllowListRejectsUnknownQuery(t *testing.T) {
+	h := newAllowListServer(t)
+	query := "{ users { id password } }"
+
+	resp := postGraphQL(t, h, map[string]interface{}{"query": query})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, persisted.ErrOperationNotAllowed, resp.Errors[0].Extensions["code"])
+
+	// Регистрация через APQ тоже не позволяет выполнить запрос позже по хэшу
+	resp = postGraphQL(t, h, map[string]interface{}{
+		"query": query,
+		"extensions": map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": persisted.Hash(query)},
+		},
+	})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, persisted.ErrOperationNotAllowed, resp.Errors[0].Extensions["code"])
+
+	resp = postGraphQL(t, h, map[string]interface{}{
+		"extensions": map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": persisted.Hash(query)},
+		},
+	})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PERSISTED_QUERY_NOT_FOUND", resp.Errors[0].Extensions["code"])
+}
@@ -4,14 +4,15 @@ import (
 	"context"
 	"errors"
 
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
 	"github.com/VadimRight/GraphQLOzon/internal/middleware"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/google/uuid"
 )
 
 // Метод получения всех постов
-func (r *queryResolver) Posts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	posts, err := r.PostUsecase.GetAllPosts(ctx, limit, offset)
+func (r *queryResolver) Posts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	posts, err := r.PostUsecase.GetAllPosts(ctx, filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +25,7 @@ func (r *queryResolver) Posts(ctx context.Context, limit, offset *int) ([]*model
 		}
 
 		// Получаем комментарии для поста
-		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -37,7 +38,7 @@ func (r *queryResolver) Posts(ctx context.Context, limit, offset *int) ([]*model
 			}
 
 			// Получаем ответы для каждого комментария
-			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 			if err != nil {
 				return nil, err
 			}
@@ -70,7 +71,7 @@ func (r *queryResolver) PostsByUserID(ctx context.Context, userID string, limit,
 		}
 
 		// Получаем комментарии для поста
-		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -83,7 +84,7 @@ func (r *queryResolver) PostsByUserID(ctx context.Context, userID string, limit,
 			}
 
 			// Получаем ответы для каждого комментария
-			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 			if err != nil {
 				return nil, err
 			}
@@ -115,7 +116,7 @@ func (r *queryResolver) Post(ctx context.Context, id string, limit, offset *int)
 	}
 
 	// Получаем комментарии для поста
-	post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+	post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +129,7 @@ func (r *queryResolver) Post(ctx context.Context, id string, limit, offset *int)
 		}
 
 		// Получаем ответы для каждого комментария
-		comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+		comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -146,15 +147,61 @@ func (r *queryResolver) Post(ctx context.Context, id string, limit, offset *int)
 }
 
 // Метод создания поста
-func (r *mutationResolver) CreatePost(ctx context.Context, text string, permissionToComment bool) (*model.Post, error) {
+func (r *mutationResolver) CreatePost(ctx context.Context, text string, permissionToComment bool, tags []string, format model.TextFormat) (*model.Post, error) {
 	user := middleware.CtxValue(ctx)
 	if user == nil {
 		return nil, errors.New("unauthorized")
 	}
 	id := uuid.New().String()
-	post, err := r.PostUsecase.CreatePost(ctx, id, text, user.ID, permissionToComment)
+	post, err := r.PostUsecase.CreatePost(ctx, id, text, user.ID, permissionToComment, tags, format)
 	if err != nil {
 		return nil, err
 	}
+	// Пост уже создан, поэтому ошибка рассылки уведомлений не возвращается клиенту
+	if err := r.NotificationUsecase.PostCreated(ctx, post); err != nil {
+		logger.FromContext(ctx).Error("failed to create notifications", "post_id", post.ID, "error", err)
+	}
 	return post, nil
 }
+
+// Метод редактирования поста
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, text *string, commentable *bool, tags []string, format *model.TextFormat) (*model.Post, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	post, err := r.PostUsecase.UpdatePost(ctx, id, user.ID, text, commentable, tags, format)
+	if err != nil {
+		return nil, err
+	}
+	post.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, post.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// Метод удаления поста
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return false, errors.New("unauthorized")
+	}
+	if err := r.PostUsecase.DeletePost(ctx, id, user.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Комментарии поста. Без аргументов возвращаются комментарии, загруженные вместе с постом,
+// иначе они запрашиваются заново в нужном порядке и с нужной пагинацией
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, sort *model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	if sort == nil && limit == nil && offset == nil && obj.Comments != nil {
+		return obj.Comments, nil
+	}
+	comments, err := r.CommentUsecase.GetCommentsByPostID(ctx, obj.ID, commentSort(sort), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return r.withAuthors(ctx, comments)
+}
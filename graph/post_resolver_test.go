@@ -28,13 +28,13 @@ func TestPosts(t *testing.T) {
 		{ID: "1", Comment: "Test comment", AuthorID: "1"},
 	}
 
-	mockPostUsecase.On("GetAllPosts", ctx, &limit, &offset).Return(expectedPosts, nil)
+	mockPostUsecase.On("GetAllPosts", ctx, (*model.PostFilter)(nil), &limit, &offset).Return(expectedPosts, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", &limit, &offset).Return(expectedComments, nil)
+	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", model.CommentSortOld, &limit, &offset).Return(expectedComments, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", &limit, &offset).Return([]*model.CommentResponse{}, nil)
+	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", model.CommentSortOld, &limit, &offset).Return([]*model.CommentResponse{}, nil)
 
-	posts, err := resolver.Posts(ctx, &limit, &offset)
+	posts, err := resolver.Posts(ctx, nil, &limit, &offset)
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedPosts, posts)
@@ -65,9 +65,9 @@ func TestPostsByUserID(t *testing.T) {
 
 	mockPostUsecase.On("GetPostsByUserID", ctx, userID, &limit, &offset).Return(expectedPosts, nil)
 	mockUserUsecase.On("GetUserByID", ctx, userID).Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", &limit, &offset).Return(expectedComments, nil)
+	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", model.CommentSortOld, &limit, &offset).Return(expectedComments, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", &limit, &offset).Return([]*model.CommentResponse{}, nil)
+	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", model.CommentSortOld, &limit, &offset).Return([]*model.CommentResponse{}, nil)
 
 	posts, err := resolver.PostsByUserID(ctx, userID, &limit, &offset)
 
@@ -97,9 +97,9 @@ func TestPost(t *testing.T) {
 
 	mockPostUsecase.On("GetPostByID", ctx, id).Return(expectedPost, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", &limit, &offset).Return(expectedComments, nil)
+	mockCommentUsecase.On("GetCommentsByPostID", ctx, "1", model.CommentSortOld, &limit, &offset).Return(expectedComments, nil)
 	mockUserUsecase.On("GetUserByID", ctx, "1").Return(expectedUser, nil)
-	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", &limit, &offset).Return([]*model.CommentResponse{}, nil)
+	mockCommentUsecase.On("GetCommentsByParentID", ctx, "1", model.CommentSortOld, &limit, &offset).Return([]*model.CommentResponse{}, nil)
 
 	post, err := resolver.Post(ctx, id, &limit, &offset)
 
@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/querylimit"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type limitsResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func doLimitedQuery(t *testing.T, cfg *config.QueryLimitsConfig, query string, claim *service.JwtCustomClaim) limitsResponse {
+	t.Helper()
+	h := handler.NewDefaultServer(querylimit.WithCosts(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(storage.NewInMemoryStorage())}), cfg))
+	h.Use(querylimit.NewLimit(cfg))
+
+	body, err := json.Marshal(map[string]string{"query": query})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if claim != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthKey, claim))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var resp limitsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestComplexityLimitMultipliesListCosts(t *testing.T) {
+	cfg := &config.QueryLimitsConfig{MaxComplexityAnonymous: 1000, MaxComplexityAuthenticated: 1000, MaxDepth: 10, DefaultListSize: 20}
+
+	resp := doLimitedQuery(t, cfg, `{ users(limit: 50) { posts { comments { replies { id } } } } }`, nil)
+
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, querylimit.ErrComplexityLimit, resp.Errors[0].Extensions["code"])
+	// replies: 1+20*1, comments: 1+20*21, posts: 1+20*421, users: 1+50*8421
+	assert.EqualValues(t, 421051, resp.Errors[0].Extensions["complexity"])
+	assert.EqualValues(t, 1000, resp.Errors[0].Extensions["limit"])
+}
+
+func TestComplexityLimitDependsOnAuthentication(t *testing.T) {
+	cfg := &config.QueryLimitsConfig{MaxComplexityAnonymous: 10, MaxComplexityAuthenticated: 100, MaxDepth: 10, DefaultListSize: 20}
+	query := `{ posts(limit: 5) { id text } }`
+
+	anonymous := doLimitedQuery(t, cfg, query, nil)
+	require.Len(t, anonymous.Errors, 1)
+	assert.Equal(t, querylimit.ErrComplexityLimit, anonymous.Errors[0].Extensions["code"])
+	assert.EqualValues(t, 11, anonymous.Errors[0].Extensions["complexity"])
+	assert.Equal(t, false, anonymous.Errors[0].Extensions["authenticated"])
+
+	authenticated := doLimitedQuery(t, cfg, query, &service.JwtCustomClaim{ID: "1"})
+	assert.Empty(t, authenticated.Errors)
+	assert.JSONEq(t, `{"posts":[]}`, string(authenticated.Data))
+}
+
+func TestComplexityLimitUsesFieldCosts(t *testing.T) {
+	cfg := &config.QueryLimitsConfig{MaxComplexityAnonymous: 10, MaxComplexityAuthenticated: 10, MaxDepth: 10, DefaultListSize: 20,
+		FieldCosts: map[string]int{"Query.posts": 50}}
+
+	resp := doLimitedQuery(t, cfg, `{ posts(limit: 0) { id } }`, nil)
+
+	require.Len(t, resp.Errors, 1)
+	assert.EqualValues(t, 50, resp.Errors[0].Extensions["complexity"])
+}
+
+func TestDepthLimit(t *testing.T) {
+	cfg := &config.QueryLimitsConfig{MaxComplexityAnonymous: 1 << 30, MaxComplexityAuthenticated: 1 << 30, MaxDepth: 5, DefaultListSize: 1}
+
+	resp := doLimitedQuery(t, cfg, `
+		query { users { ...UserPosts } }
+		fragment UserPosts on User { posts { comments { replies { authorComment { username } } } } }`, nil)
+
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, querylimit.ErrDepthLimit, resp.Errors[0].Extensions["code"])
+	assert.EqualValues(t, 6, resp.Errors[0].Extensions["depth"])
+	assert.EqualValues(t, 5, resp.Errors[0].Extensions["maxDepth"])
+}
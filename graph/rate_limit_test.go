@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/ratelimit"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.RateLimitConfig{Enabled: true, Rules: map[string]config.RateLimitRule{
+		"loginUser": {Limit: 1, Per: time.Minute},
+	}}
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(storage.NewInMemoryStorage())}))
+	h.Use(ratelimit.NewExtension(ratelimit.NewMemoryStore(), cfg))
+
+	r := gin.New()
+	r.Use(middleware.ClientIP())
+	r.POST("/graphql", func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), middleware.AuthKey, &service.JwtCustomClaim{ID: userID}))
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+	})
+
+	login := func(remoteAddr, userID string) limitsResponse {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"mutation { loginUser(username: \"u\", password: \"p\") { token } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			req.Header.Set("X-Test-User", userID)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		var resp limitsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp
+	}
+	code := func(resp limitsResponse) interface{} {
+		require.Len(t, resp.Errors, 1)
+		return resp.Errors[0].Extensions["code"]
+	}
+
+	// Первый вызов доходит до резольвера и падает, так как пользователя нет
+	assert.Nil(t, code(login("10.0.0.1:1234", "")))
+
+	limited := login("10.0.0.1:1234", "")
+	assert.Equal(t, ratelimit.ErrRateLimited, code(limited))
+	assert.EqualValues(t, 60, limited.Errors[0].Extensions["retryAfter"])
+
+	// Другой IP и аутентифицированный пользователь расходуют свои корзины
+	assert.Nil(t, code(login("10.0.0.2:1234", "")))
+	assert.Nil(t, code(login("10.0.0.1:1234", "user-1")))
+	assert.Equal(t, ratelimit.ErrRateLimited, code(login("10.0.0.3:1234", "user-1")))
+}
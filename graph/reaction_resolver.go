@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Метод установки реакции на пост или комментарий
+func (r *mutationResolver) React(ctx context.Context, itemID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.ReactionUsecase.React(ctx, itemID, user.ID, kind)
+}
+
+// Метод снятия реакции с поста или комментария
+func (r *mutationResolver) Unreact(ctx context.Context, itemID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return nil, errors.New("unauthorized")
+	}
+	return r.ReactionUsecase.Unreact(ctx, itemID, user.ID, kind)
+}
+
+// Счетчики реакций на пост
+func (r *postResolver) ReactionCounts(ctx context.Context, obj *model.Post) ([]*model.ReactionCount, error) {
+	return r.ReactionUsecase.GetReactionCounts(ctx, obj.ID)
+}
+
+// Реакции текущего пользователя на пост
+func (r *postResolver) ViewerReaction(ctx context.Context, obj *model.Post) ([]model.ReactionKind, error) {
+	return r.viewerReaction(ctx, obj.ID)
+}
+
+// Счетчики реакций на комментарий
+func (r *commentResponseResolver) ReactionCounts(ctx context.Context, obj *model.CommentResponse) ([]*model.ReactionCount, error) {
+	return r.ReactionUsecase.GetReactionCounts(ctx, obj.ID)
+}
+
+// Реакции текущего пользователя на комментарий
+func (r *commentResponseResolver) ViewerReaction(ctx context.Context, obj *model.CommentResponse) ([]model.ReactionKind, error) {
+	return r.viewerReaction(ctx, obj.ID)
+}
+
+// viewerReaction возвращает реакции текущего пользователя, для анонимного запроса - пустой список
+func (r *Resolver) viewerReaction(ctx context.Context, itemID string) ([]model.ReactionKind, error) {
+	user := middleware.CtxValue(ctx)
+	if user == nil {
+		return []model.ReactionKind{}, nil
+	}
+	return r.ReactionUsecase.GetUserReactions(ctx, itemID, user.ID)
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doAsUser выполняет GraphQL запрос от имени пользователя (или анонимно, если userID пустой)
+func doAsUser(t *testing.T, h http.Handler, userID, query string) limitsResponse {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"query": query})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthKey, &service.JwtCustomClaim{ID: userID}))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp limitsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestReactions(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-1", "Post", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	comment, err := store.CreateComment(ctx, "Comment", "post-1", bob.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	// Повторная реакция того же вида не увеличивает счетчик
+	for i := 0; i < 2; i++ {
+		resp := doAsUser(t, h, alice.ID, `mutation { react(itemId: "post-1", kind: LIKE) { itemId } }`)
+		require.Empty(t, resp.Errors)
+	}
+	resp := doAsUser(t, h, bob.ID, `mutation { react(itemId: "post-1", kind: LIKE) { reactionCounts { kind count } viewerReaction } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"react":{"reactionCounts":[{"kind":"LIKE","count":2}],"viewerReaction":["LIKE"]}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, bob.ID, `mutation { react(itemId: "post-1", kind: LOVE) { itemId } }`)
+	require.Empty(t, resp.Errors)
+	resp = doAsUser(t, h, alice.ID, `mutation { unreact(itemId: "post-1", kind: LIKE) { itemId } }`)
+	require.Empty(t, resp.Errors)
+	resp = doAsUser(t, h, alice.ID, `mutation { react(itemId: "`+comment.ID+`", kind: LAUGH) { itemId } }`)
+	require.Empty(t, resp.Errors)
+
+	resp = doAsUser(t, h, bob.ID, `{ post(id: "post-1") { reactionCounts { kind count } viewerReaction comments { reactionCounts { kind count } viewerReaction } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"post":{
+		"reactionCounts":[{"kind":"LIKE","count":1},{"kind":"LOVE","count":1}],
+		"viewerReaction":["LIKE","LOVE"],
+		"comments":[{"reactionCounts":[{"kind":"LAUGH","count":1}],"viewerReaction":[]}]
+	}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, "", `{ post(id: "post-1") { viewerReaction } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"post":{"viewerReaction":[]}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, "", `mutation { react(itemId: "post-1", kind: LIKE) { itemId } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "unauthorized", resp.Errors[0].Message)
+
+	resp = doAsUser(t, h, alice.ID, `mutation { react(itemId: "missing", kind: LIKE) { itemId } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "item not found", resp.Errors[0].Message)
+}
@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentRegistration(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	h.SetErrorPresenter(ErrorPresenter)
+
+	const workers = 8
+	responses := make([]limitsResponse, workers)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			// Имена отличаются только регистром
+			username := "racer"
+			if i%2 == 1 {
+				username = "Racer"
+			}
+			responses[i] = doAsUser(t, h, "", fmt.Sprintf(`mutation { registerUser(username: %q, password: "secret") { id } }`, username))
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created := 0
+	for _, resp := range responses {
+		if len(resp.Errors) == 0 {
+			created++
+			continue
+		}
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "user already exists", resp.Errors[0].Message)
+		assert.Equal(t, ErrCodeConflict, resp.Errors[0].Extensions["code"])
+		assert.Equal(t, "username", resp.Errors[0].Extensions["field"])
+	}
+	assert.Equal(t, 1, created)
+
+	users, err := store.GetAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// Пароль хэшируется один раз, поэтому с ним можно войти
+	resp := doAsUser(t, h, "", `mutation { loginUser(username: "RACER", password: "secret") { token } }`)
+	assert.Empty(t, resp.Errors)
+}
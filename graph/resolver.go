@@ -6,9 +6,15 @@ import (
 
 // Тип Resolver, который ответственен за работу с данными в нашей схеме GraphQL
 type Resolver struct {
-	UserUsecase    usecase.UserUsecase
-	CommentUsecase usecase.CommentUsecase
-	PostUsecase    usecase.PostUsecase
+	UserUsecase         usecase.UserUsecase
+	CommentUsecase      usecase.CommentUsecase
+	PostUsecase         usecase.PostUsecase
+	SearchUsecase       usecase.SearchUsecase
+	ReactionUsecase     usecase.ReactionUsecase
+	FollowUsecase       usecase.FollowUsecase
+	NotificationUsecase usecase.NotificationUsecase
+	MentionUsecase      usecase.MentionUsecase
+	MarkdownUsecase     usecase.MarkdownUsecase
 }
 
 // Функция возвращающая тип Запросов нашего резольвера
@@ -21,6 +27,44 @@ func (r *Resolver) Mutation() MutationResolver {
 	return &mutationResolver{r}
 }
 
+// Функция возвращающая резольвер вычисляемых полей поста
+func (r *Resolver) Post() PostResolver {
+	return &postResolver{r}
+}
+
+// Функция возвращающая резольвер вычисляемых полей комментария
+func (r *Resolver) CommentResponse() CommentResponseResolver {
+	return &commentResponseResolver{r}
+}
+
+// Функция возвращающая тип подписок нашего резольвера
+func (r *Resolver) Subscription() SubscriptionResolver {
+	return &subscriptionResolver{r}
+}
+
+// Функция возвращающая резольвер вычисляемых полей уведомления
+func (r *Resolver) Notification() NotificationResolver {
+	return &notificationResolver{r}
+}
+
+// Функция возвращающая резольвер вычисляемых полей упоминания
+func (r *Resolver) Mention() MentionResolver {
+	return &mentionResolver{r}
+}
+
+// Функция возвращающая резольвер вычисляемых полей пользователя
+func (r *Resolver) User() UserResolver {
+	return &userResolver{r}
+}
+
 // Типы используемых методов GraphQL - тип запросов (аналог GET) и мутации (запросы, способных изменить данные)
 type queryResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+
+// Резольверы полей типов, которые не хранятся в модели и вычисляются отдельными запросами
+type postResolver struct{ *Resolver }
+type commentResponseResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
+type notificationResolver struct{ *Resolver }
+type mentionResolver struct{ *Resolver }
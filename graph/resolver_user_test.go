@@ -3,12 +3,12 @@ package graph
 
 import (
 	"context"
-	"errors"
 	"testing"
 
 	"github.com/VadimRight/GraphQLOzon/internal/usecase"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestUsers(t *testing.T) {
@@ -98,7 +98,7 @@ func TestLoginUser(t *testing.T) {
 	expectedToken := "token"
 
 	mockUserUsecase.On("GetUserByUsername", ctx, username).Return(expectedUser, nil)
-	mockUserUsecase.On("ComparePassword", hashedPassword, password).Return(false) // Return false for no error
+	mockUserUsecase.On("ComparePassword", hashedPassword, password).Return(true) // true - пароль совпадает
 	mockUserUsecase.On("GenerateToken", ctx, userID).Return(expectedToken, nil)
 
 	token, err := resolver.LoginUser(ctx, username, password)
@@ -108,6 +108,40 @@ func TestLoginUser(t *testing.T) {
 	mockUserUsecase.AssertExpectations(t)
 }
 
+func TestLoginUserIncorrectPassword(t *testing.T) {
+	mockUserUsecase := new(usecase.MockUserUsecase)
+	resolver := &mutationResolver{&Resolver{UserUsecase: mockUserUsecase}}
+
+	ctx := context.Background()
+	user := &model.User{ID: "1", Username: "user1", Password: "hashedPassword"}
+	mockUserUsecase.On("GetUserByUsername", ctx, "user1").Return(user, nil)
+	mockUserUsecase.On("ComparePassword", "hashedPassword", "wrong").Return(false) // false - пароль не совпадает
+
+	token, err := resolver.LoginUser(ctx, "user1", "wrong")
+
+	assert.EqualError(t, err, "Incorrect password")
+	assert.Nil(t, token)
+	// Токен для неверного пароля не выпускается
+	mockUserUsecase.AssertNotCalled(t, "GenerateToken", mock.Anything, mock.Anything)
+	mockUserUsecase.AssertExpectations(t)
+}
+
+func TestLoginDisabledUser(t *testing.T) {
+	mockUserUsecase := new(usecase.MockUserUsecase)
+	resolver := &mutationResolver{&Resolver{UserUsecase: mockUserUsecase}}
+
+	ctx := context.Background()
+	user := &model.User{ID: "1", Username: "user1", Password: "hashedPassword", Disabled: true}
+	mockUserUsecase.On("GetUserByUsername", ctx, "user1").Return(user, nil)
+	mockUserUsecase.On("ComparePassword", "hashedPassword", "password").Return(true)
+
+	token, err := resolver.LoginUser(ctx, "user1", "password")
+
+	assert.EqualError(t, err, "User is disabled")
+	assert.Nil(t, token)
+	mockUserUsecase.AssertExpectations(t)
+}
+
 func TestRegisterUser(t *testing.T) {
 	mockUserUsecase := new(usecase.MockUserUsecase)
 	resolver := &mutationResolver{&Resolver{UserUsecase: mockUserUsecase}}
@@ -118,12 +152,8 @@ func TestRegisterUser(t *testing.T) {
 	hashedPassword := "hashedPassword"
 	expectedUser := &model.User{ID: "1", Username: username, Password: hashedPassword}
 
-	// Mock the GetUserByUsername to return an error indicating the user does not exist
-	mockUserUsecase.On("GetUserByUsername", ctx, username).Return(nil, errors.New("user not found"))
-	// Mock the HashPassword to return the hashed password
-	mockUserUsecase.On("HashPassword", password).Return(hashedPassword, nil)
-	// Mock the UserCreate to return the created user
-	mockUserUsecase.On("UserCreate", ctx, username, hashedPassword).Return(expectedUser, nil)
+	// Mock the UserCreate to hash the password and return the created user
+	mockUserUsecase.On("UserCreate", ctx, username, password).Return(expectedUser, nil)
 
 	user, err := resolver.RegisterUser(ctx, username, password)
 
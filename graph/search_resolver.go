@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Метод полнотекстового поиска по постам и комментариям
+func (r *queryResolver) Search(ctx context.Context, query string, typeArg []model.SearchType, first *int, after *string) (*model.SearchConnection, error) {
+	connection, err := r.SearchUsecase.Search(ctx, query, typeArg, first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edge := range connection.Edges {
+		// Заполняем автора найденного поста или комментария
+		switch node := edge.Node.(type) {
+		case *model.Post:
+			node.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, node.AuthorID)
+		case *model.CommentResponse:
+			node.AuthorComment, err = r.UserUsecase.GetUserByID(ctx, node.AuthorID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return connection, nil
+}
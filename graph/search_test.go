@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchResponse struct {
+	Search struct {
+		Edges []struct {
+			Cursor  string  `json:"cursor"`
+			Snippet string  `json:"snippet"`
+			Score   float64 `json:"score"`
+			Node    struct {
+				Typename string `json:"__typename"`
+				ID       string `json:"id"`
+			} `json:"node"`
+		} `json:"edges"`
+		PageInfo struct {
+			EndCursor   *string `json:"endCursor"`
+			HasNextPage bool    `json:"hasNextPage"`
+		} `json:"pageInfo"`
+	} `json:"search"`
+}
+
+const searchGraphQL = `query Search($query: String!, $type: [SearchType!], $first: Int, $after: String) {
+  search(query: $query, type: $type, first: $first, after: $after) {
+    edges {
+      cursor snippet score
+      node {
+        __typename
+        ... on Post { id authorPost { username } }
+        ... on CommentResponse { id authorComment { username } }
+      }
+    }
+    pageInfo { endCursor hasNextPage }
+  }
+}`
+
+func newSearchServer(t *testing.T) *handler.Server {
+	t.Helper()
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	author, err := store.UserCreate(ctx, "author", "hash")
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-en", "Testing GraphQL subscriptions with websockets", author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-ru", "Тестируем подписки в GraphQL", author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = store.CreateComment(ctx, "Websockets are great, subscriptions too", "post-en", author.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-other", "Nothing related here", author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	return handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+}
+
+func search(t *testing.T, h *handler.Server, variables map[string]interface{}) searchResponse {
+	t.Helper()
+	resp := postGraphQL(t, h, map[string]interface{}{"query": searchGraphQL, "variables": variables})
+	require.Empty(t, resp.Errors)
+	var data searchResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	return data
+}
+
+func TestSearchFindsPostsAndComments(t *testing.T) {
+	h := newSearchServer(t)
+
+	data := search(t, h, map[string]interface{}{"query": "websocket subscription"})
+
+	require.Len(t, data.Search.Edges, 2)
+	typenames := []string{data.Search.Edges[0].Node.Typename, data.Search.Edges[1].Node.Typename}
+	assert.ElementsMatch(t, []string{"Post", "CommentResponse"}, typenames)
+	assert.GreaterOrEqual(t, data.Search.Edges[0].Score, data.Search.Edges[1].Score)
+	for _, edge := range data.Search.Edges {
+		assert.Contains(t, edge.Snippet, "<b>")
+		assert.Greater(t, edge.Score, 0.0)
+	}
+	assert.False(t, data.Search.PageInfo.HasNextPage)
+}
+
+func TestSearchRussianWordForms(t *testing.T) {
+	h := newSearchServer(t)
+
+	data := search(t, h, map[string]interface{}{"query": "подписками", "type": []string{"POST"}})
+
+	require.Len(t, data.Search.Edges, 1)
+	assert.Equal(t, "post-ru", data.Search.Edges[0].Node.ID)
+	assert.Equal(t, "Тестируем <b>подписки</b> в GraphQL", data.Search.Edges[0].Snippet)
+}
+
+func TestSearchTypeFilterAndPagination(t *testing.T) {
+	h := newSearchServer(t)
+
+	first := search(t, h, map[string]interface{}{"query": "graphql", "type": []string{"POST"}, "first": 1})
+	require.Len(t, first.Search.Edges, 1)
+	require.True(t, first.Search.PageInfo.HasNextPage)
+	require.NotNil(t, first.Search.PageInfo.EndCursor)
+
+	second := search(t, h, map[string]interface{}{"query": "graphql", "type": []string{"POST"}, "first": 1, "after": *first.Search.PageInfo.EndCursor})
+	require.Len(t, second.Search.Edges, 1)
+	assert.False(t, second.Search.PageInfo.HasNextPage)
+	assert.ElementsMatch(t, []string{"post-en", "post-ru"}, []string{first.Search.Edges[0].Node.ID, second.Search.Edges[0].Node.ID})
+
+	comments := search(t, h, map[string]interface{}{"query": "graphql", "type": []string{"COMMENT"}})
+	assert.Empty(t, comments.Search.Edges)
+}
+
+func TestSearchRejectsOverflowingCursor(t *testing.T) {
+	h := newSearchServer(t)
+
+	for _, offset := range []string{strconv.Itoa(math.MaxInt), strconv.Itoa(cursor.MaxOffset + 1), "99999999999999999999"} {
+		after := base64.RawURLEncoding.EncodeToString([]byte("offset:" + offset))
+		resp := postGraphQL(t, h, map[string]interface{}{"query": searchGraphQL, "variables": map[string]interface{}{"query": "graphql", "after": after}})
+		require.Len(t, resp.Errors, 1, offset)
+		assert.Equal(t, "invalid cursor", resp.Errors[0].Message)
+	}
+
+	// Отрицательное смещение, дошедшее до хранилища, дает пустую страницу, а не панику
+	hits, err := storage.NewInMemoryStorage().Search(context.Background(), "graphql", nil, 10, -1)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+}
+
+func TestSearchSnippetEscapesHTML(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	author, err := store.UserCreate(ctx, "author", "hash")
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-html", `Some payload <img src=x onerror="alert(1)"> & more`, author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+
+	data := search(t, h, map[string]interface{}{"query": "payload"})
+
+	require.Len(t, data.Search.Edges, 1)
+	assert.Equal(t, `Some <b>payload</b> &lt;img src=x onerror=&#34;alert(1)&#34;&gt; &amp; more`, data.Search.Edges[0].Snippet)
+}
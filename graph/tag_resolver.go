@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Теги поста
+func (r *postResolver) Tags(ctx context.Context, obj *model.Post) ([]string, error) {
+	return r.PostUsecase.GetPostTags(ctx, obj.ID)
+}
+
+// Посты с тегом от новых к старым
+func (r *queryResolver) PostsByTag(ctx context.Context, tag string, first *int, after *string) (*model.PostConnection, error) {
+	connection, err := r.PostUsecase.GetPostsByTag(ctx, tag, first, after)
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range connection.Edges {
+		edge.Node.AuthorPost, err = r.UserUsecase.GetUserByID(ctx, edge.Node.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return connection, nil
+}
+
+// Самые используемые теги за последний промежуток window
+func (r *queryResolver) TrendingTags(ctx context.Context, window time.Duration, limit *int) ([]*model.TrendingTag, error) {
+	return r.PostUsecase.GetTrendingTags(ctx, window, limit)
+}
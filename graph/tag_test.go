@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := store.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	createPost := func(text, tags string) string {
+		t.Helper()
+		resp := doAsUser(t, h, alice.ID, `mutation { createPost(text: "`+text+`", commentable: true, tags: `+tags+`) { id } }`)
+		require.Empty(t, resp.Errors)
+		var data struct {
+			CreatePost struct {
+				ID string `json:"id"`
+			} `json:"createPost"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+		return data.CreatePost.ID
+	}
+
+	first := createPost("Learning #Go and #ＧｒａｐｈＱＬ", `["#News"]`)
+	createPost("More #go", `[]`)
+
+	resp := doAsUser(t, h, "", `{ post(id: "`+first+`") { tags } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"post":{"tags":["go","graphql","news"]}}`, string(resp.Data))
+
+	// Теги из текста пересчитываются, а явный тег news сохраняется
+	resp = doAsUser(t, h, alice.ID, `mutation { updatePost(id: "`+first+`", text: "Now about #Rust") { text commentable tags authorPost { username } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"updatePost":{"text":"Now about #Rust","commentable":true,"tags":["news","rust"],"authorPost":{"username":"alice"}}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, bob.ID, `mutation { updatePost(id: "`+first+`", commentable: false) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "only the author can edit the post", resp.Errors[0].Message)
+
+	resp = doAsUser(t, h, "", `{ postsByTag(tag: "#GO") { edges { node { text authorPost { username } } } pageInfo { hasNextPage } } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"postsByTag":{"edges":[{"node":{"text":"More #go","authorPost":{"username":"alice"}}}],"pageInfo":{"hasNextPage":false}}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, "", `{ trendingTags(window: "1h", limit: 2) { tag count } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"trendingTags":[{"tag":"go","count":1},{"tag":"news","count":1}]}`, string(resp.Data))
+
+	resp = doAsUser(t, h, "", `{ trendingTags(window: "1w") { tag } }`)
+	require.Len(t, resp.Errors, 1)
+
+	// Число дней, при котором длительность переполнилась бы, отклоняется
+	resp = doAsUser(t, h, "", `{ trendingTags(window: "106752d") { tag } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, `duration "106752d" is too long`)
+
+	// Явный тег, который был и хэштегом в прежнем тексте, остается после смены текста
+	second := createPost("About #Go", `["go"]`)
+	resp = doAsUser(t, h, alice.ID, `mutation { updatePost(id: "`+second+`", text: "Now about #Rust") { tags } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"updatePost":{"tags":["go","rust"]}}`, string(resp.Data))
+	resp = doAsUser(t, h, alice.ID, `mutation { updatePost(id: "`+second+`", tags: []) { tags } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"updatePost":{"tags":["rust"]}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, alice.ID, `mutation { createPost(text: "Bad tag", commentable: true, tags: ["no spaces"]) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, `invalid tag "no spaces"`, resp.Errors[0].Message)
+}
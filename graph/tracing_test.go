@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/notify"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/internal/tracing"
+	"github.com/VadimRight/GraphQLOzon/internal/usecase"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newInMemoryResolver собирает резольвер с настоящими usecase поверх переданного хранилища
+func newInMemoryResolver(store storage.Storage) *Resolver {
+	markdownUsecase := usecase.NewMarkdownUsecase(&config.MarkdownConfig{CacheSize: 100, MaxNesting: 20, MaxTableCells: 1000})
+	validator := validation.NewValidator(&config.ValidationConfig{
+		UsernamePattern:   regexp.MustCompile(`^[\p{L}\p{N}_]+$`),
+		UsernameMinLength: 3,
+		UsernameMaxLength: 20,
+		PostMaxLength:     10000,
+		CommentMaxLength:  2000,
+	})
+	commentUsecase := usecase.NewCommentUsecase(store, markdownUsecase, validator)
+	return &Resolver{
+		UserUsecase:         usecase.NewUserUsecase(store, commentUsecase, service.NewPasswordService(), service.NewAuthService("secret", time.Hour), validator),
+		PostUsecase:         usecase.NewPostUsecase(store, markdownUsecase, validator),
+		CommentUsecase:      commentUsecase,
+		SearchUsecase:       usecase.NewSearchUsecase(store),
+		ReactionUsecase:     usecase.NewReactionUsecase(store),
+		FollowUsecase:       usecase.NewFollowUsecase(store),
+		NotificationUsecase: usecase.NewNotificationUsecase(store, notify.NewBroker()),
+		MentionUsecase:      usecase.NewMentionUsecase(store),
+		MarkdownUsecase:     markdownUsecase,
+	}
+}
+
+func TestTracingSpanStructure(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	author, err := store.UserCreate(ctx, "author", "hash")
+	require.NoError(t, err)
+	_, err = store.CreatePost(ctx, "post-1", "Test post", author.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	h.Use(tracing.Extension{})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"query ListPosts { posts { id } }","operationName":"ListPosts"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), `"errors"`)
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, span := range spans {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String(), span.Name)
+		byName[span.Name] = span
+	}
+
+	operation, ok := byName["query ListPosts"]
+	require.True(t, ok, "operation span is missing")
+	assert.True(t, operation.Parent.IsRemote())
+
+	field, ok := byName["Query.posts"]
+	require.True(t, ok, "field span is missing")
+	assert.Equal(t, operation.SpanContext.SpanID(), field.Parent.SpanID())
+
+	for _, name := range []string{"PostUsecase.GetAllPosts", "UserUsecase.GetUserByID", "CommentUsecase.GetCommentsByPostID"} {
+		span, ok := byName[name]
+		require.True(t, ok, "usecase span %s is missing", name)
+		assert.Equal(t, field.SpanContext.SpanID(), span.Parent.SpanID(), name)
+	}
+}
+
+func TestTracingDoesNotRecordQueryText(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(storage.NewInMemoryStorage())}))
+	h.Use(tracing.Extension{})
+
+	const query = `mutation Login { loginUser(username: \"alice\", password: \"hunter2secret\") { token } }`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"`+query+`","operationName":"Login"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.NotEmpty(t, spans)
+	for _, span := range spans {
+		for _, attr := range span.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "hunter2secret", "%s: %s", span.Name, attr.Key)
+		}
+		for _, event := range span.Events {
+			for _, attr := range event.Attributes {
+				assert.NotContains(t, attr.Value.Emit(), "hunter2secret", "%s: %s", span.Name, attr.Key)
+			}
+		}
+	}
+}
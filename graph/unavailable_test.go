@@ -0,0 +1,34 @@
+package graph
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// downStorage имитирует базу данных, соединение с которой пропало во время работы
+type downStorage struct {
+	storage.Storage
+}
+
+func (downStorage) GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+}
+
+func TestStorageUnavailable(t *testing.T) {
+	store := downStorage{Storage: storage.NewInMemoryStorage()}
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	h.SetErrorPresenter(ErrorPresenter)
+
+	resp := doAsUser(t, h, "", `{ posts { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "service temporarily unavailable", resp.Errors[0].Message)
+	assert.Equal(t, ErrCodeUnavailable, resp.Errors[0].Extensions["code"])
+}
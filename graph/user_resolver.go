@@ -3,7 +3,6 @@ package graph
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	"github.com/VadimRight/GraphQLOzon/model"
 )
@@ -30,7 +29,7 @@ func (r *queryResolver) Users(ctx context.Context, limit, offset *int) ([]*model
 			}
 
 			// Получаем комментарии для поста
-			post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+			post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 			if err != nil {
 				return nil, err
 			}
@@ -43,7 +42,7 @@ func (r *queryResolver) Users(ctx context.Context, limit, offset *int) ([]*model
 				}
 
 				// Получаем ответы для каждого комментария
-				comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+				comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 				if err != nil {
 					return nil, err
 				}
@@ -83,7 +82,7 @@ func (r *queryResolver) User(ctx context.Context, id string, limit, offset *int)
 		}
 
 		// Получаем комментарии для поста
-		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -96,7 +95,7 @@ func (r *queryResolver) User(ctx context.Context, id string, limit, offset *int)
 			}
 
 			// Получаем ответы для каждого комментария
-			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 			if err != nil {
 				return nil, err
 			}
@@ -133,7 +132,7 @@ func (r *queryResolver) UserByUsername(ctx context.Context, username string, lim
 		}
 
 		// Получение комментариев для поста с учетом пагинации
-		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+		post.Comments, err = r.CommentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -146,7 +145,7 @@ func (r *queryResolver) UserByUsername(ctx context.Context, username string, lim
 			}
 
 			// Получение ответов для каждого комментария с учетом пагинации
-			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, limit, offset)
+			comment.Replies, err = r.CommentUsecase.GetCommentsByParentID(ctx, comment.ID, model.CommentSortOld, limit, offset)
 			if err != nil {
 				return nil, err
 			}
@@ -170,12 +169,13 @@ func (r *mutationResolver) LoginUser(ctx context.Context, username string, passw
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println(getUser.Password)
-	fmt.Println(password)
-	dismatch := r.UserUsecase.ComparePassword(getUser.Password, password)
-	if dismatch == true {
+	if !r.UserUsecase.ComparePassword(getUser.Password, password) {
 		return nil, errors.New("Incorrect password")
 	}
+	// Блокировка проверяется после пароля, чтобы не раскрывать ее тому, кто пароля не знает
+	if getUser.Disabled {
+		return nil, errors.New("User is disabled")
+	}
 	token, err := r.UserUsecase.GenerateToken(ctx, getUser.ID)
 	if err != nil {
 		return nil, err
@@ -183,17 +183,10 @@ func (r *mutationResolver) LoginUser(ctx context.Context, username string, passw
 	return &model.Token{Token: token}, nil
 }
 
-// Метод регистрации пользователя
+// Метод регистрации пользователя. Пароль хэширует usecase, а занятость имени проверяется
+// хранилищем при вставке, поэтому отдельной проверки перед созданием нет
 func (r *mutationResolver) RegisterUser(ctx context.Context, username string, password string) (*model.User, error) {
-	_, err := r.UserUsecase.GetUserByUsername(ctx, username)
-	if err == nil {
-		return nil, errors.New("user already exists")
-	}
-	hashedPassword, err := r.UserUsecase.HashPassword(password)
-	if err != nil {
-		return nil, err
-	}
-	createdUser, err := r.UserUsecase.UserCreate(ctx, username, hashedPassword)
+	createdUser, err := r.UserUsecase.UserCreate(ctx, username, password)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,60 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputValidation(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	alice, err := store.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+
+	h := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: newInMemoryResolver(store)}))
+	h.Use(validation.Extension{})
+
+	// Каждое нарушение возвращается отдельной ошибкой с именем аргумента
+	resp := doAsUser(t, h, "", `mutation { registerUser(username: "a ", password: "secret") { id } }`)
+	require.Len(t, resp.Errors, 2)
+	assert.Equal(t, "username: must be between 3 and 20 characters long", resp.Errors[0].Message)
+	assert.Equal(t, "username: must not contain whitespace, invisible or control characters", resp.Errors[1].Message)
+	for _, e := range resp.Errors {
+		assert.Equal(t, validation.ErrValidationFailed, e.Extensions["code"])
+		assert.Equal(t, "username", e.Extensions["field"])
+	}
+	users, err := store.GetAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// Имя сохраняется в форме NFKC
+	resp = doAsUser(t, h, "", `mutation { registerUser(username: "ｂｏｂ", password: "secret") { username } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"registerUser":{"username":"bob"}}`, string(resp.Data))
+
+	resp = doAsUser(t, h, alice.ID, `mutation { createPost(text: "  \n\t ", commentable: true) { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "text: must not be empty or contain only whitespace", resp.Errors[0].Message)
+	assert.Equal(t, "text", resp.Errors[0].Extensions["field"])
+
+	resp = doAsUser(t, h, alice.ID, `mutation { createPost(text: "Post", commentable: true) { id } }`)
+	require.Empty(t, resp.Errors)
+	posts, err := store.GetAllPosts(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+
+	resp = doAsUser(t, h, alice.ID, `mutation { createComment(comment: "`+strings.Repeat("x", 2001)+`", itemId: "`+posts[0].ID+`") { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "comment: must be at most 2000 characters long, got 2001", resp.Errors[0].Message)
+
+	resp = doAsUser(t, h, alice.ID, `mutation { updatePost(id: "`+posts[0].ID+`", text: "") { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "text", resp.Errors[0].Extensions["field"])
+}
@@ -0,0 +1,76 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy - экспоненциальная задержка между попытками: Initial, 2*Initial, 4*Initial и так далее,
+// но не больше Max. Попытки прекращаются, когда с первой прошло больше MaxElapsed
+type Policy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	MaxElapsed time.Duration
+}
+
+// Delay возвращает задержку перед повтором после попытки attempt (начиная с 1). Задержка случайна
+// в пределах от половины до полного экспоненциального значения, чтобы несколько экземпляров
+// приложения не повторяли попытки одновременно
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.Max
+	if shift := attempt - 1; shift < 32 && p.Initial<<shift < p.Max && p.Initial<<shift > 0 {
+		delay = p.Initial << shift
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// permanentError - ошибка, после которой повторять попытку бессмысленно
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как окончательную: Retry вернет ее сразу, без повторов
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry вызывает fn, пока она не вернет nil или окончательную ошибку, не истечет MaxElapsed
+// или не будет отменен ctx, и возвращает последнюю ошибку. onRetry, если задан,
+// вызывается перед каждым ожиданием
+func Retry(ctx context.Context, p Policy, fn func(ctx context.Context) error, onRetry func(attempt int, delay time.Duration, err error)) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		delay := p.Delay(attempt)
+		if time.Since(start)+delay > p.MaxElapsed {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{Initial: 100 * time.Millisecond, Max: time.Second}
+	tests := []struct {
+		attempt int
+		full    time.Duration
+	}{
+		{attempt: 1, full: 100 * time.Millisecond},
+		{attempt: 2, full: 200 * time.Millisecond},
+		{attempt: 4, full: 800 * time.Millisecond},
+		{attempt: 5, full: time.Second},
+		{attempt: 100, full: time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			delay := p.Delay(tt.attempt)
+			assert.GreaterOrEqual(t, delay, tt.full/2)
+			assert.LessOrEqual(t, delay, tt.full)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	p := Policy{Initial: time.Millisecond, Max: 4 * time.Millisecond, MaxElapsed: time.Second}
+	errDown := errors.New("connection refused")
+
+	calls := 0
+	var retries []int
+	err := Retry(ctx, p, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errDown
+		}
+		return nil
+	}, func(attempt int, delay time.Duration, err error) {
+		retries = append(retries, attempt)
+		assert.ErrorIs(t, err, errDown)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retries)
+
+	// Окончательная ошибка возвращается без повторов
+	calls = 0
+	errSchema := errors.New("syntax error")
+	err = Retry(ctx, p, func(ctx context.Context) error {
+		calls++
+		return Permanent(errSchema)
+	}, nil)
+	assert.Equal(t, errSchema, err)
+	assert.Equal(t, 1, calls)
+
+	// Попытки ограничены по времени
+	start := time.Now()
+	err = Retry(ctx, Policy{Initial: 10 * time.Millisecond, Max: 20 * time.Millisecond, MaxElapsed: 100 * time.Millisecond}, func(ctx context.Context) error {
+		return errDown
+	}, nil)
+	assert.ErrorIs(t, err, errDown)
+	assert.Less(t, time.Since(start), time.Second)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = Retry(cancelled, Policy{Initial: time.Second, Max: time.Second, MaxElapsed: time.Minute}, func(ctx context.Context) error {
+		return errDown
+	}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// Тип общей конфигурации
+type Config struct {
+	Env        *EnvConfig
+	Postgres   *PostgresConfig
+	Server     *ServerConfig
+	Storage    *StorageTypeConfig
+	Tracing    *TracingConfig
+	Log        *LogConfig
+	Query      *QueryLimitsConfig
+	RateLimit  *RateLimitConfig
+	Persisted  *PersistedQueriesConfig
+	Markdown   *MarkdownConfig
+	Validation *ValidationConfig
+	Cache      *StorageCacheConfig
+}
+
+// Тип конифурации типа хранилища, применяемого при запуске сервера
+type StorageTypeConfig struct {
+	StorageType string
+}
+
+// Тип конфигурации окружения (local или prod) и пути до файла конфигурации, если он задан
+type EnvConfig struct {
+	Env     string
+	EnvPath string
+}
+
+// Тип конфигурации трассировки OpenTelemetry
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	ServiceName  string
+}
+
+// Тип конфигурации логгера: минимальный уровень и формат вывода (json или text)
+type LogConfig struct {
+	Level  slog.Level
+	Format string
+}
+
+// Тип конфигурации ограничений на глубину и сложность GraphQL запросов.
+// FieldCosts задает стоимость отдельных полей в виде "Тип.поле" -> стоимость
+type QueryLimitsConfig struct {
+	MaxComplexityAnonymous     int
+	MaxComplexityAuthenticated int
+	MaxDepth                   int
+	DefaultListSize            int
+	FieldCosts                 map[string]int
+}
+
+// Правило ограничения частоты: не больше Limit вызовов за Per
+type RateLimitRule struct {
+	Limit int
+	Per   time.Duration
+}
+
+// Тип конфигурации ограничения частоты мутаций. Rules задает правило для каждой
+// мутации по ее имени в схеме, мутации без правила не ограничиваются
+type RateLimitConfig struct {
+	Enabled bool
+	Rules   map[string]RateLimitRule
+}
+
+// Тип конфигурации persisted-запросов: размер LRU кэша APQ и каталог с одобренными
+// .graphql операциями. В режиме AllowListOnly выполняются только одобренные операции
+type PersistedQueriesConfig struct {
+	CacheSize     int
+	AllowListDir  string
+	AllowListOnly bool
+}
+
+// Тип конфигурации Markdown: размер LRU кэша отрисованного HTML и ограничения на структуру
+// документа - глубину вложенности элементов и суммарное число ячеек таблиц
+type MarkdownConfig struct {
+	CacheSize     int
+	MaxNesting    int
+	MaxTableCells int
+}
+
+// Тип конфигурации проверки пользовательского ввода. Длины считаются в символах (кодовых
+// точках Unicode) после нормализации текста
+type ValidationConfig struct {
+	UsernamePattern   *regexp.Regexp
+	UsernameMinLength int
+	UsernameMaxLength int
+	PostMaxLength     int
+	CommentMaxLength  int
+}
+
+// Тип конфигурации кэша чтений хранилища: число пользователей и постов в LRU кэше
+// и время, через которое запись устаревает
+type StorageCacheConfig struct {
+	Enabled bool
+	Size    int
+	TTL     time.Duration
+}
+
+// Тип конфигурации базы данных Postgres. Пул соединений настраивается одинаково для основной базы
+// и реплик. ReplicaDSNs - строки подключения к репликам для чтения; чтения пользователя идут
+// в основную базу в течение ReadYourWritesWindow после его собственной записи. При старте
+// подключение и миграции повторяются с задержкой от ConnectBackoffInitial до ConnectBackoffMax,
+// пока не пройдет ConnectTimeout
+type PostgresConfig struct {
+	PostgresPort          string
+	PostgresHost          string
+	DatabaseName          string
+	PostgresUser          string
+	PostgresPassword      string
+	SSLMode               string
+	SSLRootCert           string
+	SSLCert               string
+	SSLKey                string
+	MaxOpenConns          int
+	MaxIdleConns          int
+	ConnMaxLifetime       time.Duration
+	ConnMaxIdleTime       time.Duration
+	StatementTimeout      time.Duration
+	ReplicaDSNs           []string
+	ReadYourWritesWindow  time.Duration
+	ConnectTimeout        time.Duration
+	ConnectBackoffInitial time.Duration
+	ConnectBackoffMax     time.Duration
+}
+
+// Тип конфигурации сервера
+type ServerConfig struct {
+	ServerAddress string
+	ServerPort    string
+	Timeout       time.Duration
+	IdleTimeout   time.Duration
+	RunMode       string
+	JWTSecret     string
+	TokenTTL      time.Duration
+	// TrustedProxies - адреса и подсети прокси, которым доверяется X-Forwarded-For.
+	// Пустой список - заголовок игнорируется и IP клиента берется из соединения
+	TrustedProxies []string
+}
+
+// LogValue описывает конфигурацию для логов при старте сервера. Секреты сюда не попадают
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("env", c.Env.Env),
+		slog.String("server_port", c.Server.ServerPort),
+		slog.String("postgres_port", c.Postgres.PostgresPort),
+		slog.String("postgres_sslmode", c.Postgres.SSLMode),
+		slog.Int("postgres_max_open_conns", c.Postgres.MaxOpenConns),
+		slog.Duration("postgres_statement_timeout", c.Postgres.StatementTimeout),
+		slog.Int("postgres_replicas", len(c.Postgres.ReplicaDSNs)),
+		slog.Duration("postgres_connect_timeout", c.Postgres.ConnectTimeout),
+		slog.String("storage_type", c.Storage.StorageType),
+		slog.String("tracing_exporter", c.Tracing.Exporter),
+		slog.String("log_level", c.Log.Level.String()),
+		slog.String("log_format", c.Log.Format),
+		slog.Int("query_max_complexity_anonymous", c.Query.MaxComplexityAnonymous),
+		slog.Int("query_max_complexity_authenticated", c.Query.MaxComplexityAuthenticated),
+		slog.Int("query_max_depth", c.Query.MaxDepth),
+		slog.Bool("rate_limit_enabled", c.RateLimit.Enabled),
+		slog.Int("apq_cache_size", c.Persisted.CacheSize),
+		slog.Bool("persisted_queries_only", c.Persisted.AllowListOnly),
+		slog.Int("markdown_cache_size", c.Markdown.CacheSize),
+		slog.String("username_pattern", c.Validation.UsernamePattern.String()),
+		slog.Int("post_max_length", c.Validation.PostMaxLength),
+		slog.Int("comment_max_length", c.Validation.CommentMaxLength),
+		slog.Bool("storage_cache_enabled", c.Cache.Enabled),
+		slog.Int("storage_cache_size", c.Cache.Size),
+		slog.Duration("storage_cache_ttl", c.Cache.TTL),
+	)
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Значение, которым заменяются секреты в выводе config print
+const redacted = "[REDACTED]"
+
+// value - итоговое значение настройки и слой, из которого оно взято
+type value struct {
+	raw    string
+	source string
+}
+
+// Loader собирает конфигурацию из слоев, каждый следующий из которых переопределяет предыдущий:
+// значения по умолчанию, файл YAML или TOML, переменные окружения и флаги командной строки.
+// Ошибки всех настроек собираются и возвращаются вместе, а не по одной
+type Loader struct {
+	fs         *flag.FlagSet
+	configPath *string
+	envFile    *string
+	values     map[string]value
+}
+
+// NewLoader регистрирует в fs флаги -config, -env-file и флаг для каждой настройки.
+// Load вызывается после разбора флагов
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{fs: fs}
+	l.configPath = fs.String("config", "", "path to a YAML or TOML config file (env CONFIG_PATH)")
+	l.envFile = fs.String("env-file", "env-files/.env", "dotenv file loaded into the environment if it exists")
+	for _, s := range newConfig().settings() {
+		usage := fmt.Sprintf("%s (env %s", s.usage, s.env)
+		if s.def != "" {
+			usage += ", default " + s.def
+		}
+		fs.String(s.key, "", usage+")")
+	}
+	return l
+}
+
+// Load собирает и проверяет конфигурацию. Если какие-то настройки неверны, возвращается ошибка
+// со списком всех проблем
+func (l *Loader) Load() (*Config, error) {
+	var errs []error
+	// Переменные из .env не заменяют уже заданные переменные окружения
+	if _, err := os.Stat(*l.envFile); err == nil {
+		if err := godotenv.Load(*l.envFile); err != nil {
+			errs = append(errs, fmt.Errorf("env file %s: %w", *l.envFile, err))
+		}
+	}
+
+	cfg := newConfig()
+	settings := cfg.settings()
+	values := make(map[string]value, len(settings))
+	for _, s := range settings {
+		if s.def != "" {
+			values[s.key] = value{raw: s.def, source: "default"}
+		}
+	}
+
+	path := *l.configPath
+	if path == "" {
+		path = os.Getenv("CONFIG_PATH")
+	}
+	if path != "" {
+		fileValues, err := readConfigFile(path, settings)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for key, raw := range fileValues {
+			values[key] = value{raw: raw, source: "file " + path}
+		}
+	}
+
+	for _, s := range settings {
+		if raw := os.Getenv(s.env); raw != "" {
+			values[s.key] = value{raw: raw, source: "env " + s.env}
+		}
+	}
+
+	l.fs.Visit(func(f *flag.Flag) {
+		if slices.ContainsFunc(settings, func(s setting) bool { return s.key == f.Name }) {
+			values[f.Name] = value{raw: f.Value.String(), source: "flag -" + f.Name}
+		}
+	})
+	l.values = values
+
+	for _, s := range settings {
+		v := values[s.key]
+		if v.raw == "" {
+			continue
+		}
+		if err := s.parse(v.raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", s.key, v.source, err))
+		}
+	}
+	// Обязательность некоторых настроек зависит от других, поэтому проверяется после разбора всех
+	for _, s := range settings {
+		if values[s.key].raw == "" && s.required != nil && s.required(cfg) {
+			errs = append(errs, fmt.Errorf("%s: required, set %s or -%s", s.key, s.env, s.key))
+		}
+	}
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	cfg.Env.EnvPath = path
+	return cfg, nil
+}
+
+// Print выводит итоговую конфигурацию последнего вызова Load в формате YAML с источником
+// каждого значения в комментарии. Значения секретов скрываются
+func (l *Loader) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range newConfig().settings() {
+		parent := root
+		parts := strings.Split(s.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			parent = childMapping(parent, part)
+		}
+		v := l.values[s.key]
+		raw := v.raw
+		if s.secret && raw != "" {
+			raw = redacted
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: raw, LineComment: v.source}
+		if strings.Contains(raw, ": ") || strings.Contains(raw, " #") {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}, node)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// childMapping возвращает вложенный раздел с именем name, создавая его при необходимости
+func childMapping(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+	return child
+}
+
+// readConfigFile читает файл конфигурации и возвращает значения настроек по ключам.
+// Формат определяется по расширению файла, неизвестные ключи считаются ошибкой
+func readConfigFile(path string, settings []setting) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	known := make(map[string]struct{}, len(settings))
+	for _, s := range settings {
+		known[s.key] = struct{}{}
+	}
+	values := make(map[string]string)
+	var errs []error
+	flattenConfig("", tree, known, values, &errs)
+	return values, errors.Join(errs...)
+}
+
+// flattenConfig обходит разделы файла и записывает значения настроек под ключами вида section.name
+func flattenConfig(prefix string, tree map[string]any, known map[string]struct{}, values map[string]string, errs *[]error) {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if _, ok := known[key]; ok {
+			raw, err := fileValue(tree[name])
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s (file): %w", key, err))
+				continue
+			}
+			values[key] = raw
+			continue
+		}
+		if section, ok := tree[name].(map[string]any); ok {
+			flattenConfig(key, section, known, values, errs)
+			continue
+		}
+		*errs = append(*errs, fmt.Errorf("%s (file): unknown setting", key))
+	}
+}
+
+// fileValue приводит значение из файла к тому же текстовому виду, что и переменная окружения:
+// списки записываются через запятую, словари - как name=value через запятую
+func fileValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			raw, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, raw)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		pairs := make([]string, 0, len(v))
+		for name, item := range v {
+			raw, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, name+"="+raw)
+		}
+		slices.Sort(pairs)
+		return strings.Join(pairs, ","), nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// load собирает конфигурацию с флагами args. Файл .env по умолчанию не подхватывается
+func load(t *testing.T, args ...string) (*Loader, *Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	require.NoError(t, fs.Parse(append([]string{"-env-file", filepath.Join(t.TempDir(), ".env")}, args...)))
+	cfg, err := loader.Load()
+	return loader, cfg, err
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  jwt_secret: file-secret
+  trusted_proxies: [10.0.0.0/8, 192.168.1.1]
+storage:
+  type: postgres
+postgres:
+  host: file-host
+  port: 5433
+  user: app
+  database: app
+  replica_dsns: [host=r1, host=r2]
+rate_limit:
+  rules:
+    createPost: 1/1s
+`)
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("POSTGRES_HOST", "env-host")
+	t.Setenv("POSTGRES_PASSWORD", "env-password")
+	t.Setenv("POSTGRES_MAX_OPEN_CONNS", "50")
+
+	_, cfg, err := load(t, "-postgres.max_open_conns", "40", "-log.format", "json")
+	require.NoError(t, err)
+
+	// Значение по умолчанию
+	assert.Equal(t, 30*time.Second, cfg.Postgres.StatementTimeout)
+	// Файл переопределяет значения по умолчанию
+	assert.Equal(t, "5433", cfg.Postgres.PostgresPort)
+	assert.Equal(t, []string{"host=r1", "host=r2"}, cfg.Postgres.ReplicaDSNs)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.Server.TrustedProxies)
+	assert.Equal(t, RateLimitRule{Limit: 1, Per: time.Second}, cfg.RateLimit.Rules["createPost"])
+	assert.Equal(t, defaultRateLimitRules["loginUser"], cfg.RateLimit.Rules["loginUser"])
+	// Переменные окружения переопределяют файл, а флаги - переменные окружения
+	assert.Equal(t, "env-host", cfg.Postgres.PostgresHost)
+	assert.Equal(t, 40, cfg.Postgres.MaxOpenConns)
+	assert.Equal(t, "json", cfg.Log.Format)
+	assert.Equal(t, path, cfg.Env.EnvPath)
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[server]
+jwt_secret = "secret"
+
+[storage.cache]
+enabled = true
+size = 100
+`)
+	_, cfg, err := load(t, "-config", path)
+	require.NoError(t, err)
+	assert.True(t, cfg.Cache.Enabled)
+	assert.Equal(t, 100, cfg.Cache.Size)
+	assert.Equal(t, "memory", cfg.Storage.StorageType)
+	assert.Empty(t, cfg.Server.TrustedProxies)
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+storage:
+  type: postgres
+postgres:
+  prot: 5432
+`)
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,proxy")
+	_, cfg, err := load(t, "-config", path, "-postgres.max_idle_conns", "100", "-markdown.cache_size", "-1")
+	require.Error(t, err)
+	assert.Nil(t, cfg)
+	for _, problem := range []string{
+		"postgres.prot (file): unknown setting",
+		"log.level (env LOG_LEVEL)",
+		`server.trusted_proxies (env TRUSTED_PROXIES): "proxy" is neither an IP nor a CIDR`,
+		"markdown.cache_size (flag -markdown.cache_size): can't parse positive integer",
+		"server.jwt_secret: required",
+		"postgres.user: required",
+		"postgres.password: required",
+		"postgres.database: required",
+		"postgres.max_idle_conns: greater than postgres.max_open_conns",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestLoadMemoryDoesNotNeedPostgres(t *testing.T) {
+	_, cfg, err := load(t, "-storage.type", "memory", "-server.jwt_secret", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Storage.StorageType)
+	assert.Empty(t, cfg.Server.TrustedProxies)
+}
+
+func TestLoadZeroDisablesOptionalDurations(t *testing.T) {
+	_, cfg, err := load(t, "-storage.type", "memory", "-server.jwt_secret", "secret",
+		"-postgres.statement_timeout", "0", "-postgres.read_your_writes_window", "0s")
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Postgres.StatementTimeout)
+	assert.Zero(t, cfg.Postgres.ReadYourWritesWindow)
+
+	_, _, err = load(t, "-storage.type", "memory", "-server.jwt_secret", "secret", "-postgres.statement_timeout", "-1s")
+	assert.ErrorContains(t, err, `can't parse non-negative duration "-1s"`)
+	// Обязательные длительности по-прежнему должны быть положительными
+	_, _, err = load(t, "-storage.type", "memory", "-server.jwt_secret", "secret", "-postgres.connect_timeout", "0")
+	assert.ErrorContains(t, err, `can't parse positive duration "0"`)
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Setenv("POSTGRES_PASSWORD", "hunter2")
+	t.Setenv("POSTGRES_REPLICA_DSNS", "host=replica password=hunter3")
+	loader, _, err := load(t, "-server.jwt_secret", "jwt-hunter", "-storage.type", "postgres")
+	require.Error(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, loader.Print(&out))
+	assert.NotContains(t, out.String(), "hunter")
+	assert.Contains(t, out.String(), "password: '"+redacted+"' # env POSTGRES_PASSWORD")
+	assert.Contains(t, out.String(), "jwt_secret: '"+redacted+"' # flag -server.jwt_secret")
+	assert.Contains(t, out.String(), "type: postgres # flag -storage.type")
+	assert.Contains(t, out.String(), "statement_timeout: 30s # default")
+}
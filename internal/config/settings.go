@@ -0,0 +1,308 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting - одна настройка. Ее значение берется по ключу key из файла конфигурации, из переменной
+// окружения env или из флага -key; def - значение по умолчанию в том же текстовом виде.
+// parse разбирает значение в поле конфигурации и вызывается только для непустого значения
+type setting struct {
+	key      string
+	env      string
+	def      string
+	usage    string
+	secret   bool
+	required func(c *Config) bool
+	parse    func(raw string) error
+}
+
+// Ограничения колонок в Postgres, которые не могут превышать настройки проверки ввода
+const (
+	usernameColumnLength = 20
+	commentColumnLength  = 2000
+)
+
+// Правила ограничения частоты мутаций по умолчанию
+var defaultRateLimitRules = map[string]RateLimitRule{
+	"createPost":    {Limit: 10, Per: time.Minute},
+	"updatePost":    {Limit: 30, Per: time.Minute},
+	"deletePost":    {Limit: 30, Per: time.Minute},
+	"createComment": {Limit: 30, Per: time.Minute},
+	"loginUser":     {Limit: 5, Per: time.Minute},
+	"registerUser":  {Limit: 3, Per: time.Minute},
+	"react":         {Limit: 60, Per: time.Minute},
+	"unreact":       {Limit: 60, Per: time.Minute},
+	"follow":        {Limit: 30, Per: time.Minute},
+	"unfollow":      {Limit: 30, Per: time.Minute},
+}
+
+// newConfig создает пустую конфигурацию, в поля которой разбираются настройки
+func newConfig() *Config {
+	rules := make(map[string]RateLimitRule, len(defaultRateLimitRules))
+	for operation, rule := range defaultRateLimitRules {
+		rules[operation] = rule
+	}
+	return &Config{
+		Env:        &EnvConfig{},
+		Postgres:   &PostgresConfig{},
+		Server:     &ServerConfig{},
+		Storage:    &StorageTypeConfig{},
+		Tracing:    &TracingConfig{},
+		Log:        &LogConfig{},
+		Query:      &QueryLimitsConfig{FieldCosts: make(map[string]int)},
+		RateLimit:  &RateLimitConfig{Rules: rules},
+		Persisted:  &PersistedQueriesConfig{},
+		Markdown:   &MarkdownConfig{},
+		Validation: &ValidationConfig{},
+		Cache:      &StorageCacheConfig{},
+	}
+}
+
+// settings перечисляет все настройки приложения в порядке, в котором они выводятся командой config print
+func (c *Config) settings() []setting {
+	withPostgres := func(c *Config) bool { return c.Storage.StorageType == "postgres" }
+	always := func(*Config) bool { return true }
+	return []setting{
+		{key: "env", env: "ENV", def: "local", usage: "environment name, local or prod", parse: stringVar(&c.Env.Env)},
+
+		{key: "server.address", env: "SERVER_ADDR", def: "localhost:8000", usage: "server address", parse: stringVar(&c.Server.ServerAddress)},
+		{key: "server.port", env: "SERVER_PORT", def: "8000", usage: "server port", parse: stringVar(&c.Server.ServerPort)},
+		{key: "server.run_mode", env: "SERVER_RUN_MODE", def: "debug", usage: "gin mode: debug, release or test", parse: oneOfVar(&c.Server.RunMode, "debug", "release", "test")},
+		{key: "server.jwt_secret", env: "JWT_SECRET", usage: "secret for signing tokens", secret: true, required: always, parse: stringVar(&c.Server.JWTSecret)},
+		{key: "server.token_ttl", env: "TOKEN_TTL", def: "72h", usage: "lifetime of issued tokens", parse: durationVar(&c.Server.TokenTTL)},
+		{key: "server.trusted_proxies", env: "TRUSTED_PROXIES", usage: "comma-separated IPs or CIDRs of proxies allowed to set X-Forwarded-For", parse: proxiesVar(&c.Server.TrustedProxies)},
+		{key: "server.timeout", env: "TIMEOUT", def: "4s", usage: "request timeout", parse: durationVar(&c.Server.Timeout)},
+		{key: "server.idle_timeout", env: "IDLE_TIMEOUT", def: "30s", usage: "idle connection timeout", parse: durationVar(&c.Server.IdleTimeout)},
+
+		{key: "storage.type", env: "STORAGE_TYPE", def: "memory", usage: "storage backend: postgres or memory", parse: oneOfVar(&c.Storage.StorageType, "postgres", "memory")},
+		{key: "storage.cache.enabled", env: "STORAGE_CACHE_ENABLED", def: "false", usage: "cache users and posts in memory", parse: boolVar(&c.Cache.Enabled)},
+		{key: "storage.cache.size", env: "STORAGE_CACHE_SIZE", def: "10000", usage: "storage cache size", parse: intVar(&c.Cache.Size)},
+		{key: "storage.cache.ttl", env: "STORAGE_CACHE_TTL", def: "1m", usage: "storage cache entry lifetime", parse: durationVar(&c.Cache.TTL)},
+
+		{key: "postgres.host", env: "POSTGRES_HOST", def: "localhost", usage: "postgres host", parse: stringVar(&c.Postgres.PostgresHost)},
+		{key: "postgres.port", env: "POSTGRES_PORT", def: "5432", usage: "postgres port", parse: stringVar(&c.Postgres.PostgresPort)},
+		{key: "postgres.user", env: "POSTGRES_USER", usage: "postgres user", required: withPostgres, parse: stringVar(&c.Postgres.PostgresUser)},
+		{key: "postgres.password", env: "POSTGRES_PASSWORD", usage: "postgres password", secret: true, required: withPostgres, parse: stringVar(&c.Postgres.PostgresPassword)},
+		{key: "postgres.database", env: "POSTGRES_DB", usage: "postgres database name", required: withPostgres, parse: stringVar(&c.Postgres.DatabaseName)},
+		{key: "postgres.sslmode", env: "POSTGRES_SSLMODE", def: "disable", usage: "disable, require, verify-ca or verify-full", parse: oneOfVar(&c.Postgres.SSLMode, "disable", "require", "verify-ca", "verify-full")},
+		{key: "postgres.sslrootcert", env: "POSTGRES_SSLROOTCERT", usage: "path to the CA certificate", parse: stringVar(&c.Postgres.SSLRootCert)},
+		{key: "postgres.sslcert", env: "POSTGRES_SSLCERT", usage: "path to the client certificate", parse: stringVar(&c.Postgres.SSLCert)},
+		{key: "postgres.sslkey", env: "POSTGRES_SSLKEY", usage: "path to the client key", parse: stringVar(&c.Postgres.SSLKey)},
+		{key: "postgres.max_open_conns", env: "POSTGRES_MAX_OPEN_CONNS", def: "25", usage: "max open connections", parse: intVar(&c.Postgres.MaxOpenConns)},
+		{key: "postgres.max_idle_conns", env: "POSTGRES_MAX_IDLE_CONNS", def: "25", usage: "max idle connections", parse: intVar(&c.Postgres.MaxIdleConns)},
+		{key: "postgres.conn_max_lifetime", env: "POSTGRES_CONN_MAX_LIFETIME", def: "30m", usage: "connection lifetime", parse: durationVar(&c.Postgres.ConnMaxLifetime)},
+		{key: "postgres.conn_max_idle_time", env: "POSTGRES_CONN_MAX_IDLE_TIME", def: "5m", usage: "connection idle time before closing", parse: durationVar(&c.Postgres.ConnMaxIdleTime)},
+		{key: "postgres.statement_timeout", env: "POSTGRES_STATEMENT_TIMEOUT", def: "30s", usage: "statement timeout, 0 disables it", parse: optionalDurationVar(&c.Postgres.StatementTimeout)},
+		{key: "postgres.replica_dsns", env: "POSTGRES_REPLICA_DSNS", usage: "comma-separated read replica DSNs", secret: true, parse: listVar(&c.Postgres.ReplicaDSNs)},
+		{key: "postgres.read_your_writes_window", env: "POSTGRES_READ_YOUR_WRITES_WINDOW", def: "5s", usage: "how long reads of a user go to the primary after their write, 0 disables it", parse: optionalDurationVar(&c.Postgres.ReadYourWritesWindow)},
+		{key: "postgres.connect_timeout", env: "POSTGRES_CONNECT_TIMEOUT", def: "1m", usage: "how long to retry connecting at startup", parse: durationVar(&c.Postgres.ConnectTimeout)},
+		{key: "postgres.connect_backoff_initial", env: "POSTGRES_CONNECT_BACKOFF_INITIAL", def: "500ms", usage: "first connect retry delay", parse: durationVar(&c.Postgres.ConnectBackoffInitial)},
+		{key: "postgres.connect_backoff_max", env: "POSTGRES_CONNECT_BACKOFF_MAX", def: "10s", usage: "max connect retry delay", parse: durationVar(&c.Postgres.ConnectBackoffMax)},
+
+		{key: "tracing.exporter", env: "TRACING_EXPORTER", def: "none", usage: "span exporter: otlp, stdout or none", parse: oneOfVar(&c.Tracing.Exporter, "none", "stdout", "otlp")},
+		{key: "tracing.otlp_endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", usage: "OTLP collector endpoint", parse: stringVar(&c.Tracing.OTLPEndpoint)},
+		{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", def: "graphqlozon", usage: "service name in traces", parse: stringVar(&c.Tracing.ServiceName)},
+
+		{key: "log.level", env: "LOG_LEVEL", def: "info", usage: "debug, info, warn or error", parse: logLevelVar(&c.Log.Level)},
+		{key: "log.format", env: "LOG_FORMAT", def: "text", usage: "text or json", parse: oneOfVar(&c.Log.Format, "text", "json")},
+
+		{key: "query.max_complexity_anonymous", env: "QUERY_MAX_COMPLEXITY_ANONYMOUS", def: "1000", usage: "max query complexity for anonymous users", parse: intVar(&c.Query.MaxComplexityAnonymous)},
+		{key: "query.max_complexity_authenticated", env: "QUERY_MAX_COMPLEXITY_AUTHENTICATED", def: "5000", usage: "max query complexity for authenticated users", parse: intVar(&c.Query.MaxComplexityAuthenticated)},
+		{key: "query.max_depth", env: "QUERY_MAX_DEPTH", def: "10", usage: "max query depth", parse: intVar(&c.Query.MaxDepth)},
+		{key: "query.default_list_size", env: "QUERY_DEFAULT_LIST_SIZE", def: "20", usage: "list size assumed when limit is not set", parse: intVar(&c.Query.DefaultListSize)},
+		{key: "query.field_costs", env: "QUERY_FIELD_COSTS", usage: "field costs as Type.field=cost,...", parse: fieldCostsVar(c.Query.FieldCosts)},
+
+		{key: "rate_limit.enabled", env: "RATE_LIMIT_ENABLED", def: "true", usage: "limit mutation rate", parse: boolVar(&c.RateLimit.Enabled)},
+		{key: "rate_limit.rules", env: "RATE_LIMITS", usage: "rules added to the defaults as operation=limit/duration,...", parse: rateLimitRulesVar(c.RateLimit.Rules)},
+
+		{key: "persisted.cache_size", env: "APQ_CACHE_SIZE", def: "1000", usage: "automatic persisted queries cache size", parse: intVar(&c.Persisted.CacheSize)},
+		{key: "persisted.dir", env: "PERSISTED_QUERIES_DIR", usage: "directory with approved .graphql operations", parse: stringVar(&c.Persisted.AllowListDir)},
+		{key: "persisted.only", env: "PERSISTED_QUERIES_ONLY", def: "false", usage: "execute approved operations only", parse: boolVar(&c.Persisted.AllowListOnly)},
+
+		{key: "markdown.cache_size", env: "MARKDOWN_CACHE_SIZE", def: "10000", usage: "rendered markdown cache size", parse: intVar(&c.Markdown.CacheSize)},
+		{key: "markdown.max_nesting", env: "MARKDOWN_MAX_NESTING", def: "20", usage: "max markdown nesting", parse: intVar(&c.Markdown.MaxNesting)},
+		{key: "markdown.max_table_cells", env: "MARKDOWN_MAX_TABLE_CELLS", def: "1000", usage: "max markdown table cells", parse: intVar(&c.Markdown.MaxTableCells)},
+
+		{key: "validation.username_pattern", env: "USERNAME_PATTERN", def: `^[\p{L}\p{N}_]+$`, usage: "username regular expression", parse: regexpVar(&c.Validation.UsernamePattern)},
+		{key: "validation.username_min_length", env: "USERNAME_MIN_LENGTH", def: "3", usage: "min username length", parse: intVar(&c.Validation.UsernameMinLength)},
+		{key: "validation.username_max_length", env: "USERNAME_MAX_LENGTH", def: strconv.Itoa(usernameColumnLength), usage: "max username length", parse: intVar(&c.Validation.UsernameMaxLength)},
+		{key: "validation.post_max_length", env: "POST_MAX_LENGTH", def: "10000", usage: "max post length", parse: intVar(&c.Validation.PostMaxLength)},
+		{key: "validation.comment_max_length", env: "COMMENT_MAX_LENGTH", def: strconv.Itoa(commentColumnLength), usage: "max comment length", parse: intVar(&c.Validation.CommentMaxLength)},
+	}
+}
+
+// validate проверяет условия, связывающие несколько настроек
+func (c *Config) validate() []error {
+	var errs []error
+	if c.Postgres.MaxIdleConns > c.Postgres.MaxOpenConns {
+		errs = append(errs, errors.New("postgres.max_idle_conns: greater than postgres.max_open_conns"))
+	}
+	if c.Postgres.ConnectBackoffInitial > c.Postgres.ConnectBackoffMax {
+		errs = append(errs, errors.New("postgres.connect_backoff_initial: greater than postgres.connect_backoff_max"))
+	}
+	if c.Persisted.AllowListOnly && c.Persisted.AllowListDir == "" {
+		errs = append(errs, errors.New("persisted.dir: required when persisted.only is set"))
+	}
+	if c.Validation.UsernameMinLength > c.Validation.UsernameMaxLength {
+		errs = append(errs, errors.New("validation.username_min_length: greater than validation.username_max_length"))
+	}
+	if c.Validation.UsernameMaxLength > usernameColumnLength {
+		errs = append(errs, fmt.Errorf("validation.username_max_length: exceeds the database column length %d", usernameColumnLength))
+	}
+	if c.Validation.CommentMaxLength > commentColumnLength {
+		errs = append(errs, fmt.Errorf("validation.comment_max_length: exceeds the database column length %d", commentColumnLength))
+	}
+	return errs
+}
+
+func stringVar(p *string) func(string) error {
+	return func(raw string) error {
+		*p = raw
+		return nil
+	}
+}
+
+func oneOfVar(p *string, allowed ...string) func(string) error {
+	return func(raw string) error {
+		if !slices.Contains(allowed, raw) {
+			return fmt.Errorf("unknown value %q, expected %s", raw, strings.Join(allowed, ", "))
+		}
+		*p = raw
+		return nil
+	}
+}
+
+// intVar разбирает положительное целое
+func intVar(p *int) func(string) error {
+	return func(raw string) error {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("can't parse positive integer %q", raw)
+		}
+		*p = value
+		return nil
+	}
+}
+
+func boolVar(p *bool) func(string) error {
+	return func(raw string) error {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("can't parse boolean %q", raw)
+		}
+		*p = value
+		return nil
+	}
+}
+
+// durationVar разбирает положительную длительность в формате Go, например 30s
+func durationVar(p *time.Duration) func(string) error {
+	return func(raw string) error {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("can't parse positive duration %q", raw)
+		}
+		*p = value
+		return nil
+	}
+}
+
+// optionalDurationVar разбирает неотрицательную длительность, 0 выключает настройку
+func optionalDurationVar(p *time.Duration) func(string) error {
+	return func(raw string) error {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value < 0 {
+			return fmt.Errorf("can't parse non-negative duration %q", raw)
+		}
+		*p = value
+		return nil
+	}
+}
+
+// listVar разбирает список через запятую, пустые элементы пропускаются
+func listVar(p *[]string) func(string) error {
+	return func(raw string) error {
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+// proxiesVar разбирает список IP адресов и подсетей прокси
+func proxiesVar(p *[]string) func(string) error {
+	return func(raw string) error {
+		var proxies []string
+		if err := listVar(&proxies)(raw); err != nil {
+			return err
+		}
+		for _, proxy := range proxies {
+			if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+				return fmt.Errorf("%q is neither an IP nor a CIDR", proxy)
+			}
+		}
+		*p = proxies
+		return nil
+	}
+}
+
+func logLevelVar(p *slog.Level) func(string) error {
+	return func(raw string) error {
+		return p.UnmarshalText([]byte(raw))
+	}
+}
+
+func regexpVar(p **regexp.Regexp) func(string) error {
+	return func(raw string) error {
+		value, err := regexp.Compile(raw)
+		if err != nil {
+			return err
+		}
+		*p = value
+		return nil
+	}
+}
+
+// fieldCostsVar разбирает стоимости полей вида "Query.users=10,User.posts=2"
+func fieldCostsVar(costs map[string]int) func(string) error {
+	return func(raw string) error {
+		for _, pair := range strings.Split(raw, ",") {
+			field, costRaw, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			cost, err := strconv.Atoi(costRaw)
+			if !ok || !strings.Contains(field, ".") || err != nil || cost < 0 {
+				return fmt.Errorf("can't parse field cost %q, expected Type.field=cost", pair)
+			}
+			costs[field] = cost
+		}
+		return nil
+	}
+}
+
+// rateLimitRulesVar разбирает правила вида "createPost=10/1m,createComment=30/1m",
+// которые дополняют правила по умолчанию
+func rateLimitRulesVar(rules map[string]RateLimitRule) func(string) error {
+	return func(raw string) error {
+		for _, pair := range strings.Split(raw, ",") {
+			operation, ruleRaw, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			limitRaw, perRaw, okRule := strings.Cut(ruleRaw, "/")
+			limit, errLimit := strconv.Atoi(limitRaw)
+			per, errPer := time.ParseDuration(perRaw)
+			if !ok || !okRule || errLimit != nil || errPer != nil || limit <= 0 || per <= 0 {
+				return fmt.Errorf("can't parse rate limit %q, expected operation=limit/duration", pair)
+			}
+			rules[operation] = RateLimitRule{Limit: limit, Per: per}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor возвращается, если курсор, присланный клиентом, не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Префикс курсора-смещения
+const offsetPrefix = "offset:"
+
+// MaxOffset - наибольшее смещение в курсоре. Ограничение не дает курсору, собранному вручную,
+// переполнить смещение следующего элемента
+const MaxOffset = math.MaxInt32
+
+// EncodeOffset возвращает непрозрачный курсор, указывающий на элемент с номером offset
+func EncodeOffset(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(offsetPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeOffset разбирает курсор, созданный EncodeOffset. Смещение больше MaxOffset - ошибка ErrInvalidCursor
+func DecodeOffset(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	value, ok := strings.CutPrefix(string(raw), offsetPrefix)
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 || offset > MaxOffset {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
+
+// Key - позиция элемента в списке, упорядоченном по времени создания и ID.
+// Используется для курсорной пагинации по ключу (keyset), которая не сбивается при вставке новых элементов
+type Key struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Префикс курсора-ключа
+const keyPrefix = "key:"
+
+// EncodeKey возвращает непрозрачный курсор, указывающий на элемент с ключом key
+func EncodeKey(key Key) string {
+	raw := keyPrefix + strconv.FormatInt(key.CreatedAt.UnixNano(), 10) + ":" + key.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeKey разбирает курсор, созданный EncodeKey
+func DecodeKey(cursor string) (Key, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Key{}, ErrInvalidCursor
+	}
+	value, ok := strings.CutPrefix(string(raw), keyPrefix)
+	if !ok {
+		return Key{}, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(value, ":")
+	if !ok || id == "" {
+		return Key{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Key{}, ErrInvalidCursor
+	}
+	return Key{CreatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// Less сравнивает ключи по времени, а при равном времени - по ID. В списке от новых
+// к старым элементы, идущие после курсора, - это элементы с меньшим ключом
+func (k Key) Less(other Key) bool {
+	if !k.CreatedAt.Equal(other.CreatedAt) {
+		return k.CreatedAt.Before(other.CreatedAt)
+	}
+	return k.ID < other.ID
+}
@@ -0,0 +1,69 @@
+package hashtag
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Максимальная длина тега в символах
+const MaxLength = 64
+
+// Тег - # и слово из букв, цифр и подчеркиваний. Перед # не должно быть буквы, цифры или &,
+// чтобы не считать тегами якоря ссылок и HTML-сущности вида &#39;
+var tagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{M}\p{N}_]+)`)
+
+// Normalize приводит тег к каноническому виду: без #, в форме Unicode NFKC и в нижнем регистре.
+// Тег должен состоять из букв, цифр и подчеркиваний и содержать хотя бы одну букву, поэтому
+// ссылки вида #123 тегами не считаются. Второе значение false, если тег некорректен
+func Normalize(tag string) (string, bool) {
+	tag = strings.ToLower(norm.NFKC.String(strings.TrimPrefix(tag, "#")))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxLength {
+		return "", false
+	}
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r), unicode.IsMark(r), r == '_':
+		default:
+			return "", false
+		}
+	}
+	return tag, hasLetter
+}
+
+// Parse возвращает нормализованные теги из текста без повторов в порядке первого появления
+func Parse(text string) []string {
+	var tags []string
+	for _, match := range tagPattern.FindAllStringSubmatch(text, -1) {
+		if tag, ok := Normalize(match[1]); ok {
+			tags = appendUnique(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Merge объединяет списки нормализованных тегов без повторов
+func Merge(lists ...[]string) []string {
+	tags := []string{}
+	for _, list := range lists {
+		for _, tag := range list {
+			tags = appendUnique(tags, tag)
+		}
+	}
+	return tags
+}
+
+func appendUnique(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
@@ -0,0 +1,29 @@
+package hashtag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	// Полноширинные символы и регистр приводятся к одной форме, номера и HTML-сущности пропускаются
+	assert.Equal(t, []string{"golang", "graphql", "привет", "go_2"},
+		Parse("#GoLang и #ＧｒａｐｈＱＬ, #Привет #golang #Go_2 issue #123 don&#39;t"))
+	assert.Nil(t, Parse("no tags here, a#b"))
+}
+
+func TestNormalize(t *testing.T) {
+	tag, ok := Normalize("#Café")
+	assert.True(t, ok)
+	assert.Equal(t, "café", tag)
+	// Буква с отдельным диакритическим знаком совпадает с составной после NFKC
+	decomposed, ok := Normalize("café")
+	assert.True(t, ok)
+	assert.Equal(t, tag, decomposed)
+
+	for _, invalid := range []string{"", "#", "42", "with space", "dash-tag"} {
+		_, ok := Normalize(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
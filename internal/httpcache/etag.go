@@ -0,0 +1,70 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETag добавляет к успешным ответам на GET запросы заголовок ETag, вычисленный по телу ответа,
+// и отвечает 304 Not Modified, если клиент прислал тот же ETag в If-None-Match.
+// Запросы на апгрейд до websocket и остальные методы проходят без изменений
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		if buffered.status == http.StatusOK {
+			sum := sha256.Sum256(buffered.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+			if matchesETag(r.Header.Get("If-None-Match"), etag) {
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(buffered.status)
+		_, _ = w.Write(buffered.body.Bytes())
+	})
+}
+
+// matchesETag сравнивает ETag со списком из If-None-Match по слабому сравнению (RFC 9110)
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse накапливает тело ответа, чтобы посчитать ETag до отправки заголовков
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
@@ -0,0 +1,65 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type headerKey struct{}
+
+// WithResponseHeader кладет в контекст заголовки HTTP ответа, в которые расширение запишет Cache-Control
+func WithResponseHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+func responseHeader(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	return header
+}
+
+// Extension - расширение gqlgen, выставляющее заголовок Cache-Control по подсказкам @cacheControl.
+// Мутации, ответы с ошибками и запросы без подсказок не кэшируются, а ответы
+// аутентифицированным пользователям кэшируются только как PRIVATE
+type Extension struct {
+	schema *ast.Schema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = &Extension{}
+
+func (e *Extension) ExtensionName() string {
+	return "CacheControl"
+}
+
+func (e *Extension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema.Schema()
+	return nil
+}
+
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	header := responseHeader(ctx)
+	if header == nil || !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+
+	op := graphql.GetOperationContext(ctx).Operation
+	if op == nil || op.Operation != ast.Query || resp == nil || len(resp.Errors) > 0 {
+		header.Set("Cache-Control", "no-store")
+		return resp
+	}
+
+	policy := Compute(e.schema, op)
+	if middleware.CtxValue(ctx) != nil {
+		policy.Scope = model.CacheControlScopePrivate
+	}
+	header.Set("Cache-Control", policy.Header())
+	return resp
+}
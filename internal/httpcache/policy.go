@@ -0,0 +1,114 @@
+package httpcache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Имя директивы схемы с подсказками кэширования
+const directiveName = "cacheControl"
+
+// Policy - политика кэширования ответа, вычисленная по подсказкам @cacheControl
+type Policy struct {
+	MaxAge int
+	Scope  model.CacheControlScope
+}
+
+// Header возвращает значение заголовка Cache-Control для политики
+func (p Policy) Header() string {
+	if p.MaxAge <= 0 {
+		return "no-store"
+	}
+	return fmt.Sprintf("max-age=%d, %s", p.MaxAge, strings.ToLower(string(p.Scope)))
+}
+
+// Compute вычисляет политику кэширования операции. Для каждого поля берется подсказка
+// с определения поля, иначе с типа, который поле возвращает. Корневые поля и поля
+// составных типов без подсказки запрещают кэширование, скалярные поля наследуют политику родителя.
+// Итоговый maxAge - минимум по всем полям, scope - PRIVATE, если хотя бы одно поле приватное
+func Compute(schema *ast.Schema, op *ast.OperationDefinition) Policy {
+	w := &walker{schema: schema, maxAge: -1, scope: model.CacheControlScopePublic}
+	w.selectionSet(op.SelectionSet, true, map[string]bool{})
+	if w.maxAge < 0 {
+		w.maxAge = 0
+	}
+	return Policy{MaxAge: w.maxAge, Scope: w.scope}
+}
+
+type walker struct {
+	schema *ast.Schema
+	maxAge int
+	scope  model.CacheControlScope
+}
+
+func (w *walker) selectionSet(set ast.SelectionSet, root bool, visiting map[string]bool) {
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") || s.Definition == nil {
+				continue
+			}
+			w.field(s.Definition, root)
+			w.selectionSet(s.SelectionSet, false, visiting)
+		case *ast.InlineFragment:
+			w.selectionSet(s.SelectionSet, root, visiting)
+		case *ast.FragmentSpread:
+			if s.Definition == nil || visiting[s.Name] {
+				continue
+			}
+			visiting[s.Name] = true
+			w.selectionSet(s.Definition.SelectionSet, root, visiting)
+			delete(visiting, s.Name)
+		}
+	}
+}
+
+func (w *walker) field(def *ast.FieldDefinition, root bool) {
+	returnType := w.schema.Types[def.Type.Name()]
+	maxAge, hasMaxAge, private := hint(def.Directives)
+	if returnType != nil {
+		typeMaxAge, typeHasMaxAge, typePrivate := hint(returnType.Directives)
+		if !hasMaxAge {
+			maxAge, hasMaxAge = typeMaxAge, typeHasMaxAge
+		}
+		private = private || typePrivate
+	}
+
+	if private {
+		w.scope = model.CacheControlScopePrivate
+	}
+	composite := returnType != nil && (returnType.Kind == ast.Object || returnType.Kind == ast.Interface || returnType.Kind == ast.Union)
+	switch {
+	case hasMaxAge:
+		w.restrict(maxAge)
+	case root || composite:
+		w.restrict(0)
+	}
+}
+
+func (w *walker) restrict(maxAge int) {
+	if w.maxAge < 0 || maxAge < w.maxAge {
+		w.maxAge = maxAge
+	}
+}
+
+// hint читает аргументы директивы @cacheControl из списка директив
+func hint(directives ast.DirectiveList) (maxAge int, hasMaxAge bool, private bool) {
+	d := directives.ForName(directiveName)
+	if d == nil {
+		return 0, false, false
+	}
+	if arg := d.Arguments.ForName("maxAge"); arg != nil && arg.Value != nil {
+		if v, err := strconv.Atoi(arg.Value.Raw); err == nil {
+			maxAge, hasMaxAge = v, true
+		}
+	}
+	if arg := d.Arguments.ForName("scope"); arg != nil && arg.Value != nil {
+		private = arg.Value.Raw == string(model.CacheControlScopePrivate)
+	}
+	return maxAge, hasMaxAge, private
+}
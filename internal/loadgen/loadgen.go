@@ -0,0 +1,339 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/seed"
+)
+
+// Options - параметры нагрузки
+type Options struct {
+	// URL - адрес GraphQL эндпоинта
+	URL         string
+	Duration    time.Duration
+	Concurrency int
+	// Users - число пользователей seed, от имени которых выполняются запросы. Без пользователей
+	// мутации не выполняются
+	Users    int
+	Password string
+	// Mix - вес каждой операции из DefaultMix, операции без веса не выполняются
+	Mix  map[string]int
+	Seed uint64
+}
+
+// DefaultMix - соотношение операций по умолчанию: в основном чтение ленты и постов с комментариями
+var DefaultMix = map[string]int{
+	"posts":         40,
+	"postsPage":     20,
+	"post":          25,
+	"createPost":    5,
+	"createComment": 7,
+	"react":         3,
+}
+
+// operation - запрос нагрузки. build возвращает текст и переменные запроса или false,
+// если для запроса не хватает данных
+type operation struct {
+	auth  bool
+	build func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool)
+}
+
+// operations - доступные операции. Запросы проверяют разрешение вложенных полей и пагинацию
+var operations = map[string]operation{
+	"posts": {build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		return `query LoadPosts { posts(limit: 10) { id text authorPost { username } comments(limit: 5) { id comment authorComment { username } } } }`, nil, true
+	}},
+	"postsPage": {build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		offset := 0
+		if len(postIDs) > 0 {
+			offset = rnd.IntN(len(postIDs))
+		}
+		return `query LoadPostsPage($offset: Int) { posts(limit: 20, offset: $offset) { id createdAt } }`, map[string]any{"offset": offset}, true
+	}},
+	"post": {build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		if len(postIDs) == 0 {
+			return "", nil, false
+		}
+		return `query LoadPost($id: ID!) { post(id: $id) { id text comments(limit: 20) { id comment replies(limit: 5) { id replies(limit: 5) { id } } } } }`,
+			map[string]any{"id": postIDs[rnd.IntN(len(postIDs))]}, true
+	}},
+	"createPost": {auth: true, build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		return `mutation LoadCreatePost($text: String!) { createPost(text: $text, commentable: true) { id } }`,
+			map[string]any{"text": "load test post " + strconv.FormatUint(rnd.Uint64(), 36)}, true
+	}},
+	"createComment": {auth: true, build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		if len(postIDs) == 0 {
+			return "", nil, false
+		}
+		return `mutation LoadCreateComment($id: ID!, $text: String!) { createComment(comment: $text, itemId: $id) { id } }`,
+			map[string]any{"id": postIDs[rnd.IntN(len(postIDs))], "text": "load test comment " + strconv.FormatUint(rnd.Uint64(), 36)}, true
+	}},
+	"react": {auth: true, build: func(rnd *rand.Rand, postIDs []string) (string, map[string]any, bool) {
+		if len(postIDs) == 0 {
+			return "", nil, false
+		}
+		return `mutation LoadReact($id: ID!) { react(itemId: $id, kind: LIKE) { itemId } }`,
+			map[string]any{"id": postIDs[rnd.IntN(len(postIDs))]}, true
+	}},
+}
+
+// ParseMix разбирает соотношение операций вида posts=40,post=25
+func ParseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("expected operation=weight, got %q", part)
+		}
+		if _, exists := operations[name]; !exists {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight of %s: %q", name, value)
+		}
+		mix[name] = weight
+	}
+	return mix, nil
+}
+
+// Result - задержки одной операции. Count включает ошибки, а перцентили считаются только
+// по успешным запросам: быстрые отказы, например из-за ограничения частоты, занижали бы задержки
+type Result struct {
+	Name   string
+	Count  int
+	Errors int
+	// FirstError - первая ошибка операции, чтобы было видно причину ошибок
+	FirstError string
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// Report - итог нагрузки. Results отсортированы по имени, последним идет total по всем операциям
+type Report struct {
+	Elapsed time.Duration
+	Results []Result
+}
+
+// Run выполняет запросы из Mix в Concurrency потоков, пока не истечет Duration или не отменен ctx.
+// Перед началом входит от имени пользователей seed и запоминает ID существующих постов
+func Run(ctx context.Context, client *http.Client, opts Options) (*Report, error) {
+	if opts.Concurrency < 1 {
+		return nil, errors.New("concurrency must be positive")
+	}
+	c := &gqlClient{http: client, url: opts.URL}
+
+	tokens := make([]string, 0, opts.Users)
+	for i := 0; i < opts.Users; i++ {
+		var data struct {
+			LoginUser struct {
+				Token string `json:"token"`
+			} `json:"loginUser"`
+		}
+		err := c.do(ctx, "", `mutation LoadLogin($username: String!, $password: String!) { loginUser(username: $username, password: $password) { token } }`,
+			map[string]any{"username": seed.Username(i), "password": opts.Password}, &data)
+		if err != nil {
+			return nil, fmt.Errorf("login %s: %w", seed.Username(i), err)
+		}
+		tokens = append(tokens, data.LoginUser.Token)
+	}
+
+	var posts struct {
+		Posts []struct {
+			ID string `json:"id"`
+		} `json:"posts"`
+	}
+	if err := c.do(ctx, "", `query LoadPostIDs { posts(limit: 500) { id } }`, nil, &posts); err != nil {
+		return nil, fmt.Errorf("list posts: %w", err)
+	}
+	postIDs := make([]string, 0, len(posts.Posts))
+	for _, post := range posts.Posts {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	// Мутации без пользователей выполнить нельзя, поэтому они исключаются из смеси
+	names := make([]string, 0, len(opts.Mix))
+	for name, weight := range opts.Mix {
+		if weight > 0 && (!operations[name].auth || len(tokens) > 0) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no operations to run")
+	}
+	sort.Strings(names)
+	cumulative := make([]int, len(names))
+	total := 0
+	for i, name := range names {
+		total += opts.Mix[name]
+		cumulative[i] = total
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+	var (
+		mu        sync.Mutex
+		latencies = make(map[string][]time.Duration)
+		failures  = make(map[string]int)
+		first     = make(map[string]string)
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for worker := 0; worker < opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker uint64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewPCG(opts.Seed, worker))
+			for ctx.Err() == nil {
+				name := names[sort.SearchInts(cumulative, rnd.IntN(total)+1)]
+				query, variables, ok := operations[name].build(rnd, postIDs)
+				if !ok {
+					continue
+				}
+				token := ""
+				if len(tokens) > 0 {
+					token = tokens[rnd.IntN(len(tokens))]
+				}
+				began := time.Now()
+				err := c.do(ctx, token, query, variables, nil)
+				elapsed := time.Since(began)
+				// Запрос, прерванный окончанием нагрузки, не учитывается
+				if ctx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				if err != nil {
+					failures[name]++
+					if first[name] == "" {
+						first[name] = err.Error()
+					}
+				} else {
+					latencies[name] = append(latencies[name], elapsed)
+				}
+				mu.Unlock()
+			}
+		}(uint64(worker))
+	}
+	wg.Wait()
+
+	report := &Report{Elapsed: time.Since(start)}
+	var all []time.Duration
+	allFailures := 0
+	for _, name := range names {
+		all = append(all, latencies[name]...)
+		allFailures += failures[name]
+		result := summarize(name, latencies[name])
+		result.Count += failures[name]
+		result.Errors, result.FirstError = failures[name], first[name]
+		report.Results = append(report.Results, result)
+	}
+	overall := summarize("total", all)
+	overall.Count += allFailures
+	overall.Errors = allFailures
+	report.Results = append(report.Results, overall)
+	return report, nil
+}
+
+// summarize считает перцентили задержек успешных запросов методом ближайшего ранга
+func summarize(name string, latencies []time.Duration) Result {
+	result := Result{Name: name, Count: len(latencies)}
+	if len(latencies) == 0 {
+		return result
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) time.Duration {
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank, 1)-1]
+	}
+	result.P50 = percentile(50)
+	result.P90 = percentile(90)
+	result.P99 = percentile(99)
+	result.Max = sorted[len(sorted)-1]
+	return result
+}
+
+// Write выводит отчет таблицей
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\tcount\terrors\trps\tp50\tp90\tp99\tmax\t")
+	for _, result := range r.Results {
+		rps := float64(result.Count) / r.Elapsed.Seconds()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n", result.Name, result.Count, result.Errors, rps,
+			round(result.P50), round(result.P90), round(result.P99), round(result.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, result := range r.Results {
+		if result.FirstError != "" {
+			fmt.Fprintf(w, "%s: first error: %s\n", result.Name, result.FirstError)
+		}
+	}
+	return nil
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+type gqlClient struct {
+	http *http.Client
+	url  string
+}
+
+// do выполняет запрос и разбирает data в out. Ошибкой считаются ответ не 200 и непустой errors
+func (c *gqlClient) do(ctx context.Context, token, query string, variables map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return err
+	}
+	if len(payload.Errors) > 0 {
+		return errors.New(payload.Errors[0].Message)
+	}
+	if out != nil {
+		return json.Unmarshal(payload.Data, out)
+	}
+	return nil
+}
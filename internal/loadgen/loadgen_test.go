@@ -0,0 +1,95 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizePercentiles(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	result := summarize("posts", latencies)
+	assert.Equal(t, Result{Name: "posts", Count: 100, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}, result)
+
+	assert.Equal(t, Result{Name: "empty"}, summarize("empty", nil))
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("posts=3, post=1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"posts": 3, "post": 1}, mix)
+
+	_, err = ParseMix("unknown=1")
+	assert.EqualError(t, err, `unknown operation "unknown"`)
+	_, err = ParseMix("posts=-1")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	var requests, authorized atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch {
+		case strings.Contains(body.Query, "loginUser"):
+			w.Write([]byte(`{"data":{"loginUser":{"token":"token"}}}`))
+		case strings.Contains(body.Query, "LoadPostIDs"):
+			w.Write([]byte(`{"data":{"posts":[{"id":"p1"},{"id":"p2"}]}}`))
+		case strings.Contains(body.Query, "react"):
+			requests.Add(1)
+			w.Write([]byte(`{"errors":[{"message":"rate limit exceeded"}],"data":null}`))
+		default:
+			requests.Add(1)
+			if r.Header.Get("Authorization") == "Bearer token" {
+				authorized.Add(1)
+			}
+			w.Write([]byte(`{"data":{}}`))
+		}
+	}))
+	defer server.Close()
+
+	report, err := Run(context.Background(), server.Client(), Options{
+		URL:         server.URL,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 2,
+		Users:       1,
+		Password:    "password",
+		Mix:         map[string]int{"post": 1, "react": 1, "createPost": 0},
+		Seed:        1,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Results, 3)
+	post, react, total := report.Results[0], report.Results[1], report.Results[2]
+	assert.Equal(t, "post", post.Name)
+	assert.Zero(t, post.Errors)
+	assert.Equal(t, "react", react.Name)
+	assert.Equal(t, react.Count, react.Errors)
+	// Отказы не попадают в перцентили
+	assert.Zero(t, react.P50)
+	assert.Zero(t, react.Max)
+	assert.Equal(t, "rate limit exceeded", react.FirstError)
+	assert.Equal(t, "total", total.Name)
+	assert.Equal(t, post.Count+react.Count, total.Count)
+	assert.Positive(t, post.Count)
+	// Запросы, прерванные окончанием нагрузки, сервер получил, но в отчет они не попали
+	assert.GreaterOrEqual(t, requests.Load(), int64(total.Count))
+	assert.GreaterOrEqual(t, authorized.Load(), int64(post.Count))
+
+	var out strings.Builder
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "react: first error: rate limit exceeded")
+}
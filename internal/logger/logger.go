@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+)
+
+type ctxKey struct{}
+
+// Значение, которым заменяются секреты в логах
+const redacted = "[REDACTED]"
+
+// Ключи атрибутов, значения которых никогда не попадают в логи
+var secretKeys = map[string]struct{}{
+	"password":      {},
+	"token":         {},
+	"authorization": {},
+	"jwt_secret":    {},
+	"secret":        {},
+	"cookie":        {},
+}
+
+// New создает структурированный логгер с уровнем и форматом из конфигурации.
+// Значения атрибутов с секретными ключами заменяются на [REDACTED]
+func New(w io.Writer, cfg *config.LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       cfg.Level,
+		ReplaceAttr: redact,
+	}
+	if cfg.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// redact скрывает значения секретных атрибутов
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if _, ok := secretKeys[strings.ToLower(attr.Key)]; ok {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// WithContext кладет логгер в контекст запроса
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext достает логгер запроса из контекста. Если логгера нет, возвращается логгер по умолчанию
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, &config.LogConfig{Level: slog.LevelInfo, Format: "json"})
+
+	l.Info("login", "username", "user1", "password", "qwerty", "Authorization", "Bearer abc", slog.Group("user", "token", "abc"))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "user1", entry["username"])
+	assert.Equal(t, redacted, entry["password"])
+	assert.Equal(t, redacted, entry["Authorization"])
+	assert.Equal(t, map[string]any{"token": redacted}, entry["user"])
+	assert.NotContains(t, buf.String(), "qwerty")
+}
+
+func TestNewRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, &config.LogConfig{Level: slog.LevelWarn, Format: "text"})
+
+	l.Info("skipped")
+	assert.Empty(t, buf.String())
+
+	l.Warn("written")
+	assert.Contains(t, buf.String(), "msg=written")
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+
+	l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	assert.Equal(t, l, FromContext(WithContext(context.Background(), l)))
+}
@@ -0,0 +1,142 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Уровень, с которого начинаются заголовки: # отображается как h3, ## как h4 и так далее до h6
+const minHeadingLevel = 3
+
+var (
+	ErrTooDeep       = errors.New("markdown nesting is too deep")
+	ErrTableTooLarge = errors.New("markdown tables have too many cells")
+)
+
+// Limits ограничивает структуру Markdown документа. MaxNesting - максимальная глубина
+// вложенности элементов (цитаты, списки, выделение), MaxTableCells - суммарное число ячеек
+// всех таблиц документа
+type Limits struct {
+	MaxNesting    int
+	MaxTableCells int
+}
+
+// Renderer разбирает Markdown и отрисовывает его в безопасный HTML. Сырой HTML в тексте
+// не выводится, а результат дополнительно проходит через строгий белый список тегов
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	limits Limits
+}
+
+func NewRenderer(limits Limits) *Renderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough,
+		),
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.Prioritized(headingShift{}, 100)),
+		),
+	)
+	return &Renderer{md: md, policy: newPolicy(), limits: limits}
+}
+
+// newPolicy возвращает белый список HTML: только текстовая разметка, таблицы и ссылки
+// http, https и mailto с rel="nofollow". Картинки, скрипты, стили и атрибуты событий удаляются
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "em", "strong", "del", "code", "pre", "blockquote",
+		"ul", "ol", "li", "h3", "h4", "h5", "h6", "table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	return p
+}
+
+// Check разбирает документ и проверяет его по ограничениям рендерера
+func (r *Renderer) Check(source string) error {
+	doc := r.md.Parser().Parse(text.NewReader([]byte(source)))
+	return checkLimits(doc, r.limits)
+}
+
+// Render отрисовывает Markdown в очищенный HTML
+func (r *Renderer) Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return r.policy.Sanitize(buf.String()), nil
+}
+
+// Plain отрисовывает обычный текст: HTML экранируется, пустые строки разделяют абзацы,
+// а одиночные переводы строк становятся <br>
+func Plain(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	var b strings.Builder
+	for _, paragraph := range strings.Split(source, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// checkLimits обходит дерево документа и возвращает ошибку, как только ограничение превышено
+func checkLimits(doc ast.Node, limits Limits) error {
+	depth, cells := 0, 0
+	var limitErr error
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			depth--
+			return ast.WalkContinue, nil
+		}
+		depth++
+		// Сам документ не считается уровнем вложенности
+		if limits.MaxNesting > 0 && depth-1 > limits.MaxNesting {
+			limitErr = ErrTooDeep
+			return ast.WalkStop, nil
+		}
+		if _, ok := n.(*extast.TableCell); ok {
+			cells++
+			if limits.MaxTableCells > 0 && cells > limits.MaxTableCells {
+				limitErr = ErrTableTooLarge
+				return ast.WalkStop, nil
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return limitErr
+}
+
+// headingShift сдвигает уровни заголовков, чтобы текст пользователя не перебивал заголовки страницы
+type headingShift struct{}
+
+func (headingShift) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := n.(*ast.Heading); ok && entering {
+			heading.Level = min(heading.Level+minHeadingLevel-1, 6)
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	r := NewRenderer(Limits{})
+
+	tests := []struct {
+		name     string
+		source   string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "inline formatting",
+			source:   "**bold** _em_ ~~del~~ `code`",
+			contains: []string{"<strong>bold</strong>", "<em>em</em>", "<del>del</del>", "<code>code</code>"},
+		},
+		{
+			name:     "raw html is dropped",
+			source:   "hi <script>alert(1)</script> <b onclick=\"x()\">b</b>",
+			excludes: []string{"<script", "alert(1)</script>", "onclick"},
+		},
+		{
+			name:     "links get nofollow",
+			source:   "[site](https://example.com)",
+			contains: []string{`<a href="https://example.com" rel="nofollow">site</a>`},
+		},
+		{
+			name:     "javascript links are removed",
+			source:   "[x](javascript:alert(1))",
+			excludes: []string{"javascript:", "href"},
+		},
+		{
+			name:     "images are removed",
+			source:   "![alt](https://example.com/a.png)",
+			excludes: []string{"<img"},
+		},
+		{
+			name:     "headings are shifted",
+			source:   "# one\n\n## two\n\n##### five",
+			contains: []string{"<h3>one</h3>", "<h4>two</h4>", "<h6>five</h6>"},
+			excludes: []string{"<h1>", "<h2>"},
+		},
+		{
+			name:     "tables",
+			source:   "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			contains: []string{"<table>", `<th align="left">a</th>`, `<td align="right">2</td>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := r.Render(tt.source)
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, out, s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, out, s)
+			}
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	r := NewRenderer(Limits{MaxNesting: 5, MaxTableCells: 6})
+
+	assert.NoError(t, r.Check("> > quote\n\n| a | b |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |"))
+	assert.ErrorIs(t, r.Check(strings.Repeat("> ", 10)+"deep"), ErrTooDeep)
+	assert.ErrorIs(t, r.Check(strings.Repeat("- ", 10)+"deep"), ErrTooDeep)
+	assert.ErrorIs(t, r.Check("| a | b |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |\n| 5 | 6 |"), ErrTableTooLarge)
+}
+
+func TestPlain(t *testing.T) {
+	assert.Equal(t, "<p>a &lt;b&gt;<br>\nc</p>\n<p>d</p>\n", Plain("a <b>\nc\n\n\nd"))
+	assert.Equal(t, "", Plain("\n\n"))
+}
@@ -0,0 +1,33 @@
+package mention
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// Упоминание - @ и имя пользователя из букв, цифр и подчеркиваний. Перед @ не должно быть
+// буквы или цифры, чтобы адреса почты вида user@example.com не считались упоминаниями
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])(@([\p{L}\p{N}_]+))`)
+
+// Mention - упоминание в тексте. Offset и Length считаются в символах (кодовых точках Unicode)
+// и включают @, поэтому не зависят от того, как клиент хранит строки
+type Mention struct {
+	Username string
+	Offset   int
+	Length   int
+}
+
+// Parse возвращает упоминания в порядке их следования в тексте
+func Parse(text string) []Mention {
+	var mentions []Mention
+	// Позиции регулярного выражения - в байтах, переводим их в символы по ходу текста
+	bytePos, runePos := 0, 0
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+		runePos += utf8.RuneCountInString(text[bytePos:start])
+		length := utf8.RuneCountInString(text[start:end])
+		mentions = append(mentions, Mention{Username: text[match[4]:match[5]], Offset: runePos, Length: length})
+		bytePos, runePos = end, runePos+length
+	}
+	return mentions
+}
@@ -0,0 +1,17 @@
+package mention
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	// Смещения считаются в символах, а не в байтах: кириллица перед упоминанием их не сдвигает
+	assert.Equal(t, []Mention{
+		{Username: "alice", Offset: 0, Length: 6},
+		{Username: "боб", Offset: 15, Length: 4},
+		{Username: "alice", Offset: 30, Length: 6},
+	}, Parse("@alice, привет @боб! mail@x.y @alice"))
+	assert.Nil(t, Parse("no mentions, user@example.com, @@"))
+}
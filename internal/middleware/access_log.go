@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+)
+
+// AccessLog - расширение gqlgen, которое пишет строку лога на каждую GraphQL операцию:
+// тип и имя операции, длительность и количество ошибок. Переменные запроса не логируются,
+// так как в них бывают пароли
+type AccessLog struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = AccessLog{}
+
+func (AccessLog) ExtensionName() string {
+	return "AccessLog"
+}
+
+func (AccessLog) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (AccessLog) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	start := time.Now()
+	resp := next(ctx)
+
+	oc := graphql.GetOperationContext(ctx)
+	opType := ""
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+	}
+	errorsCount := 0
+	if resp != nil {
+		errorsCount = len(resp.Errors)
+	}
+	level := slog.LevelInfo
+	if errorsCount > 0 {
+		level = slog.LevelWarn
+	}
+	logger.FromContext(ctx).LogAttrs(ctx, level, "graphql operation",
+		slog.String("operation_type", opType),
+		slog.String("operation_name", oc.OperationName),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int("errors", errorsCount),
+	)
+	return resp
+}
@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
 	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,19 +17,25 @@ type authString string
 
 var AuthKey = authString("auth")
 
+// DisabledCheck сообщает, заблокирован ли пользователь с данным ID
+type DisabledCheck func(ctx context.Context, userID string) (bool, error)
+
 type AuthMiddleware struct {
 	authService service.AuthService
+	disabled    DisabledCheck
 }
 
-func NewAuthMiddleware(authService service.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: authService}
+// NewAuthMiddleware создает middleware аутентификации. Если disabled задан, токены заблокированных
+// пользователей отклоняются. Если проверить пользователя не удалось, запрос тоже отклоняется:
+// токен заблокированного пользователя не должен пройти из-за недоступного хранилища
+func NewAuthMiddleware(authService service.AuthService, disabled DisabledCheck) *AuthMiddleware {
+	return &AuthMiddleware{authService: authService, disabled: disabled}
 }
 
 // Middleware для аутентификации
 func (a *AuthMiddleware) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
-		fmt.Println("Authorization Header:", auth)
 
 		// Если заголовок пустой, пропускаем запрос дальше
 		if auth == "" {
@@ -44,12 +53,12 @@ func (a *AuthMiddleware) Handler() gin.HandlerFunc {
 
 		// Извлекаем сам токен из заголовка
 		token := auth[len(bearer):]
-		fmt.Println("Token:", token)
 
 		// Валидируем токен с помощью службы JWT
 		validate, err := a.authService.ValidateToken(context.Background(), token)
 		if err != nil || !validate.Valid {
-			fmt.Println("Validation Error:", err)
+			// Сам токен в лог не пишется, только причина отказа
+			logger.FromContext(c.Request.Context()).Debug("invalid bearer token", "error", err)
 			// Если токен недействителен, возвращаем ошибку 403 Forbidden
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid token"})
 			return
@@ -57,15 +66,72 @@ func (a *AuthMiddleware) Handler() gin.HandlerFunc {
 
 		// Извлекаем кастомные claims из токена
 		customClaim, _ := validate.Claims.(*service.JwtCustomClaim)
+		disabled, err := a.isDisabled(c.Request.Context(), customClaim)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check user"})
+			return
+		}
+		if disabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "User is disabled"})
+			return
+		}
 		// Добавляем claims в контекст запроса
-		ctx := context.WithValue(c.Request.Context(), AuthKey, customClaim)
-		c.Request = c.Request.WithContext(ctx)
+		c.Request = c.Request.WithContext(withClaim(c.Request.Context(), customClaim))
 
 		// Пропускаем запрос дальше
 		c.Next()
 	}
 }
 
+// WebsocketInit аутентифицирует websocket соединение по токену из payload connection_init
+// (ключ Authorization, со схемой Bearer или без нее). Если пользователь уже определен по заголовку
+// запроса на открытие соединения, payload не проверяется
+func (a *AuthMiddleware) WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	if CtxValue(ctx) != nil {
+		return ctx, &payload, nil
+	}
+	token := strings.TrimPrefix(payload.Authorization(), "Bearer ")
+	if token == "" {
+		return ctx, &payload, nil
+	}
+	validate, err := a.authService.ValidateToken(ctx, token)
+	if err != nil || !validate.Valid {
+		logger.FromContext(ctx).Debug("invalid websocket token", "error", err)
+		return ctx, nil, errors.New("Invalid token")
+	}
+	customClaim, _ := validate.Claims.(*service.JwtCustomClaim)
+	disabled, err := a.isDisabled(ctx, customClaim)
+	if err != nil {
+		return ctx, nil, errors.New("Failed to check user")
+	}
+	if disabled {
+		return ctx, nil, errors.New("User is disabled")
+	}
+	return withClaim(ctx, customClaim), &payload, nil
+}
+
+// withClaim добавляет claims в контекст. ID пользователя передается и хранилищу:
+// по нему чтения недавно писавшего пользователя идут в основную базу
+func withClaim(ctx context.Context, claim *service.JwtCustomClaim) context.Context {
+	if claim != nil {
+		ctx = storage.WithUserID(ctx, claim.ID)
+	}
+	return context.WithValue(ctx, AuthKey, claim)
+}
+
+// isDisabled проверяет, что владелец токена заблокирован
+func (a *AuthMiddleware) isDisabled(ctx context.Context, claim *service.JwtCustomClaim) (bool, error) {
+	if a.disabled == nil || claim == nil {
+		return false, nil
+	}
+	disabled, err := a.disabled(ctx, claim.ID)
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to check whether the user is disabled", "user_id", claim.ID, "error", err)
+		return false, err
+	}
+	return disabled, nil
+}
+
 // Функция для получения значений из контекста
 func CtxValue(ctx context.Context) *service.JwtCustomClaim {
 	// Извлекаем кастомные claims из контекста
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddlewareDisabledCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := service.NewAuthService("secret", time.Hour)
+	token, err := authService.GenerateToken(context.Background(), "alice")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		disabled DisabledCheck
+		code     int
+		wsErr    string
+	}{
+		{name: "active", disabled: func(context.Context, string) (bool, error) { return false, nil }, code: http.StatusOK},
+		{name: "disabled", disabled: func(context.Context, string) (bool, error) { return true, nil }, code: http.StatusForbidden, wsErr: "User is disabled"},
+		// Если хранилище недоступно, токен не принимается
+		{name: "check failed", disabled: func(context.Context, string) (bool, error) { return false, errors.New("db is down") }, code: http.StatusServiceUnavailable, wsErr: "Failed to check user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuthMiddleware(authService, tt.disabled)
+
+			r := gin.New()
+			r.Use(auth.Handler())
+			r.GET("/", func(c *gin.Context) {
+				assert.Equal(t, "alice", CtxValue(c.Request.Context()).ID)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tt.code, rec.Code)
+
+			ctx, _, err := auth.WebsocketInit(context.Background(), transport.InitPayload{"Authorization": token})
+			if tt.wsErr != "" {
+				assert.EqualError(t, err, tt.wsErr)
+				assert.Nil(t, CtxValue(ctx))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", CtxValue(ctx).ID)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+type clientIPKey struct{}
+
+// ClientIP кладет в контекст запроса IP клиента, определенный gin с учетом доверенных прокси
+func ClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), clientIPKey{}, c.ClientIP())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ClientIPFromContext возвращает IP клиента, сохраненный middleware ClientIP
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"log/slog"
+	"unicode"
+
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Заголовок, в котором клиент передает и получает обратно идентификатор запроса
+const RequestIDHeader = "X-Request-ID"
+
+// Максимальная длина идентификатора запроса, принимаемого от клиента
+const maxRequestIDLength = 128
+
+// RequestID берет идентификатор запроса из заголовка X-Request-ID (или генерирует новый),
+// возвращает его клиенту и кладет в контекст логгер, помеченный этим идентификатором
+func RequestID(base *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		l := base.With("request_id", requestID)
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+			l = l.With("trace_id", spanContext.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), l))
+
+		c.Next()
+	}
+}
+
+// validRequestID проверяет, что присланный клиентом идентификатор можно безопасно писать в логи
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"sync"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Размер буфера канала подписчика. Если клиент не успевает читать уведомления,
+// новые уведомления для него отбрасываются, а не блокируют создание комментариев
+const subscriberBuffer = 16
+
+// Broker рассылает новые уведомления подписчикам внутри процесса. Подписки живут
+// в памяти экземпляра приложения: клиент получает уведомления, созданные тем же экземпляром
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan *model.Notification]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[chan *model.Notification]struct{})}
+}
+
+// Subscribe возвращает канал уведомлений пользователя. Канал закрывается после отмены ctx
+func (b *Broker) Subscribe(ctx context.Context, userID string) <-chan *model.Notification {
+	ch := make(chan *model.Notification, subscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan *model.Notification]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[userID], ch)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// Publish отправляет уведомление всем подпискам получателя
+func (b *Broker) Publish(notification *model.Notification) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[notification.RecipientID] {
+		select {
+		case ch <- notification:
+		default:
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package persisted
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// Код ошибки для операций, которых нет в списке одобренных
+const ErrOperationNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// AllowList - набор одобренных операций, загруженных из .graphql файлов. Операция
+// узнается по sha256 исходного текста (как его считает APQ) или по нормализованному тексту,
+// поэтому различия в пробелах и переносах строк не мешают совпадению
+type AllowList struct {
+	byHash     map[string]string
+	normalized map[string]struct{}
+}
+
+// LoadAllowList читает все .graphql файлы из каталога. Каждый файл - это документ,
+// который клиент отправляет целиком (операции вместе с используемыми фрагментами)
+func LoadAllowList(dir string) (*AllowList, error) {
+	const op = "persisted.LoadAllowList"
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no .graphql files in %s", op, dir)
+	}
+
+	list := &AllowList{
+		byHash:     make(map[string]string, len(files)),
+		normalized: make(map[string]struct{}, len(files)),
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		query := string(content)
+		normalized, err := normalize(query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, file, err)
+		}
+		list.byHash[Hash(query)] = query
+		list.normalized[normalized] = struct{}{}
+	}
+	return list, nil
+}
+
+// Len возвращает количество одобренных документов
+func (a *AllowList) Len() int {
+	return len(a.byHash)
+}
+
+// Allowed сообщает, одобрен ли текст запроса
+func (a *AllowList) Allowed(query string) bool {
+	if _, ok := a.byHash[Hash(query)]; ok {
+		return true
+	}
+	normalized, err := normalize(query)
+	if err != nil {
+		return false
+	}
+	_, ok := a.normalized[normalized]
+	return ok
+}
+
+// Hash считает sha256 запроса так же, как клиенты APQ
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalize приводит документ к каноническому виду через форматтер gqlparser
+func normalize(query string) (string, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf, formatter.WithIndent(" ")).FormatQueryDocument(doc)
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Cache возвращает кэш APQ, в котором одобренные операции доступны по хэшу сразу после старта
+// и никогда не вытесняются. Остальные запросы хранятся в next; если enforce включен,
+// неодобренные запросы в кэш не попадают вовсе
+func (a *AllowList) Cache(next graphql.Cache, enforce bool) graphql.Cache {
+	return &allowListCache{list: a, next: next, enforce: enforce}
+}
+
+type allowListCache struct {
+	list    *AllowList
+	next    graphql.Cache
+	enforce bool
+}
+
+func (c *allowListCache) Get(ctx context.Context, key string) (interface{}, bool) {
+	if query, ok := c.list.byHash[key]; ok {
+		return query, true
+	}
+	return c.next.Get(ctx, key)
+}
+
+func (c *allowListCache) Add(ctx context.Context, key string, value interface{}) {
+	if _, ok := c.list.byHash[key]; ok {
+		return
+	}
+	if query, ok := value.(string); ok && c.enforce && !c.list.Allowed(query) {
+		return
+	}
+	c.next.Add(ctx, key, value)
+}
+
+// Enforcer - расширение gqlgen, отклоняющее любые операции не из списка одобренных.
+// Должно подключаться после AutomaticPersistedQuery, чтобы видеть текст запроса, восстановленный по хэшу
+type Enforcer struct {
+	List *AllowList
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = Enforcer{}
+
+func (e Enforcer) ExtensionName() string {
+	return "PersistedQueryAllowList"
+}
+
+func (e Enforcer) Validate(graphql.ExecutableSchema) error {
+	if e.List == nil {
+		return fmt.Errorf("PersistedQueryAllowList.List can not be nil")
+	}
+	return nil
+}
+
+func (e Enforcer) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	if e.List.Allowed(params.Query) {
+		return nil
+	}
+	err := gqlerror.Errorf("operation is not in the list of approved persisted queries")
+	errcode.Set(err, ErrOperationNotAllowed)
+	return err
+}
@@ -0,0 +1,12 @@
+package persisted
+
+import (
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+)
+
+// NewCache возвращает LRU кэш APQ на size запросов. Любая другая реализация graphql.Cache
+// (например общий кэш в Redis для нескольких реплик) подключается вместо него без изменений в handler
+func NewCache(size int) graphql.Cache {
+	return lru.New(size)
+}
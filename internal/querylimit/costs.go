@@ -0,0 +1,125 @@
+package querylimit
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Аргументы, ограничивающие размер возвращаемого списка
+var pageSizeArgs = []string{"limit", "first"}
+
+// costSchema подменяет расчет сложности полей сгенерированной схемы. Каждое поле стоит
+// 1 (или значение из конфигурации), а стоимость вложенной выборки списка умножается
+// на его размер: аргумент limit, если он передан, иначе размер списка по умолчанию.
+// Для connection-полей (тип *Connection с аргументом first) умножение происходит
+// на самом поле, а список edges внутри повторно не умножается
+type costSchema struct {
+	graphql.ExecutableSchema
+	cfg *config.QueryLimitsConfig
+}
+
+// WithCosts оборачивает исполняемую схему моделью стоимости полей из конфигурации
+func WithCosts(es graphql.ExecutableSchema, cfg *config.QueryLimitsConfig) graphql.ExecutableSchema {
+	return &costSchema{ExecutableSchema: es, cfg: cfg}
+}
+
+func (s *costSchema) Complexity(typeName, field string, childComplexity int, args map[string]interface{}) (int, bool) {
+	def := s.Schema().Types[typeName]
+	if def == nil {
+		return 0, false
+	}
+	fieldDef := def.Fields.ForName(field)
+	if fieldDef == nil {
+		return 0, false
+	}
+
+	cost := 1
+	if fieldCost, ok := s.cfg.FieldCosts[typeName+"."+field]; ok {
+		cost = fieldCost
+	}
+	if isConnectionEdges(typeName, field) || (fieldDef.Type.Elem == nil && !isConnection(fieldDef)) {
+		return safeAdd(cost, childComplexity), true
+	}
+	return safeAdd(cost, safeMul(s.listSize(args), childComplexity)), true
+}
+
+// isConnectionEdges проверяет, что поле - список edges внутри connection-типа
+func isConnectionEdges(typeName, field string) bool {
+	return field == "edges" && strings.HasSuffix(typeName, "Connection")
+}
+
+// isConnection проверяет, что поле возвращает connection-тип и принимает размер страницы
+func isConnection(def *ast.FieldDefinition) bool {
+	if !strings.HasSuffix(def.Type.Name(), "Connection") {
+		return false
+	}
+	for _, name := range pageSizeArgs {
+		if def.Arguments.ForName(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// listSize возвращает ожидаемый размер списка по аргументам поля
+func (s *costSchema) listSize(args map[string]interface{}) int {
+	for _, name := range pageSizeArgs {
+		if size, ok := toInt(args[name]); ok && size >= 0 {
+			return size
+		}
+	}
+	return s.cfg.DefaultListSize
+}
+
+// toInt приводит значение аргумента к int. Литералы запроса приходят как int64,
+// переменные - как int64 или json.Number в зависимости от транспорта
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		if n > math.MaxInt32 {
+			return math.MaxInt32, true
+		}
+		return int(n), true
+	case float64:
+		return int(math.Min(n, math.MaxInt32)), true
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return toInt(i)
+	case *int:
+		if n == nil {
+			return 0, false
+		}
+		return *n, true
+	default:
+		return 0, false
+	}
+}
+
+// safeAdd складывает стоимости без переполнения
+func safeAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// safeMul умножает стоимости без переполнения
+func safeMul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > math.MaxInt/b {
+		return math.MaxInt
+	}
+	return a * b
+}
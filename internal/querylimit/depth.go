@@ -0,0 +1,41 @@
+package querylimit
+
+import (
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Depth считает глубину вложенности полей в выборке. Фрагменты раскрываются,
+// служебные поля интроспекции (__schema, __type, __typename) не учитываются
+func Depth(selectionSet ast.SelectionSet) int {
+	return depth(selectionSet, map[string]bool{})
+}
+
+func depth(selectionSet ast.SelectionSet, visiting map[string]bool) int {
+	maxDepth := 0
+	for _, selection := range selectionSet {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + depth(s.SelectionSet, visiting)
+		case *ast.InlineFragment:
+			d = depth(s.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			// Циклы фрагментов отсекает валидатор, но защищаемся и здесь
+			if s.Definition == nil || visiting[s.Name] {
+				continue
+			}
+			visiting[s.Name] = true
+			d = depth(s.Definition.SelectionSet, visiting)
+			delete(visiting, s.Name)
+		}
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	return maxDepth
+}
@@ -0,0 +1,100 @@
+package querylimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок, возвращаемые в extensions.code
+const (
+	ErrComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+	ErrDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+)
+
+// Limit - расширение gqlgen, отклоняющее операции глубже MaxDepth или сложнее лимита.
+// Лимит сложности для анонимных и аутентифицированных пользователей задается отдельно
+type Limit struct {
+	cfg *config.QueryLimitsConfig
+	es  graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &Limit{}
+
+// Stats - рассчитанные для операции глубина и сложность
+type Stats struct {
+	Depth           int
+	Complexity      int
+	ComplexityLimit int
+}
+
+const extensionName = "QueryLimit"
+
+// NewLimit создает расширение с лимитами из конфигурации. Для учета стоимости полей
+// схему нужно обернуть через WithCosts
+func NewLimit(cfg *config.QueryLimitsConfig) *Limit {
+	return &Limit{cfg: cfg}
+}
+
+func (l *Limit) ExtensionName() string {
+	return extensionName
+}
+
+func (l *Limit) Validate(schema graphql.ExecutableSchema) error {
+	l.es = schema
+	return nil
+}
+
+func (l *Limit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		return nil
+	}
+	authenticated := middleware.CtxValue(ctx) != nil
+
+	stats := &Stats{Depth: Depth(op.SelectionSet)}
+	rc.Stats.SetExtension(extensionName, stats)
+	if stats.Depth > l.cfg.MaxDepth {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", stats.Depth, l.cfg.MaxDepth)
+		errcode.Set(err, ErrDepthLimit)
+		err.Extensions["depth"] = stats.Depth
+		err.Extensions["maxDepth"] = l.cfg.MaxDepth
+		return err
+	}
+
+	stats.Complexity = complexity.Calculate(l.es, op, rc.Variables)
+	stats.ComplexityLimit = l.cfg.MaxComplexityAnonymous
+	if authenticated {
+		stats.ComplexityLimit = l.cfg.MaxComplexityAuthenticated
+	}
+	if stats.Complexity > stats.ComplexityLimit {
+		err := gqlerror.Errorf("operation has complexity %d, which exceeds the limit of %d", stats.Complexity, stats.ComplexityLimit)
+		errcode.Set(err, ErrComplexityLimit)
+		err.Extensions["complexity"] = stats.Complexity
+		err.Extensions["limit"] = stats.ComplexityLimit
+		err.Extensions["authenticated"] = authenticated
+		if !authenticated {
+			err.Message += fmt.Sprintf(" for anonymous callers (authenticated limit is %d)", l.cfg.MaxComplexityAuthenticated)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetStats возвращает глубину и сложность текущей операции
+func GetStats(ctx context.Context) *Stats {
+	if !graphql.HasOperationContext(ctx) {
+		return nil
+	}
+	stats, _ := graphql.GetOperationContext(ctx).Stats.GetExtension(extensionName).(*Stats)
+	return stats
+}
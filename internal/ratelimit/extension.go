@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/VadimRight/GraphQLOzon/internal/middleware"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Код ошибки, возвращаемый в extensions.code при превышении лимита
+const ErrRateLimited = "RATE_LIMITED"
+
+// Extension - расширение gqlgen, ограничивающее частоту вызова мутаций. Ключом корзины
+// служит ID пользователя из JwtCustomClaim, а для анонимных запросов - IP клиента
+type Extension struct {
+	store Store
+	cfg   *config.RateLimitConfig
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = &Extension{}
+
+// NewExtension создает расширение с правилами из конфигурации поверх переданного хранилища корзин
+func NewExtension(store Store, cfg *config.RateLimitConfig) *Extension {
+	return &Extension{store: store, cfg: cfg}
+}
+
+func (e *Extension) ExtensionName() string {
+	return "RateLimit"
+}
+
+func (e *Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e *Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if !e.cfg.Enabled || fc == nil || fc.Object != "Mutation" {
+		return next(ctx)
+	}
+	rule, ok := e.cfg.Rules[fc.Field.Name]
+	if !ok {
+		return next(ctx)
+	}
+
+	result, err := e.store.Take(ctx, fc.Field.Name+":"+callerKey(ctx), rule)
+	if err != nil {
+		// Недоступность хранилища лимитов не должна блокировать пользователей
+		logger.FromContext(ctx).Error("rate limit store failed", "operation", fc.Field.Name, "error", err)
+		return next(ctx)
+	}
+	if !result.Allowed {
+		retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+		gqlErr := gqlerror.ErrorPathf(fc.Path(), "too many %s requests, retry after %d seconds", fc.Field.Name, retryAfter)
+		errcode.Set(gqlErr, ErrRateLimited)
+		gqlErr.Extensions["retryAfter"] = retryAfter
+		gqlErr.Extensions["limit"] = rule.Limit
+		gqlErr.Extensions["period"] = rule.Per.String()
+		return nil, gqlErr
+	}
+	return next(ctx)
+}
+
+// callerKey определяет, чей лимит расходует запрос
+func callerKey(ctx context.Context) string {
+	if claim := middleware.CtxValue(ctx); claim != nil {
+		return "user:" + claim.ID
+	}
+	return "ip:" + middleware.ClientIPFromContext(ctx)
+}
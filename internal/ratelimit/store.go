@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+)
+
+// Result - результат попытки взять токен из корзины
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store хранит состояние корзин токенов. По умолчанию используется MemoryStore внутри процесса,
+// для нескольких реплик сервера интерфейс можно реализовать поверх общего хранилища (например Redis)
+type Store interface {
+	Take(ctx context.Context, key string, rule config.RateLimitRule) (Result, error)
+}
+
+// Как часто из памяти удаляются полные корзины
+const sweepInterval = 10 * time.Minute
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	capacity float64
+	// rate - скорость пополнения в токенах за секунду
+	rate float64
+}
+
+// MemoryStore - потокобезопасное хранилище корзин токенов в памяти процесса
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore возвращает пустое хранилище корзин в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take списывает один токен из корзины key. Корзина вмещает rule.Limit токенов
+// и равномерно пополняется на rule.Limit токенов за rule.Per
+func (s *MemoryStore) Take(ctx context.Context, key string, rule config.RateLimitRule) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(rule.Limit)
+	rate := capacity / rule.Per.Seconds()
+	b, ok := s.buckets[key]
+	if !ok || b.capacity != capacity || b.rate != rate {
+		b = &bucket{tokens: capacity, updated: now, capacity: capacity, rate: rate}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return Result{Allowed: false, RetryAfter: wait}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// sweep удаляет корзины, которые к этому моменту пополнились до конца, чтобы память не росла
+// вместе с числом клиентов. Полная корзина не отличается от новой, поэтому удаление не сбрасывает
+// лимит, даже если rule.Per больше интервала очистки
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.rate >= b.capacity {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	rule := config.RateLimitRule{Limit: 2, Per: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		res, err := store.Take(ctx, "createPost:user:1", rule)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+
+	res, err := store.Take(ctx, "createPost:user:1", rule)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+
+	// Другой ключ расходует свою корзину
+	res, err = store.Take(ctx, "createPost:user:2", rule)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// За 30 секунд корзина пополняется на один токен
+	now = now.Add(30 * time.Second)
+	res, err = store.Take(ctx, "createPost:user:1", rule)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestMemoryStoreSweepsIdleBuckets(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	rule := config.RateLimitRule{Limit: 1, Per: time.Minute}
+
+	_, err := store.Take(context.Background(), "a", rule)
+	require.NoError(t, err)
+	now = now.Add(2 * sweepInterval)
+	_, err = store.Take(context.Background(), "b", rule)
+	require.NoError(t, err)
+
+	assert.NotContains(t, store.buckets, "a")
+	assert.Contains(t, store.buckets, "b")
+}
+
+func TestMemoryStoreKeepsBucketsOfLongPeriods(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	rule := config.RateLimitRule{Limit: 1, Per: 24 * time.Hour}
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "a", rule)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	// Очистка после интервала не сбрасывает корзину, которая еще не пополнилась
+	now = now.Add(2 * sweepInterval)
+	res, err = store.Take(ctx, "a", rule)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
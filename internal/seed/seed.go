@@ -0,0 +1,219 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Options - параметры генерации данных. При одном и том же Seed генерируются одни и те же
+// пользователи, посты и комментарии с одними и теми же ID и временем создания
+type Options struct {
+	Seed  uint64
+	Users int
+	// Posts - общее число постов. Авторы распределяются по степенному закону: автор с рангом k
+	// получает пост с весом 1/k^PostsExponent, поэтому у немногих авторов большая часть постов
+	Posts         int
+	PostsExponent float64
+	// ThreadsPerPost - число комментариев верхнего уровня у каждого поста
+	ThreadsPerPost int
+	// ThreadDepth - максимальная глубина ответов, 0 - комментарии без ответов
+	ThreadDepth int
+	// FanOut - максимальное число ответов на комментарий, фактическое выбирается от 0 до FanOut
+	FanOut int
+	// Password - пароль всех созданных пользователей
+	Password string
+}
+
+// Stats - число созданных объектов, без уже существовавших
+type Stats struct {
+	Users    int
+	Posts    int
+	Comments int
+}
+
+// Username возвращает имя i-го сгенерированного пользователя
+func Username(i int) string {
+	return fmt.Sprintf("user%05d", i)
+}
+
+// Время, от которого отсчитывается время создания сгенерированных объектов
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Run заполняет хранилище сгенерированными данными. Каждый пост сохраняется вместе с
+// комментариями в одной транзакции. Комментарии создаются по уровням, поэтому родитель
+// всегда старше ответа. Объекты с уже существующими ID пропускаются, поэтому повторный
+// запуск с тем же Seed ничего не меняет
+func Run(ctx context.Context, store storage.Storage, opts Options) (Stats, error) {
+	var stats Stats
+	if opts.Users < 0 || opts.Posts < 0 || opts.ThreadsPerPost < 0 || opts.ThreadDepth < 0 || opts.FanOut < 0 {
+		return stats, errors.New("counts must not be negative")
+	}
+	if opts.Posts > 0 && opts.Users == 0 {
+		return stats, errors.New("posts require at least one user")
+	}
+	g := &generator{rnd: rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15))}
+
+	// Минимальная стоимость bcrypt: хэш считается для каждого пользователя, а пароль общий
+	users := make([]string, 0, opts.Users)
+	for i := 0; i < opts.Users; i++ {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.MinCost)
+		if err != nil {
+			return stats, err
+		}
+		user := &model.User{ID: g.id(), Username: Username(i), Password: string(hash), Role: model.UserRoleUser}
+		created, err := store.RestoreUser(ctx, user)
+		if err != nil {
+			return stats, fmt.Errorf("user %s: %w", user.Username, err)
+		}
+		users = append(users, user.ID)
+		if created {
+			stats.Users++
+		}
+	}
+
+	authors := g.powerLaw(users, opts.PostsExponent)
+	for i := 0; i < opts.Posts; i++ {
+		post := &model.Post{
+			ID:          g.id(),
+			Text:        g.text(5, 40),
+			AuthorID:    authors(),
+			Commentable: true,
+			Format:      model.TextFormatPlain,
+			CreatedAt:   epoch.Add(time.Duration(g.rnd.Int64N(int64(365 * 24 * time.Hour)))),
+		}
+		comments := g.threads(post, users, opts)
+		var posts, restored int
+		err := store.WithTx(ctx, func(tx storage.Storage) error {
+			posts, restored = 0, 0
+			created, err := tx.RestorePost(ctx, post)
+			if err != nil {
+				return fmt.Errorf("post %s: %w", post.ID, err)
+			}
+			if created {
+				posts++
+			}
+			for _, comment := range comments {
+				created, err := tx.RestoreComment(ctx, comment)
+				if err != nil {
+					return fmt.Errorf("comment %s: %w", comment.ID, err)
+				}
+				if created {
+					restored++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Posts += posts
+		stats.Comments += restored
+	}
+	return stats, nil
+}
+
+type generator struct {
+	rnd *rand.Rand
+}
+
+// powerLaw возвращает функцию, выбирающую элемент items с весом 1/k^exponent, где k - ранг
+// элемента. Ранги назначаются в случайном порядке, чтобы активность не зависела от имени
+func (g *generator) powerLaw(items []string, exponent float64) func() string {
+	ranked := make([]string, len(items))
+	copy(ranked, items)
+	g.rnd.Shuffle(len(ranked), func(i, j int) { ranked[i], ranked[j] = ranked[j], ranked[i] })
+	cumulative := make([]float64, len(ranked))
+	total := 0.0
+	for k := range ranked {
+		total += 1 / math.Pow(float64(k+1), exponent)
+		cumulative[k] = total
+	}
+	return func() string {
+		i := sort.SearchFloat64s(cumulative, g.rnd.Float64()*total)
+		return ranked[min(i, len(ranked)-1)]
+	}
+}
+
+// threads создает деревья комментариев поста. Время создания растет в порядке обхода по уровням
+func (g *generator) threads(post *model.Post, users []string, opts Options) []*model.CommentResponse {
+	type node struct {
+		comment *model.CommentResponse
+		depth   int
+	}
+	var comments []*model.CommentResponse
+	createdAt := post.CreatedAt
+	add := func(parent *model.CommentResponse) *model.CommentResponse {
+		createdAt = createdAt.Add(time.Duration(1 + g.rnd.Int64N(int64(time.Hour))))
+		comment := &model.CommentResponse{
+			ID:        g.id(),
+			Comment:   g.text(3, 20),
+			AuthorID:  users[g.rnd.IntN(len(users))],
+			PostID:    post.ID,
+			Format:    model.TextFormatPlain,
+			CreatedAt: createdAt,
+		}
+		if parent != nil {
+			comment.ParentCommentID = &parent.ID
+		}
+		comments = append(comments, comment)
+		return comment
+	}
+
+	queue := make([]node, 0, opts.ThreadsPerPost)
+	for i := 0; i < opts.ThreadsPerPost; i++ {
+		queue = append(queue, node{comment: add(nil)})
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.depth >= opts.ThreadDepth {
+			continue
+		}
+		for i := g.rnd.IntN(opts.FanOut + 1); i > 0; i-- {
+			queue = append(queue, node{comment: add(n.comment), depth: n.depth + 1})
+		}
+	}
+	return comments
+}
+
+// id возвращает UUID версии 4 из генератора, поэтому ID повторяются при том же Seed
+func (g *generator) id() string {
+	var b uuid.UUID
+	for i := 0; i < len(b); i += 8 {
+		v := g.rnd.Uint64()
+		for j := 0; j < 8; j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return b.String()
+}
+
+// Слова, из которых составляются тексты постов и комментариев
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor
+	incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco
+	laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit esse
+	cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia
+	deserunt mollit anim id est laborum`)
+
+// text возвращает текст из случайного числа слов от min до max
+func (g *generator) text(min, max int) string {
+	n := min + g.rnd.IntN(max-min+1)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = words[g.rnd.IntN(len(words))]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,84 @@
+package seed
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{Seed: 42, Users: 5, Posts: 10, PostsExponent: 1, ThreadsPerPost: 2, ThreadDepth: 2, FanOut: 2, Password: "password"}
+
+	ids := func() []string {
+		store := storage.NewInMemoryStorage()
+		_, err := Run(ctx, store, opts)
+		require.NoError(t, err)
+		comments, err := store.GetAllComments(ctx, nil, nil, nil)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(comments))
+		for _, comment := range comments {
+			ids = append(ids, comment.ID+comment.CreatedAt.String())
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	assert.Equal(t, ids(), ids())
+}
+
+func TestRunPowerLawAndThreads(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	opts := Options{Seed: 1, Users: 50, Posts: 1000, PostsExponent: 1.2, ThreadsPerPost: 2, ThreadDepth: 3, FanOut: 2, Password: "password"}
+	stats, err := Run(ctx, store, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 50, stats.Users)
+	assert.Equal(t, 1000, stats.Posts)
+
+	// У самого активного автора постов во много раз больше, чем у медианного
+	posts, err := store.GetAllPosts(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	perAuthor := make(map[string]int)
+	for _, post := range posts {
+		perAuthor[post.AuthorID]++
+	}
+	counts := make([]int, 0, opts.Users)
+	for _, user := range mustUsers(t, store) {
+		counts = append(counts, perAuthor[user.ID])
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	assert.Greater(t, counts[0], 10*max(counts[len(counts)/2], 1))
+
+	// Глубина ответов не превышает ThreadDepth, родитель создан раньше ответа
+	comments, err := store.GetAllComments(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Comments, len(comments))
+	byID := make(map[string]*model.CommentResponse, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+	deepest := 0
+	for _, comment := range comments {
+		depth := 0
+		for c := comment; c.ParentCommentID != nil; depth++ {
+			parent := byID[*c.ParentCommentID]
+			require.NotNil(t, parent)
+			assert.True(t, parent.CreatedAt.Before(c.CreatedAt))
+			assert.Equal(t, c.PostID, parent.PostID)
+			c = parent
+		}
+		deepest = max(deepest, depth)
+	}
+	assert.Equal(t, opts.ThreadDepth, deepest)
+}
+
+func mustUsers(t *testing.T, store storage.Storage) []*model.User {
+	users, err := store.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	return users
+}
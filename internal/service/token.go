@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -15,39 +14,33 @@ type JwtCustomClaim struct {
 	jwt.StandardClaims
 }
 
-// Получение JWT секрета из .env
-var jwtSecret = []byte(getJwtSecret())
-
-func getJwtSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "aSecret"
-	}
-	return secret
-}
-
 // AuthService интерфейс для работы с JWT
 type AuthService interface {
 	GenerateToken(ctx context.Context, userID string) (string, error)
 	ValidateToken(ctx context.Context, token string) (*jwt.Token, error)
 }
 
-type authService struct{}
+type authService struct {
+	secret []byte
+	ttl    time.Duration
+}
 
-func NewAuthService() AuthService {
-	return &authService{}
+// NewAuthService создает сервис, который подписывает токены секретом secret.
+// Токен действителен в течение ttl после выдачи
+func NewAuthService(secret string, ttl time.Duration) AuthService {
+	return &authService{secret: []byte(secret), ttl: ttl}
 }
 
 func (s *authService) GenerateToken(ctx context.Context, userID string) (string, error) {
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaim{
 		ID: userID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 72).Unix(),
+			ExpiresAt: time.Now().Add(s.ttl).Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	})
 
-	token, err := t.SignedString(jwtSecret)
+	token, err := t.SignedString(s.secret)
 	if err != nil {
 		return "", err
 	}
@@ -60,6 +53,6 @@ func (s *authService) ValidateToken(ctx context.Context, token string) (*jwt.Tok
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("there's a problem with the signing method")
 		}
-		return jwtSecret, nil
+		return s.secret, nil
 	})
 }
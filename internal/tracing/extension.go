@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/VadimRight/GraphQLOzon/internal/persisted"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/VadimRight/GraphQLOzon/internal/tracing"
+
+// Extension - расширение gqlgen, открывающее спан на каждую GraphQL операцию
+// и дочерний спан на каждое поле, у которого есть резольвер
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "OpenTelemetry"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse открывает спан операции. Если запрос пришел без HTTP-спана,
+// родительский контекст восстанавливается из заголовка traceparent. Текст запроса
+// не записывается, потому что в нем могут быть пароли из loginUser и registerUser,
+// вместо него записывается sha256 запроса, как его считает APQ
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+	if !trace.SpanContextFromContext(ctx).IsValid() && oc.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(oc.Headers))
+	}
+
+	opType := "query"
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+	}
+	name := opType
+	if oc.OperationName != "" {
+		name = fmt.Sprintf("%s %s", opType, oc.OperationName)
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.type", opType),
+			attribute.String("graphql.operation.name", oc.OperationName),
+			attribute.String("graphql.document.sha256", persisted.Hash(oc.RawQuery)),
+		),
+	)
+	defer span.End()
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
+
+// InterceptField открывает спан на вызов резольвера поля. Поля, которые просто
+// читаются из структуры модели, не трассируются, чтобы не раздувать трассу
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || (!fc.IsResolver && !fc.IsMethod) {
+		return next(ctx)
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, fmt.Sprintf("%s.%s", fc.Object, fc.Field.Name),
+		trace.WithAttributes(
+			attribute.String("graphql.field.path", fc.Path().String()),
+			attribute.String("graphql.field.name", fc.Field.Name),
+			attribute.String("graphql.field.object", fc.Object),
+		),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
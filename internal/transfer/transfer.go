@@ -0,0 +1,355 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/hashtag"
+	"github.com/VadimRight/GraphQLOzon/internal/usecase"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+// Version - версия формата выгрузки. Import читает выгрузки версий от 1 до Version
+const Version = 1
+
+// Типы строк выгрузки
+const (
+	TypeHeader  = "header"
+	TypeUser    = "user"
+	TypePost    = "post"
+	TypeComment = "comment"
+)
+
+// Record - одна строка выгрузки в формате JSON Lines. Заполнено поле, соответствующее Type.
+// Первая строка выгрузки - заголовок
+type Record struct {
+	Type    string   `json:"type"`
+	Header  *Header  `json:"header,omitempty"`
+	User    *User    `json:"user,omitempty"`
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// Header - заголовок выгрузки
+type Header struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Passwords - выгрузка содержит хэши паролей
+	Passwords bool `json:"passwords"`
+}
+
+// User - пользователь в выгрузке. Хэш пароля выгружается только по запросу, пользователи
+// без хэша после загрузки не могут войти, пока им не зададут пароль
+type User struct {
+	ID           string         `json:"id"`
+	Username     string         `json:"username"`
+	PasswordHash string         `json:"passwordHash,omitempty"`
+	Role         model.UserRole `json:"role"`
+	Disabled     bool           `json:"disabled"`
+}
+
+// Post - пост в выгрузке. Tags - теги, заданные явно при создании или изменении поста.
+// Теги из текста и упоминания не выгружаются: при загрузке они извлекаются из текста заново
+type Post struct {
+	ID          string           `json:"id"`
+	AuthorID    string           `json:"authorId"`
+	Text        string           `json:"text"`
+	Format      model.TextFormat `json:"format"`
+	Commentable bool             `json:"commentable"`
+	Tags        []string         `json:"tags,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// Comment - комментарий в выгрузке. ParentID задан у ответов на комментарии
+type Comment struct {
+	ID        string           `json:"id"`
+	PostID    string           `json:"postId"`
+	ParentID  *string          `json:"parentId,omitempty"`
+	AuthorID  string           `json:"authorId"`
+	Text      string           `json:"text"`
+	Format    model.TextFormat `json:"format"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// ExportOptions - параметры выгрузки
+type ExportOptions struct {
+	// Passwords - выгружать хэши паролей. Выгрузка с ними позволяет войти любым пользователем
+	// после перебора паролей, поэтому по умолчанию они не выгружаются
+	Passwords bool
+}
+
+// Stats - число выгруженных или загруженных объектов. Skipped - объекты, которые
+// при загрузке уже были в хранилище
+type Stats struct {
+	Users    int
+	Posts    int
+	Comments int
+	Skipped  int
+}
+
+// Export пишет в w заголовок, затем пользователей, посты и комментарии. Объекты читаются
+// из хранилища страницами и сразу пишутся, поэтому выгрузка не держит данные в памяти.
+// Комментарии идут от старых к новым, поэтому родитель записывается раньше ответа.
+// Реакции, подписки и уведомления в выгрузку не входят
+func Export(ctx context.Context, store storage.Storage, w io.Writer, opts ExportOptions) (Stats, error) {
+	var stats Stats
+	enc := json.NewEncoder(w)
+
+	header := Record{Type: TypeHeader, Header: &Header{Version: Version, CreatedAt: time.Now().UTC(), Passwords: opts.Passwords}}
+	if err := enc.Encode(header); err != nil {
+		return stats, err
+	}
+
+	err := store.ScanUsers(ctx, func(user *model.User) error {
+		record := Record{Type: TypeUser, User: &User{ID: user.ID, Username: user.Username, Role: user.Role, Disabled: user.Disabled}}
+		if opts.Passwords {
+			record.User.PasswordHash = user.Password
+		}
+		stats.Users++
+		return enc.Encode(record)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	err = store.ScanPosts(ctx, func(post *model.Post) error {
+		tags, err := store.GetPostExplicitTags(ctx, post.ID)
+		if err != nil {
+			return err
+		}
+		stats.Posts++
+		return enc.Encode(Record{Type: TypePost, Post: &Post{
+			ID:          post.ID,
+			AuthorID:    post.AuthorID,
+			Text:        post.Text,
+			Format:      post.Format,
+			Commentable: post.Commentable,
+			Tags:        tags,
+			CreatedAt:   post.CreatedAt,
+		}})
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	err = store.ScanComments(ctx, func(comment *model.CommentResponse) error {
+		stats.Comments++
+		return enc.Encode(Record{Type: TypeComment, Comment: &Comment{
+			ID:        comment.ID,
+			PostID:    comment.PostID,
+			ParentID:  comment.ParentCommentID,
+			AuthorID:  comment.AuthorID,
+			Text:      comment.Comment,
+			Format:    comment.Format,
+			CreatedAt: comment.CreatedAt,
+		}})
+	})
+	return stats, err
+}
+
+// Максимальная длина строки выгрузки
+const maxLineSize = 1 << 20
+
+// Import загружает выгрузку из r в хранилище, читая ее построчно. Объекты с уже существующими
+// ID пропускаются, поэтому прерванную загрузку можно повторить с начала. Теги и упоминания
+// загруженных постов и комментариев извлекаются из текста так же, как при их создании.
+// Ответ, встреченный раньше родительского комментария, откладывается до загрузки родителя.
+// Ошибка возвращается с номером строки
+func Import(ctx context.Context, store storage.Storage, r io.Reader) (Stats, error) {
+	im := &importer{store: store, pending: make(map[string][]pendingComment)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return im.stats, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := im.restore(ctx, &record, line); err != nil {
+			return im.stats, fmt.Errorf("line %d: %s: %w", line, record.Type, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return im.stats, err
+	}
+	if im.header == nil {
+		return im.stats, errors.New("empty input: header is missing")
+	}
+	return im.stats, im.pendingError()
+}
+
+// pendingError перечисляет по порядку строк ответы, родитель которых так и не встретился.
+// Ответы на такие ответы не перечисляются: их родитель в выгрузке есть
+func (im *importer) pendingError() error {
+	pendingIDs := make(map[string]bool)
+	for _, comments := range im.pending {
+		for _, comment := range comments {
+			pendingIDs[comment.comment.ID] = true
+		}
+	}
+	var orphans []pendingComment
+	for parentID, comments := range im.pending {
+		if !pendingIDs[parentID] {
+			orphans = append(orphans, comments...)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].line < orphans[j].line })
+	errs := make([]error, 0, len(orphans))
+	for _, orphan := range orphans {
+		errs = append(errs, fmt.Errorf("line %d: comment %s: parent comment %s not found", orphan.line, orphan.comment.ID, *orphan.comment.ParentCommentID))
+	}
+	return errors.Join(errs...)
+}
+
+// pendingComment - ответ, ожидающий загрузки родительского комментария
+type pendingComment struct {
+	comment *model.CommentResponse
+	line    int
+}
+
+type importer struct {
+	store  storage.Storage
+	header *Header
+	stats  Stats
+	// pending - отложенные ответы по ID родителя. В памяти держатся только ответы,
+	// встреченные раньше родителя
+	pending map[string][]pendingComment
+}
+
+func (im *importer) restore(ctx context.Context, record *Record, line int) error {
+	if im.header == nil {
+		if record.Type != TypeHeader || record.Header == nil {
+			return errors.New("header is missing")
+		}
+		if record.Header.Version < 1 || record.Header.Version > Version {
+			return fmt.Errorf("unsupported version %d, supported versions are 1 to %d", record.Header.Version, Version)
+		}
+		im.header = record.Header
+		return nil
+	}
+
+	switch {
+	case record.Type == TypeUser && record.User != nil:
+		user := record.User
+		if !user.Role.IsValid() {
+			return fmt.Errorf("unknown role %q", user.Role)
+		}
+		password := user.PasswordHash
+		if password == "" {
+			var err error
+			if password, err = unusablePassword(); err != nil {
+				return err
+			}
+		}
+		created, err := im.store.RestoreUser(ctx, &model.User{ID: user.ID, Username: user.Username, Password: password, Role: user.Role, Disabled: user.Disabled})
+		if err != nil {
+			return err
+		}
+		im.count(created, &im.stats.Users)
+		return nil
+	case record.Type == TypePost && record.Post != nil:
+		post := record.Post
+		var created bool
+		err := im.store.WithTx(ctx, func(tx storage.Storage) error {
+			var err error
+			created, err = tx.RestorePost(ctx, &model.Post{
+				ID:          post.ID,
+				Text:        post.Text,
+				AuthorID:    post.AuthorID,
+				Commentable: post.Commentable,
+				Format:      post.Format,
+				CreatedAt:   post.CreatedAt,
+			})
+			if err != nil || !created {
+				return err
+			}
+			if err := usecase.SaveMentions(ctx, tx, post.ID, post.Text); err != nil {
+				return err
+			}
+			return tx.SetPostTags(ctx, post.ID, hashtag.Parse(post.Text), post.Tags)
+		})
+		if err != nil {
+			return err
+		}
+		im.count(created, &im.stats.Posts)
+		return nil
+	case record.Type == TypeComment && record.Comment != nil:
+		comment := record.Comment
+		return im.restoreComment(ctx, &model.CommentResponse{
+			ID:              comment.ID,
+			Comment:         comment.Text,
+			AuthorID:        comment.AuthorID,
+			PostID:          comment.PostID,
+			ParentCommentID: comment.ParentID,
+			Format:          comment.Format,
+			CreatedAt:       comment.CreatedAt,
+		}, line)
+	}
+	return errors.New("unknown record")
+}
+
+// restoreComment сохраняет комментарий и отложенные ответы на него. Ответ, родитель которого
+// еще не загружен, откладывается
+func (im *importer) restoreComment(ctx context.Context, comment *model.CommentResponse, line int) error {
+	var created bool
+	err := im.store.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		created, err = tx.RestoreComment(ctx, comment)
+		if err != nil || !created {
+			return err
+		}
+		return usecase.SaveMentions(ctx, tx, comment.ID, comment.Comment)
+	})
+	if errors.Is(err, storage.ErrMissingReference) && comment.ParentCommentID != nil {
+		// Не найден может быть и пост или автор, тогда ждать родителя бесполезно
+		parentID := *comment.ParentCommentID
+		if _, parentErr := im.store.GetCommentByID(ctx, parentID); parentErr != nil {
+			im.pending[parentID] = append(im.pending[parentID], pendingComment{comment: comment, line: line})
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	im.count(created, &im.stats.Comments)
+
+	replies := im.pending[comment.ID]
+	delete(im.pending, comment.ID)
+	for _, reply := range replies {
+		if err := im.restoreComment(ctx, reply.comment, reply.line); err != nil {
+			return fmt.Errorf("reply %s from line %d: %w", reply.comment.ID, reply.line, err)
+		}
+	}
+	return nil
+}
+
+func (im *importer) count(created bool, counter *int) {
+	if created {
+		*counter++
+	} else {
+		im.stats.Skipped++
+	}
+}
+
+// unusablePassword возвращает значение длины bcrypt хэша, с которым не совпадает ни один пароль.
+// Значения случайны, потому что в Postgres хэши паролей уникальны
+func unusablePassword() (string, error) {
+	b := make([]byte, 30)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,213 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/internal/seed"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewInMemoryStorage()
+	seeded, err := seed.Run(ctx, source, seed.Options{Seed: 7, Users: 4, Posts: 8, PostsExponent: 1, ThreadsPerPost: 2, ThreadDepth: 2, FanOut: 2, Password: "password"})
+	require.NoError(t, err)
+
+	var first bytes.Buffer
+	exported, err := Export(ctx, source, &first, ExportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Users: seeded.Users, Posts: seeded.Posts, Comments: seeded.Comments}, exported)
+	assert.NotContains(t, first.String(), "passwordHash")
+	assert.True(t, strings.HasPrefix(first.String(), `{"type":"header","header":{"version":1,`))
+
+	target := storage.NewInMemoryStorage()
+	imported, err := Import(ctx, target, bytes.NewReader(first.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, exported, imported)
+
+	// Повторная выгрузка совпадает с первой, кроме времени в заголовке,
+	// значит ID, время создания и ответы сохранились
+	var second bytes.Buffer
+	_, err = Export(ctx, target, &second, ExportOptions{})
+	require.NoError(t, err)
+	firstLines, secondLines := strings.Split(first.String(), "\n"), strings.Split(second.String(), "\n")
+	assert.Equal(t, firstLines[1:], secondLines[1:])
+
+	// Без хэшей пароль восстановленного пользователя не подходит
+	user, err := target.GetUserByUsername(ctx, seed.Username(0))
+	require.NoError(t, err)
+	assert.Error(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password")))
+	// Войти можно после того, как пароль задан заново
+	hash, err := bcrypt.GenerateFromPassword([]byte("new password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = target.SetUserPassword(ctx, user.ID, string(hash))
+	require.NoError(t, err)
+	user, err = target.GetUserByUsername(ctx, seed.Username(0))
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("new password")))
+
+	// Повторная загрузка пропускает все объекты
+	again, err := Import(ctx, target, bytes.NewReader(first.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Skipped: exported.Users + exported.Posts + exported.Comments}, again)
+}
+
+func TestExportPasswords(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewInMemoryStorage()
+	_, err := seed.Run(ctx, source, seed.Options{Seed: 1, Users: 1, Password: "password"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	_, err = Export(ctx, source, &out, ExportOptions{Passwords: true})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `"passwords":true`)
+
+	target := storage.NewInMemoryStorage()
+	_, err = Import(ctx, target, &out)
+	require.NoError(t, err)
+	user, err := target.GetUserByUsername(ctx, seed.Username(0))
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password")))
+}
+
+func TestExportImportTagsAndMentions(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewInMemoryStorage()
+	alice, err := source.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := source.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	_, err = source.CreatePost(ctx, "post-1", "hello #golang @bob", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	require.NoError(t, source.SetPostTags(ctx, "post-1", []string{"golang"}, []string{"news"}))
+	comment, err := source.CreateComment(ctx, "thanks @alice", "post-1", bob.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+	// Реакции и подписки не выгружаются
+	require.NoError(t, source.React(ctx, "post-1", bob.ID, model.ReactionKindLike))
+	require.NoError(t, source.Follow(ctx, bob.ID, alice.ID))
+
+	var out bytes.Buffer
+	_, err = Export(ctx, source, &out, ExportOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `"tags":["news"]`)
+
+	target := storage.NewInMemoryStorage()
+	_, err = Import(ctx, target, &out)
+	require.NoError(t, err)
+
+	tags, err := target.GetPostTags(ctx, "post-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"golang", "news"}, tags)
+	explicit, err := target.GetPostExplicitTags(ctx, "post-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"news"}, explicit)
+
+	mentions, err := target.GetMentions(ctx, "post-1")
+	require.NoError(t, err)
+	require.Len(t, mentions, 1)
+	assert.Equal(t, bob.ID, *mentions[0].UserID)
+	mentions, err = target.GetMentions(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Len(t, mentions, 1)
+	assert.Equal(t, alice.ID, *mentions[0].UserID)
+
+	reactions, err := target.GetReactionCounts(ctx, "post-1")
+	require.NoError(t, err)
+	assert.Empty(t, reactions)
+	followers, err := target.GetFollowers(ctx, alice.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, followers)
+}
+
+func TestImportUserConflict(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewInMemoryStorage()
+	_, err := Import(ctx, store, strings.NewReader(header))
+	require.NoError(t, err)
+
+	renamed := strings.Replace(header, `"username":"alice"`, `"username":"alicia"`, 1)
+	_, err = Import(ctx, store, strings.NewReader(renamed))
+	require.ErrorIs(t, err, storage.ErrRestoreConflict)
+	assert.EqualError(t, err, "line 2: user: object with the same ID differs: user u1 is alice")
+}
+
+const header = `{"type":"header","header":{"version":1,"createdAt":"2024-01-01T00:00:00Z","passwords":false}}
+{"type":"user","user":{"id":"u1","username":"alice","role":"USER"}}
+{"type":"post","post":{"id":"p1","authorId":"u1","text":"hi","format":"PLAIN","createdAt":"2024-01-01T00:00:00Z"}}
+`
+
+func TestImportRepliesBeforeParent(t *testing.T) {
+	ctx := context.Background()
+	input := header + `{"type":"comment","comment":{"id":"c3","postId":"p1","parentId":"c2","authorId":"u1","text":"c","format":"PLAIN","createdAt":"2024-01-01T00:00:03Z"}}
+{"type":"comment","comment":{"id":"c2","postId":"p1","parentId":"c1","authorId":"u1","text":"b","format":"PLAIN","createdAt":"2024-01-01T00:00:02Z"}}
+{"type":"comment","comment":{"id":"c1","postId":"p1","authorId":"u1","text":"a","format":"PLAIN","createdAt":"2024-01-01T00:00:01Z"}}
+`
+	store := storage.NewInMemoryStorage()
+	stats, err := Import(ctx, store, strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Users: 1, Posts: 1, Comments: 3}, stats)
+	reply, err := store.GetCommentByID(ctx, "c3")
+	require.NoError(t, err)
+	assert.Equal(t, "c2", *reply.ParentCommentID)
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "missing header",
+			input: `{"type":"user","user":{"id":"u1","username":"alice","role":"USER"}}`,
+			err:   "line 1: user: header is missing",
+		},
+		{
+			name:  "unsupported version",
+			input: `{"type":"header","header":{"version":2}}`,
+			err:   "line 1: header: unsupported version 2, supported versions are 1 to 1",
+		},
+		{
+			name:  "empty",
+			input: "",
+			err:   "empty input: header is missing",
+		},
+		{
+			name:  "missing author",
+			input: header + `{"type":"post","post":{"id":"p2","authorId":"missing","text":"hi","format":"PLAIN"}}`,
+			err:   "line 4: post: referenced object not found: author missing",
+		},
+		{
+			name:  "missing parent",
+			input: header + `{"type":"comment","comment":{"id":"c2","postId":"p1","parentId":"c1","authorId":"u1","text":"b","format":"PLAIN"}}`,
+			err:   "line 4: comment c2: parent comment c1 not found",
+		},
+		{
+			name: "several missing parents",
+			input: header + `{"type":"comment","comment":{"id":"c4","postId":"p1","parentId":"c3","authorId":"u1","text":"d","format":"PLAIN"}}
+{"type":"comment","comment":{"id":"c3","postId":"p1","parentId":"c1","authorId":"u1","text":"c","format":"PLAIN"}}
+{"type":"comment","comment":{"id":"c2","postId":"p1","parentId":"c0","authorId":"u1","text":"b","format":"PLAIN"}}`,
+			err: "line 5: comment c3: parent comment c1 not found\nline 6: comment c2: parent comment c0 not found",
+		},
+		{
+			name: "missing post of reply",
+			input: header + `{"type":"comment","comment":{"id":"c1","postId":"p1","authorId":"u1","text":"a","format":"PLAIN"}}
+{"type":"comment","comment":{"id":"c2","postId":"p2","parentId":"c1","authorId":"u1","text":"b","format":"PLAIN"}}`,
+			err: "line 5: comment: referenced object not found: post p2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(context.Background(), storage.NewInMemoryStorage(), strings.NewReader(tt.input))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -3,42 +3,76 @@ package usecase
 import (
 	"context"
 
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/VadimRight/GraphQLOzon/storage"
 )
 
 type CommentUsecase interface {
-	GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error)
+	GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error)
 	GetCommentByID(ctx context.Context, id string) (*model.CommentResponse, error)
-	CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error)
-	GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error)
-	GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error)
+	CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error)
+	GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error)
+	GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error)
 }
 
 type commentUsecase struct {
-	storage storage.Storage
+	storage         storage.Storage
+	markdownUsecase MarkdownUsecase
+	validator       *validation.Validator
 }
 
-func NewCommentUsecase(storage storage.Storage) CommentUsecase {
-	return &commentUsecase{storage: storage}
+func NewCommentUsecase(storage storage.Storage, markdownUsecase MarkdownUsecase, validator *validation.Validator) CommentUsecase {
+	return &commentUsecase{storage: storage, markdownUsecase: markdownUsecase, validator: validator}
 }
 
-func (s *commentUsecase) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error) {
-	return s.storage.GetAllComments(ctx, limit, offset)
+func (s *commentUsecase) GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "CommentUsecase.GetAllComments")
+	defer span.End()
+	return s.storage.GetAllComments(ctx, filter, limit, offset)
 }
 
 func (s *commentUsecase) GetCommentByID(ctx context.Context, id string) (*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "CommentUsecase.GetCommentByID")
+	defer span.End()
 	return s.storage.GetCommentByID(ctx, id)
 }
 
-func (s *commentUsecase) CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error) {
-	return s.storage.CreateComment(ctx, commentText, itemId, userID)
+// CreateComment создает комментарий и сохраняет упоминания из его текста в одной транзакции
+func (s *commentUsecase) CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "CommentUsecase.CreateComment")
+	defer span.End()
+	var errs validation.Errors
+	commentText = s.validator.CommentText(&errs, "comment", commentText)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.markdownUsecase.Validate(ctx, format, commentText); err != nil {
+		return nil, err
+	}
+	var comment *model.CommentResponse
+	err := s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		comment, err = tx.CreateComment(ctx, commentText, itemId, userID, format)
+		if err != nil {
+			return err
+		}
+		return SaveMentions(ctx, tx, comment.ID, commentText)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
 }
 
-func (s *commentUsecase) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	return s.storage.GetCommentsByPostID(ctx, postID, limit, offset)
+func (s *commentUsecase) GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "CommentUsecase.GetCommentsByPostID")
+	defer span.End()
+	return s.storage.GetCommentsByPostID(ctx, postID, sort, limit, offset)
 }
 
-func (s *commentUsecase) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	return s.storage.GetCommentsByParentID(ctx, parentID, limit, offset)
+func (s *commentUsecase) GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "CommentUsecase.GetCommentsByParentID")
+	defer span.End()
+	return s.storage.GetCommentsByParentID(ctx, parentID, sort, limit, offset)
 }
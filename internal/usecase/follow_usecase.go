@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+// Размер страницы курсорной пагинации по ключу (подписки, лента, уведомления) по умолчанию и максимальный размер страницы
+const (
+	defaultKeysetPageSize = 20
+	maxKeysetPageSize     = 100
+)
+
+var ErrSelfFollow = errors.New("cannot follow yourself")
+
+type FollowUsecase interface {
+	Follow(ctx context.Context, followerID, followeeID string) (*model.User, error)
+	Unfollow(ctx context.Context, followerID, followeeID string) (*model.User, error)
+	GetFollowers(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error)
+	GetFollowing(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error)
+	GetFeed(ctx context.Context, userID string, first *int, after *string) (*model.PostConnection, error)
+}
+
+type followUsecase struct {
+	storage storage.Storage
+}
+
+func NewFollowUsecase(storage storage.Storage) FollowUsecase {
+	return &followUsecase{storage: storage}
+}
+
+// Follow подписывает followerID на followeeID и возвращает пользователя, на которого оформлена подписка
+func (s *followUsecase) Follow(ctx context.Context, followerID, followeeID string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "FollowUsecase.Follow")
+	defer span.End()
+	if followerID == followeeID {
+		return nil, ErrSelfFollow
+	}
+	var followee *model.User
+	err := s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		followee, err = tx.GetUserByID(ctx, followeeID)
+		if err != nil {
+			return err
+		}
+		return tx.Follow(ctx, followerID, followeeID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return followee, nil
+}
+
+// Unfollow отменяет подписку и возвращает пользователя, от которого отписались
+func (s *followUsecase) Unfollow(ctx context.Context, followerID, followeeID string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "FollowUsecase.Unfollow")
+	defer span.End()
+	var followee *model.User
+	err := s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		followee, err = tx.GetUserByID(ctx, followeeID)
+		if err != nil {
+			return err
+		}
+		return tx.Unfollow(ctx, followerID, followeeID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return followee, nil
+}
+
+func (s *followUsecase) GetFollowers(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error) {
+	ctx, span := tracer.Start(ctx, "FollowUsecase.GetFollowers")
+	defer span.End()
+	return s.userConnection(ctx, s.storage.GetFollowers, userID, first, after)
+}
+
+func (s *followUsecase) GetFollowing(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error) {
+	ctx, span := tracer.Start(ctx, "FollowUsecase.GetFollowing")
+	defer span.End()
+	return s.userConnection(ctx, s.storage.GetFollowing, userID, first, after)
+}
+
+// GetFeed возвращает страницу ленты пользователя - посты тех, на кого он подписан, от новых к старым
+func (s *followUsecase) GetFeed(ctx context.Context, userID string, first *int, after *string) (*model.PostConnection, error) {
+	ctx, span := tracer.Start(ctx, "FollowUsecase.GetFeed")
+	defer span.End()
+	pageSize, afterKey, err := keysetPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+	// Берем на один пост больше, чтобы узнать, есть ли следующая страница
+	posts, err := s.storage.GetFeed(ctx, userID, afterKey, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return postConnection(posts, pageSize), nil
+}
+
+// userConnection собирает страницу подписчиков или подписок
+func (s *followUsecase) userConnection(ctx context.Context, fetch func(context.Context, string, *cursor.Key, int) ([]*model.Follow, error), userID string, first *int, after *string) (*model.UserConnection, error) {
+	pageSize, afterKey, err := keysetPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := fetch(ctx, userID, afterKey, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &model.UserConnection{Edges: []*model.UserEdge{}, PageInfo: &model.PageInfo{}}
+	if len(follows) > pageSize {
+		follows = follows[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, follow := range follows {
+		connection.Edges = append(connection.Edges, &model.UserEdge{
+			Cursor:     cursor.EncodeKey(cursor.Key{CreatedAt: follow.FollowedAt, ID: follow.User.ID}),
+			Node:       follow.User,
+			FollowedAt: follow.FollowedAt,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
+
+// keysetPage разбирает аргументы first и after курсорной пагинации по ключу
+func keysetPage(first *int, after *string) (int, *cursor.Key, error) {
+	pageSize := defaultKeysetPageSize
+	if first != nil {
+		pageSize = min(max(*first, 0), maxKeysetPageSize)
+	}
+	if after == nil {
+		return pageSize, nil, nil
+	}
+	key, err := cursor.DecodeKey(*after)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pageSize, &key, nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/markdown"
+	"github.com/VadimRight/GraphQLOzon/model"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+type MarkdownUsecase interface {
+	Validate(ctx context.Context, format model.TextFormat, source string) error
+	RenderHTML(ctx context.Context, format model.TextFormat, source string) (string, error)
+}
+
+type markdownUsecase struct {
+	renderer *markdown.Renderer
+	cache    *lru.Cache[[sha256.Size]byte, string]
+}
+
+// Размер кэша отрисованного HTML, если в конфигурации задан неположительный
+const defaultMarkdownCacheSize = 10000
+
+// NewMarkdownUsecase создает usecase отрисовки текста. Неположительный размер кэша
+// заменяется размером по умолчанию, потому что LRU кэш нулевого размера создать нельзя
+func NewMarkdownUsecase(cfg *config.MarkdownConfig) MarkdownUsecase {
+	size := cfg.CacheSize
+	if size <= 0 {
+		size = defaultMarkdownCacheSize
+	}
+	cache, err := lru.New[[sha256.Size]byte, string](size)
+	if err != nil {
+		panic(err)
+	}
+	renderer := markdown.NewRenderer(markdown.Limits{MaxNesting: cfg.MaxNesting, MaxTableCells: cfg.MaxTableCells})
+	return &markdownUsecase{renderer: renderer, cache: cache}
+}
+
+// Validate проверяет, что текст в формате format можно сохранить. Обычный текст принимается
+// любым, а Markdown разбирается и проверяется по ограничениям на вложенность и таблицы
+func (s *markdownUsecase) Validate(ctx context.Context, format model.TextFormat, source string) error {
+	_, span := tracer.Start(ctx, "MarkdownUsecase.Validate")
+	defer span.End()
+	switch format {
+	case model.TextFormatPlain:
+		return nil
+	case model.TextFormatMarkdown:
+		return s.renderer.Check(source)
+	}
+	return fmt.Errorf("%s is not a valid TextFormat", format)
+}
+
+// RenderHTML возвращает очищенный HTML текста. Результат кэшируется по хэшу формата и текста,
+// поэтому одинаковый текст отрисовывается один раз, а отредактированный получает новую запись
+func (s *markdownUsecase) RenderHTML(ctx context.Context, format model.TextFormat, source string) (string, error) {
+	_, span := tracer.Start(ctx, "MarkdownUsecase.RenderHTML")
+	defer span.End()
+	key := sha256.Sum256([]byte(string(format) + "\x00" + source))
+	if html, ok := s.cache.Get(key); ok {
+		return html, nil
+	}
+
+	var html string
+	switch format {
+	case model.TextFormatMarkdown:
+		rendered, err := s.renderer.Render(source)
+		if err != nil {
+			return "", err
+		}
+		html = rendered
+	default:
+		html = markdown.Plain(source)
+	}
+	s.cache.Add(key, html)
+	return html, nil
+}
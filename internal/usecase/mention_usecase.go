@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/internal/mention"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+type MentionUsecase interface {
+	GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error)
+	GetMentionedIn(ctx context.Context, userID string, first *int, after *string) (*model.MentionConnection, error)
+}
+
+type mentionUsecase struct {
+	storage storage.Storage
+}
+
+func NewMentionUsecase(storage storage.Storage) MentionUsecase {
+	return &mentionUsecase{storage: storage}
+}
+
+func (s *mentionUsecase) GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error) {
+	ctx, span := tracer.Start(ctx, "MentionUsecase.GetMentions")
+	defer span.End()
+	return s.storage.GetMentions(ctx, itemID)
+}
+
+// GetMentionedIn возвращает страницу постов и комментариев с упоминанием пользователя от новых к старым
+func (s *mentionUsecase) GetMentionedIn(ctx context.Context, userID string, first *int, after *string) (*model.MentionConnection, error) {
+	ctx, span := tracer.Start(ctx, "MentionUsecase.GetMentionedIn")
+	defer span.End()
+	pageSize, afterKey, err := keysetPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+	// Берем на один объект больше, чтобы узнать, есть ли следующая страница
+	nodes, err := s.storage.GetMentionedIn(ctx, userID, afterKey, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &model.MentionConnection{Edges: []*model.MentionEdge{}, PageInfo: &model.PageInfo{}}
+	if len(nodes) > pageSize {
+		nodes = nodes[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, node := range nodes {
+		var key cursor.Key
+		switch n := node.(type) {
+		case *model.Post:
+			key = cursor.Key{CreatedAt: n.CreatedAt, ID: n.ID}
+		case *model.CommentResponse:
+			key = cursor.Key{CreatedAt: n.CreatedAt, ID: n.ID}
+		}
+		connection.Edges = append(connection.Edges, &model.MentionEdge{Cursor: cursor.EncodeKey(key), Node: node})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
+
+// SaveMentions находит упоминания в тексте поста или комментария и сохраняет те, что указывают
+// на существующих пользователей. Упоминания несуществующих пользователей остаются обычным текстом
+func SaveMentions(ctx context.Context, storage storage.Storage, itemID, text string) error {
+	mentions := []*model.Mention{}
+	for _, m := range mention.Parse(text) {
+		user, err := storage.GetUserByUsername(ctx, m.Username)
+		if err != nil {
+			continue
+		}
+		mentions = append(mentions, &model.Mention{UserID: &user.ID, Offset: m.Offset, Length: m.Length})
+	}
+	return storage.SaveMentions(ctx, itemID, mentions)
+}
@@ -11,8 +11,8 @@ type MockCommentUsecase struct {
 	mock.Mock
 }
 
-func (m *MockCommentUsecase) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockCommentUsecase) GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error) {
+	args := m.Called(ctx, filter, limit, offset)
 	return args.Get(0).([]*model.CommentResponse), args.Error(1)
 }
 
@@ -21,17 +21,17 @@ func (m *MockCommentUsecase) GetCommentByID(ctx context.Context, id string) (*mo
 	return args.Get(0).(*model.CommentResponse), args.Error(1)
 }
 
-func (m *MockCommentUsecase) CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error) {
-	args := m.Called(ctx, commentText, itemId, userID)
+func (m *MockCommentUsecase) CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error) {
+	args := m.Called(ctx, commentText, itemId, userID, format)
 	return args.Get(0).(*model.CommentResponse), args.Error(1)
 }
 
-func (m *MockCommentUsecase) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	args := m.Called(ctx, postID, limit, offset)
+func (m *MockCommentUsecase) GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	args := m.Called(ctx, postID, sort, limit, offset)
 	return args.Get(0).([]*model.CommentResponse), args.Error(1)
 }
 
-func (m *MockCommentUsecase) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	args := m.Called(ctx, parentID, limit, offset)
+func (m *MockCommentUsecase) GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	args := m.Called(ctx, parentID, sort, limit, offset)
 	return args.Get(0).([]*model.CommentResponse), args.Error(1)
 }
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFollowUsecase struct {
+	mock.Mock
+}
+
+func (m *MockFollowUsecase) Follow(ctx context.Context, followerID, followeeID string) (*model.User, error) {
+	args := m.Called(ctx, followerID, followeeID)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockFollowUsecase) Unfollow(ctx context.Context, followerID, followeeID string) (*model.User, error) {
+	args := m.Called(ctx, followerID, followeeID)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockFollowUsecase) GetFollowers(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error) {
+	args := m.Called(ctx, userID, first, after)
+	return args.Get(0).(*model.UserConnection), args.Error(1)
+}
+
+func (m *MockFollowUsecase) GetFollowing(ctx context.Context, userID string, first *int, after *string) (*model.UserConnection, error) {
+	args := m.Called(ctx, userID, first, after)
+	return args.Get(0).(*model.UserConnection), args.Error(1)
+}
+
+func (m *MockFollowUsecase) GetFeed(ctx context.Context, userID string, first *int, after *string) (*model.PostConnection, error) {
+	args := m.Called(ctx, userID, first, after)
+	return args.Get(0).(*model.PostConnection), args.Error(1)
+}
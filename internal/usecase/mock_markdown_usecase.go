@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMarkdownUsecase struct {
+	mock.Mock
+}
+
+func (m *MockMarkdownUsecase) Validate(ctx context.Context, format model.TextFormat, source string) error {
+	args := m.Called(ctx, format, source)
+	return args.Error(0)
+}
+
+func (m *MockMarkdownUsecase) RenderHTML(ctx context.Context, format model.TextFormat, source string) (string, error) {
+	args := m.Called(ctx, format, source)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMentionUsecase struct {
+	mock.Mock
+}
+
+func (m *MockMentionUsecase) GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error) {
+	args := m.Called(ctx, itemID)
+	return args.Get(0).([]*model.Mention), args.Error(1)
+}
+
+func (m *MockMentionUsecase) GetMentionedIn(ctx context.Context, userID string, first *int, after *string) (*model.MentionConnection, error) {
+	args := m.Called(ctx, userID, first, after)
+	return args.Get(0).(*model.MentionConnection), args.Error(1)
+}
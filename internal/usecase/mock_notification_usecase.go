@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockNotificationUsecase struct {
+	mock.Mock
+}
+
+func (m *MockNotificationUsecase) CommentCreated(ctx context.Context, comment *model.CommentResponse) error {
+	args := m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *MockNotificationUsecase) PostCreated(ctx context.Context, post *model.Post) error {
+	args := m.Called(ctx, post)
+	return args.Error(0)
+}
+
+func (m *MockNotificationUsecase) GetNotifications(ctx context.Context, userID string, first *int, after *string, unreadOnly bool) (*model.NotificationConnection, error) {
+	args := m.Called(ctx, userID, first, after, unreadOnly)
+	return args.Get(0).(*model.NotificationConnection), args.Error(1)
+}
+
+func (m *MockNotificationUsecase) CountUnread(ctx context.Context, userID string) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationUsecase) MarkRead(ctx context.Context, userID string, ids []string) (int, error) {
+	args := m.Called(ctx, userID, ids)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationUsecase) Subscribe(ctx context.Context, userID string) <-chan *model.Notification {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(<-chan *model.Notification)
+}
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/stretchr/testify/mock"
@@ -11,11 +12,36 @@ type MockPostUsecase struct {
 	mock.Mock
 }
 
-func (m *MockPostUsecase) CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error) {
-	args := m.Called(ctx, id, text, authorID, commentable)
+func (m *MockPostUsecase) CreatePost(ctx context.Context, id, text, authorID string, commentable bool, tags []string, format model.TextFormat) (*model.Post, error) {
+	args := m.Called(ctx, id, text, authorID, commentable, tags, format)
 	return args.Get(0).(*model.Post), args.Error(1)
 }
 
+func (m *MockPostUsecase) UpdatePost(ctx context.Context, id, userID string, text *string, commentable *bool, tags []string, format *model.TextFormat) (*model.Post, error) {
+	args := m.Called(ctx, id, userID, text, commentable, tags, format)
+	return args.Get(0).(*model.Post), args.Error(1)
+}
+
+func (m *MockPostUsecase) DeletePost(ctx context.Context, id, userID string) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+func (m *MockPostUsecase) GetPostTags(ctx context.Context, postID string) ([]string, error) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPostUsecase) GetPostsByTag(ctx context.Context, tag string, first *int, after *string) (*model.PostConnection, error) {
+	args := m.Called(ctx, tag, first, after)
+	return args.Get(0).(*model.PostConnection), args.Error(1)
+}
+
+func (m *MockPostUsecase) GetTrendingTags(ctx context.Context, window time.Duration, limit *int) ([]*model.TrendingTag, error) {
+	args := m.Called(ctx, window, limit)
+	return args.Get(0).([]*model.TrendingTag), args.Error(1)
+}
+
 func (m *MockPostUsecase) GetPostByID(ctx context.Context, id string) (*model.Post, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*model.Post), args.Error(1)
@@ -26,7 +52,7 @@ func (m *MockPostUsecase) GetPostsByUserID(ctx context.Context, userID string, l
 	return args.Get(0).([]*model.Post), args.Error(1)
 }
 
-func (m *MockPostUsecase) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	args := m.Called(ctx, limit, offset)
+func (m *MockPostUsecase) GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	args := m.Called(ctx, filter, limit, offset)
 	return args.Get(0).([]*model.Post), args.Error(1)
 }
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReactionUsecase struct {
+	mock.Mock
+}
+
+func (m *MockReactionUsecase) React(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	args := m.Called(ctx, itemID, userID, kind)
+	return args.Get(0).(*model.ReactionSummary), args.Error(1)
+}
+
+func (m *MockReactionUsecase) Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	args := m.Called(ctx, itemID, userID, kind)
+	return args.Get(0).(*model.ReactionSummary), args.Error(1)
+}
+
+func (m *MockReactionUsecase) GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error) {
+	args := m.Called(ctx, itemID)
+	return args.Get(0).([]*model.ReactionCount), args.Error(1)
+}
+
+func (m *MockReactionUsecase) GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error) {
+	args := m.Called(ctx, itemID, userID)
+	return args.Get(0).([]model.ReactionKind), args.Error(1)
+}
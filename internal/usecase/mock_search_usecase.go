@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSearchUsecase struct {
+	mock.Mock
+}
+
+func (m *MockSearchUsecase) Search(ctx context.Context, query string, types []model.SearchType, first *int, after *string) (*model.SearchConnection, error) {
+	args := m.Called(ctx, query, types, first, after)
+	return args.Get(0).(*model.SearchConnection), args.Error(1)
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/internal/notify"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+type NotificationUsecase interface {
+	CommentCreated(ctx context.Context, comment *model.CommentResponse) error
+	PostCreated(ctx context.Context, post *model.Post) error
+	GetNotifications(ctx context.Context, userID string, first *int, after *string, unreadOnly bool) (*model.NotificationConnection, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID string, ids []string) (int, error)
+	Subscribe(ctx context.Context, userID string) <-chan *model.Notification
+}
+
+type notificationUsecase struct {
+	storage storage.Storage
+	broker  *notify.Broker
+}
+
+func NewNotificationUsecase(storage storage.Storage, broker *notify.Broker) NotificationUsecase {
+	return &notificationUsecase{storage: storage, broker: broker}
+}
+
+// CommentCreated уведомляет автора поста о новом комментарии (или автора комментария об ответе)
+// и пользователей, упомянутых в тексте комментария
+func (s *notificationUsecase) CommentCreated(ctx context.Context, comment *model.CommentResponse) error {
+	ctx, span := tracer.Start(ctx, "NotificationUsecase.CommentCreated")
+	defer span.End()
+
+	n := newNotifications(comment.AuthorID, comment.PostID, &comment.ID)
+	if comment.ParentCommentID != nil {
+		parent, err := s.storage.GetCommentByID(ctx, *comment.ParentCommentID)
+		if err != nil {
+			return err
+		}
+		n.add(parent.AuthorID, model.NotificationKindReply)
+	} else {
+		post, err := s.storage.GetPostByID(ctx, comment.PostID)
+		if err != nil {
+			return err
+		}
+		n.add(post.AuthorID, model.NotificationKindComment)
+	}
+	if err := s.addMentions(ctx, n, comment.ID); err != nil {
+		return err
+	}
+	return s.save(ctx, n.list)
+}
+
+// PostCreated уведомляет пользователей, упомянутых в тексте поста
+func (s *notificationUsecase) PostCreated(ctx context.Context, post *model.Post) error {
+	ctx, span := tracer.Start(ctx, "NotificationUsecase.PostCreated")
+	defer span.End()
+
+	n := newNotifications(post.AuthorID, post.ID, nil)
+	if err := s.addMentions(ctx, n, post.ID); err != nil {
+		return err
+	}
+	return s.save(ctx, n.list)
+}
+
+// GetNotifications возвращает страницу уведомлений пользователя от новых к старым
+func (s *notificationUsecase) GetNotifications(ctx context.Context, userID string, first *int, after *string, unreadOnly bool) (*model.NotificationConnection, error) {
+	ctx, span := tracer.Start(ctx, "NotificationUsecase.GetNotifications")
+	defer span.End()
+	pageSize, afterKey, err := keysetPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+	// Берем на одно уведомление больше, чтобы узнать, есть ли следующая страница
+	notifications, err := s.storage.GetNotifications(ctx, userID, unreadOnly, afterKey, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &model.NotificationConnection{Edges: []*model.NotificationEdge{}, PageInfo: &model.PageInfo{}}
+	if len(notifications) > pageSize {
+		notifications = notifications[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, notification := range notifications {
+		connection.Edges = append(connection.Edges, &model.NotificationEdge{
+			Cursor: cursor.EncodeKey(cursor.Key{CreatedAt: notification.CreatedAt, ID: notification.ID}),
+			Node:   notification,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
+
+func (s *notificationUsecase) CountUnread(ctx context.Context, userID string) (int, error) {
+	ctx, span := tracer.Start(ctx, "NotificationUsecase.CountUnread")
+	defer span.End()
+	return s.storage.CountUnreadNotifications(ctx, userID)
+}
+
+func (s *notificationUsecase) MarkRead(ctx context.Context, userID string, ids []string) (int, error) {
+	ctx, span := tracer.Start(ctx, "NotificationUsecase.MarkRead")
+	defer span.End()
+	return s.storage.MarkNotificationsRead(ctx, userID, ids)
+}
+
+// Subscribe возвращает канал новых уведомлений пользователя, который закрывается после отмены ctx
+func (s *notificationUsecase) Subscribe(ctx context.Context, userID string) <-chan *model.Notification {
+	return s.broker.Subscribe(ctx, userID)
+}
+
+// addMentions добавляет уведомления для пользователей, упомянутых в посте или комментарии.
+// Упоминания уже разобраны и сохранены при создании объекта
+func (s *notificationUsecase) addMentions(ctx context.Context, n *notifications, itemID string) error {
+	mentions, err := s.storage.GetMentions(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		if m.UserID != nil {
+			n.add(*m.UserID, model.NotificationKindMention)
+		}
+	}
+	return nil
+}
+
+// save сохраняет уведомления и рассылает их подписчикам
+func (s *notificationUsecase) save(ctx context.Context, list []*model.Notification) error {
+	if len(list) == 0 {
+		return nil
+	}
+	if err := s.storage.CreateNotifications(ctx, list); err != nil {
+		return err
+	}
+	for _, notification := range list {
+		s.broker.Publish(notification)
+	}
+	return nil
+}
+
+// notifications собирает уведомления об одном действии: каждый получатель получает не больше
+// одного уведомления, автор действия себя не уведомляет
+type notifications struct {
+	actorID    string
+	postID     string
+	commentID  *string
+	recipients map[string]struct{}
+	list       []*model.Notification
+}
+
+func newNotifications(actorID, postID string, commentID *string) *notifications {
+	return &notifications{actorID: actorID, postID: postID, commentID: commentID, recipients: map[string]struct{}{}}
+}
+
+func (n *notifications) add(recipientID string, kind model.NotificationKind) {
+	if recipientID == n.actorID {
+		return
+	}
+	if _, exists := n.recipients[recipientID]; exists {
+		return
+	}
+	n.recipients[recipientID] = struct{}{}
+	n.list = append(n.list, &model.Notification{
+		Kind:        kind,
+		RecipientID: recipientID,
+		ActorID:     n.actorID,
+		PostID:      n.postID,
+		CommentID:   n.commentID,
+	})
+}
@@ -2,38 +2,264 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/internal/hashtag"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/VadimRight/GraphQLOzon/storage"
 )
 
+// Число популярных тегов по умолчанию и максимальное
+const (
+	defaultTrendingTags = 10
+	maxTrendingTags     = 100
+)
+
+var (
+	ErrNotPostAuthor         = errors.New("only the author can edit the post")
+	ErrNotPostAuthorDelete   = errors.New("only the author can delete the post")
+	ErrInvalidTrendingWindow = errors.New("trending window must be positive")
+)
+
 type PostUsecase interface {
-	CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error)
+	CreatePost(ctx context.Context, id, text, authorID string, commentable bool, tags []string, format model.TextFormat) (*model.Post, error)
+	UpdatePost(ctx context.Context, id, userID string, text *string, commentable *bool, tags []string, format *model.TextFormat) (*model.Post, error)
+	DeletePost(ctx context.Context, id, userID string) error
+	GetPostTags(ctx context.Context, postID string) ([]string, error)
+	GetPostsByTag(ctx context.Context, tag string, first *int, after *string) (*model.PostConnection, error)
+	GetTrendingTags(ctx context.Context, window time.Duration, limit *int) ([]*model.TrendingTag, error)
 	GetPostByID(ctx context.Context, id string) (*model.Post, error)
 	GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error)
-	GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error)
+	GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error)
 }
 
 type postUsecase struct {
-	storage storage.Storage
+	storage         storage.Storage
+	markdownUsecase MarkdownUsecase
+	validator       *validation.Validator
+}
+
+func NewPostUsecase(storage storage.Storage, markdownUsecase MarkdownUsecase, validator *validation.Validator) PostUsecase {
+	return &postUsecase{storage: storage, markdownUsecase: markdownUsecase, validator: validator}
+}
+
+// CreatePost создает пост с тегами из текста и явно переданными тегами. Пост, упоминания
+// и теги сохраняются в одной транзакции
+func (s *postUsecase) CreatePost(ctx context.Context, id, text, authorID string, commentable bool, tags []string, format model.TextFormat) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.CreatePost")
+	defer span.End()
+	var errs validation.Errors
+	text = s.validator.PostText(&errs, "text", text)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.markdownUsecase.Validate(ctx, format, text); err != nil {
+		return nil, err
+	}
+	explicit, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	var post *model.Post
+	err = s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		post, err = tx.CreatePost(ctx, id, text, authorID, commentable, format)
+		if err != nil {
+			return err
+		}
+		if err := SaveMentions(ctx, tx, post.ID, text); err != nil {
+			return err
+		}
+		return tx.SetPostTags(ctx, post.ID, hashtag.Parse(text), explicit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// UpdatePost меняет переданные поля поста. Теги из текста пересчитываются по новому тексту,
+// а явные теги заменяются, только если переданы tags - иначе сохраняются прежние. Чтение поста
+// и все изменения выполняются в одной транзакции
+func (s *postUsecase) UpdatePost(ctx context.Context, id, userID string, text *string, commentable *bool, tags []string, format *model.TextFormat) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.UpdatePost")
+	defer span.End()
+	var updated *model.Post
+	err := s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		var err error
+		updated, err = s.updatePost(ctx, tx, id, userID, text, commentable, tags, format)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *postUsecase) updatePost(ctx context.Context, tx storage.Storage, id, userID string, text *string, commentable *bool, tags []string, format *model.TextFormat) (*model.Post, error) {
+	post, err := tx.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorID != userID {
+		return nil, ErrNotPostAuthor
+	}
+	if text != nil {
+		var errs validation.Errors
+		normalized := s.validator.PostText(&errs, "text", *text)
+		if err := errs.Err(); err != nil {
+			return nil, err
+		}
+		text = &normalized
+	}
+
+	explicit, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		if explicit, err = tx.GetPostExplicitTags(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	newText, newCommentable, newFormat := post.Text, post.Commentable, post.Format
+	if text != nil {
+		newText = *text
+	}
+	if commentable != nil {
+		newCommentable = *commentable
+	}
+	if format != nil {
+		newFormat = *format
+	}
+	if text != nil || format != nil {
+		if err := s.markdownUsecase.Validate(ctx, newFormat, newText); err != nil {
+			return nil, err
+		}
+	}
+	updated, err := tx.UpdatePost(ctx, id, newText, newCommentable, newFormat)
+	if err != nil {
+		return nil, err
+	}
+	if text != nil {
+		if err := SaveMentions(ctx, tx, id, newText); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.SetPostTags(ctx, id, hashtag.Parse(newText), explicit); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeletePost удаляет пост вместе с комментариями и всем, что к ним относится. Удалить пост может только автор
+func (s *postUsecase) DeletePost(ctx context.Context, id, userID string) error {
+	ctx, span := tracer.Start(ctx, "PostUsecase.DeletePost")
+	defer span.End()
+	return s.storage.WithTx(ctx, func(tx storage.Storage) error {
+		post, err := tx.GetPostByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if post.AuthorID != userID {
+			return ErrNotPostAuthorDelete
+		}
+		return tx.DeletePost(ctx, id)
+	})
+}
+
+func (s *postUsecase) GetPostTags(ctx context.Context, postID string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetPostTags")
+	defer span.End()
+	return s.storage.GetPostTags(ctx, postID)
+}
+
+// GetPostsByTag возвращает страницу постов с тегом от новых к старым
+func (s *postUsecase) GetPostsByTag(ctx context.Context, tag string, first *int, after *string) (*model.PostConnection, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetPostsByTag")
+	defer span.End()
+	normalized, ok := hashtag.Normalize(tag)
+	if !ok {
+		return nil, fmt.Errorf("invalid tag %q", tag)
+	}
+	pageSize, afterKey, err := keysetPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+	// Берем на один пост больше, чтобы узнать, есть ли следующая страница
+	posts, err := s.storage.GetPostsByTag(ctx, normalized, afterKey, pageSize+1)
+	if err != nil {
+		return nil, err
+	}
+	return postConnection(posts, pageSize), nil
+}
+
+// GetTrendingTags возвращает самые используемые теги за последний промежуток window
+func (s *postUsecase) GetTrendingTags(ctx context.Context, window time.Duration, limit *int) ([]*model.TrendingTag, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetTrendingTags")
+	defer span.End()
+	if window <= 0 {
+		return nil, ErrInvalidTrendingWindow
+	}
+	count := defaultTrendingTags
+	if limit != nil {
+		count = min(max(*limit, 0), maxTrendingTags)
+	}
+	return s.storage.GetTrendingTags(ctx, time.Now().Add(-window), count)
 }
 
-func NewPostUsecase(storage storage.Storage) PostUsecase {
-	return &postUsecase{storage: storage}
+// normalizeTags нормализует явно переданные теги и отклоняет некорректные
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		n, ok := hashtag.Normalize(tag)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		normalized = append(normalized, n)
+	}
+	return hashtag.Merge(normalized), nil
 }
 
-func (s *postUsecase) CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error) {
-	return s.storage.CreatePost(ctx, id, text, authorID, commentable)
+// postConnection строит страницу постов с курсорами по ключу. posts содержит на один пост
+// больше размера страницы, если есть следующая страница
+func postConnection(posts []*model.Post, pageSize int) *model.PostConnection {
+	connection := &model.PostConnection{Edges: []*model.PostEdge{}, PageInfo: &model.PageInfo{}}
+	if len(posts) > pageSize {
+		posts = posts[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+	for _, post := range posts {
+		connection.Edges = append(connection.Edges, &model.PostEdge{
+			Cursor: cursor.EncodeKey(cursor.Key{CreatedAt: post.CreatedAt, ID: post.ID}),
+			Node:   post,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection
 }
 
 func (s *postUsecase) GetPostByID(ctx context.Context, id string) (*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetPostByID")
+	defer span.End()
 	return s.storage.GetPostByID(ctx, id)
 }
 
 func (s *postUsecase) GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetPostsByUserID")
+	defer span.End()
 	return s.storage.GetPostsByUserID(ctx, userID, limit, offset)
 }
 
-func (s *postUsecase) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	return s.storage.GetAllPosts(ctx, limit, offset)
+func (s *postUsecase) GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "PostUsecase.GetAllPosts")
+	defer span.End()
+	return s.storage.GetAllPosts(ctx, filter, limit, offset)
 }
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+type ReactionUsecase interface {
+	React(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error)
+	Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error)
+	GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error)
+	GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error)
+}
+
+type reactionUsecase struct {
+	storage storage.Storage
+}
+
+func NewReactionUsecase(storage storage.Storage) ReactionUsecase {
+	return &reactionUsecase{storage: storage}
+}
+
+func (s *reactionUsecase) React(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	ctx, span := tracer.Start(ctx, "ReactionUsecase.React")
+	defer span.End()
+	if !kind.IsValid() {
+		return nil, fmt.Errorf("%s is not a valid ReactionKind", kind)
+	}
+	if err := s.storage.React(ctx, itemID, userID, kind); err != nil {
+		return nil, err
+	}
+	return s.summary(ctx, itemID, userID)
+}
+
+func (s *reactionUsecase) Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) (*model.ReactionSummary, error) {
+	ctx, span := tracer.Start(ctx, "ReactionUsecase.Unreact")
+	defer span.End()
+	if !kind.IsValid() {
+		return nil, fmt.Errorf("%s is not a valid ReactionKind", kind)
+	}
+	if err := s.storage.Unreact(ctx, itemID, userID, kind); err != nil {
+		return nil, err
+	}
+	return s.summary(ctx, itemID, userID)
+}
+
+func (s *reactionUsecase) GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error) {
+	ctx, span := tracer.Start(ctx, "ReactionUsecase.GetReactionCounts")
+	defer span.End()
+	return s.storage.GetReactionCounts(ctx, itemID)
+}
+
+func (s *reactionUsecase) GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error) {
+	ctx, span := tracer.Start(ctx, "ReactionUsecase.GetUserReactions")
+	defer span.End()
+	return s.storage.GetUserReactions(ctx, itemID, userID)
+}
+
+// summary собирает реакции на объект после изменения
+func (s *reactionUsecase) summary(ctx context.Context, itemID, userID string) (*model.ReactionSummary, error) {
+	counts, err := s.storage.GetReactionCounts(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	viewer, err := s.storage.GetUserReactions(ctx, itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReactionSummary{ItemID: itemID, ReactionCounts: counts, ViewerReaction: viewer}, nil
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/VadimRight/GraphQLOzon/storage"
+)
+
+// Размер страницы поиска по умолчанию и максимальный размер страницы
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+var ErrEmptySearchQuery = errors.New("search query is empty")
+
+type SearchUsecase interface {
+	Search(ctx context.Context, query string, types []model.SearchType, first *int, after *string) (*model.SearchConnection, error)
+}
+
+type searchUsecase struct {
+	storage storage.Storage
+}
+
+func NewSearchUsecase(storage storage.Storage) SearchUsecase {
+	return &searchUsecase{storage: storage}
+}
+
+// Search возвращает страницу результатов поиска, начинающуюся после курсора after
+func (s *searchUsecase) Search(ctx context.Context, query string, types []model.SearchType, first *int, after *string) (*model.SearchConnection, error) {
+	ctx, span := tracer.Start(ctx, "SearchUsecase.Search")
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	pageSize := defaultSearchPageSize
+	if first != nil {
+		pageSize = min(max(*first, 0), maxSearchPageSize)
+	}
+	offset := 0
+	if after != nil {
+		afterOffset, err := cursor.DecodeOffset(*after)
+		if err != nil {
+			return nil, err
+		}
+		offset = afterOffset + 1
+	}
+
+	// Берем на один результат больше, чтобы узнать, есть ли следующая страница
+	hits, err := s.storage.Search(ctx, query, types, pageSize+1, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &model.SearchConnection{Edges: []*model.SearchEdge{}, PageInfo: &model.PageInfo{}}
+	if len(hits) > pageSize {
+		hits = hits[:pageSize]
+		connection.PageInfo.HasNextPage = true
+	}
+	for i, hit := range hits {
+		connection.Edges = append(connection.Edges, &model.SearchEdge{
+			Cursor:  cursor.EncodeOffset(offset + i),
+			Node:    hit.Node,
+			Snippet: hit.Snippet,
+			Score:   hit.Score,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
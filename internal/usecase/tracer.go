@@ -0,0 +1,7 @@
+package usecase
+
+import "go.opentelemetry.io/otel"
+
+// Трассировщик для спанов вызовов usecase-слоя. Берется из глобального провайдера,
+// поэтому начинает экспортировать спаны после tracing.Init
+var tracer = otel.Tracer("github.com/VadimRight/GraphQLOzon/internal/usecase")
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/VadimRight/GraphQLOzon/internal/service"
+	"github.com/VadimRight/GraphQLOzon/internal/validation"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/VadimRight/GraphQLOzon/storage"
 	"github.com/dgrijalva/jwt-go"
@@ -27,26 +28,41 @@ type userUsecase struct {
 	commentUsecase  CommentUsecase
 	passwordService service.PasswordService
 	authService     service.AuthService
+	validator       *validation.Validator
 }
 
-func NewUserUsecase(storage storage.Storage, commentUsecase CommentUsecase, passwordService service.PasswordService, authService service.AuthService) UserUsecase {
+func NewUserUsecase(storage storage.Storage, commentUsecase CommentUsecase, passwordService service.PasswordService, authService service.AuthService, validator *validation.Validator) UserUsecase {
 	return &userUsecase{
 		storage:         storage,
 		commentUsecase:  commentUsecase,
 		passwordService: passwordService,
 		authService:     authService,
+		validator:       validator,
 	}
 }
 
 func (s *userUsecase) GetAllUsers(ctx context.Context) ([]*model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.GetAllUsers")
+	defer span.End()
 	return s.storage.GetAllUsers(ctx)
 }
 
+// GetUserByUsername ищет пользователя по имени в той же нормализованной форме, в которой имя сохраняется
 func (s *userUsecase) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
-	return s.storage.GetUserByUsername(ctx, username)
+	ctx, span := tracer.Start(ctx, "UserUsecase.GetUserByUsername")
+	defer span.End()
+	return s.storage.GetUserByUsername(ctx, validation.NormalizeUsername(username))
 }
 
+// UserCreate проверяет имя пользователя и создает пользователя с нормализованным именем
 func (s *userUsecase) UserCreate(ctx context.Context, username string, password string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.UserCreate")
+	defer span.End()
+	var errs validation.Errors
+	username = s.validator.Username(&errs, "username", username)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 	hashedPassword, err := s.passwordService.HashPassword(password)
 	if err != nil {
 		return nil, err
@@ -55,6 +71,8 @@ func (s *userUsecase) UserCreate(ctx context.Context, username string, password
 }
 
 func (s *userUsecase) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.GetUserByID")
+	defer span.End()
 	user, err := s.storage.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -74,12 +92,14 @@ func (s *userUsecase) GetUserByID(ctx context.Context, userID string) (*model.Us
 }
 
 func (s *userUsecase) GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.GetPostsByUserID")
+	defer span.End()
 	posts, err := s.storage.GetPostsByUserID(ctx, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	for _, post := range posts {
-		post.Comments, err = s.commentUsecase.GetCommentsByPostID(ctx, post.ID, limit, offset)
+		post.Comments, err = s.commentUsecase.GetCommentsByPostID(ctx, post.ID, model.CommentSortOld, limit, offset)
 		if err != nil {
 			return nil, err
 		}
@@ -88,6 +108,8 @@ func (s *userUsecase) GetPostsByUserID(ctx context.Context, userID string, limit
 }
 
 func (s *userUsecase) GetCommentsByUserID(ctx context.Context, userID string) ([]*model.CommentResponse, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.GetCommentsByUserID")
+	defer span.End()
 	return s.storage.GetCommentsByUserID(ctx, userID)
 }
 
@@ -100,9 +122,13 @@ func (s *userUsecase) ComparePassword(hashed string, normal string) bool {
 }
 
 func (s *userUsecase) GenerateToken(ctx context.Context, userID string) (string, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.GenerateToken")
+	defer span.End()
 	return s.authService.GenerateToken(ctx, userID)
 }
 
 func (s *userUsecase) ValidateToken(ctx context.Context, token string) (*jwt.Token, error) {
+	ctx, span := tracer.Start(ctx, "UserUsecase.ValidateToken")
+	defer span.End()
 	return s.authService.ValidateToken(ctx, token)
 }
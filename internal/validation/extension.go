@@ -0,0 +1,47 @@
+package validation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Код ошибки, возвращаемый в extensions.code для каждого нарушения
+const ErrValidationFailed = "VALIDATION_FAILED"
+
+// Extension - расширение gqlgen, превращающее *Error резольвера в отдельную GraphQL ошибку
+// на каждое нарушение. Имя аргумента с ошибкой передается в extensions.field
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "InputValidation"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	res, err := next(ctx)
+	var validationErr *Error
+	if !errors.As(err, &validationErr) {
+		return res, err
+	}
+	path := graphql.GetFieldContext(ctx).Path()
+	for _, v := range validationErr.Violations {
+		gqlErr := gqlerror.ErrorPathf(path, "%s: %s", v.Field, v.Message)
+		errcode.Set(gqlErr, ErrValidationFailed)
+		gqlErr.Extensions["field"] = v.Field
+		graphql.AddError(ctx, gqlErr)
+	}
+	// Ошибки уже добавлены в ответ, поэтому поле просто возвращает null
+	return nil, nil
+}
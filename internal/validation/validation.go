@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Violation - нарушение правила проверки в одном поле ввода. Field совпадает с именем аргумента в схеме
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error - ошибка проверки ввода со всеми найденными нарушениями
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Errors собирает нарушения, чтобы вернуть их все одной ошибкой, а не останавливаться на первом
+type Errors struct {
+	violations []Violation
+}
+
+func (e *Errors) Add(field, format string, args ...any) {
+	e.violations = append(e.violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err возвращает *Error с собранными нарушениями или nil, если нарушений нет
+func (e *Errors) Err() error {
+	if len(e.violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: e.violations}
+}
+
+// Validator проверяет и нормализует имена пользователей и тексты постов и комментариев
+type Validator struct {
+	cfg *config.ValidationConfig
+}
+
+func NewValidator(cfg *config.ValidationConfig) *Validator {
+	return &Validator{cfg: cfg}
+}
+
+// NormalizeUsername приводит имя пользователя к форме Unicode NFKC, в которой оно хранится,
+// поэтому полноширинные и составные варианты символов дают одно и то же имя
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(username)
+}
+
+// Username проверяет имя пользователя и возвращает его нормализованным
+func (v *Validator) Username(errs *Errors, field, username string) string {
+	username = NormalizeUsername(username)
+	if n := utf8.RuneCountInString(username); n < v.cfg.UsernameMinLength || n > v.cfg.UsernameMaxLength {
+		errs.Add(field, "must be between %d and %d characters long", v.cfg.UsernameMinLength, v.cfg.UsernameMaxLength)
+	}
+	if hasInvisible(username) {
+		errs.Add(field, "must not contain whitespace, invisible or control characters")
+	} else if username != "" && !v.cfg.UsernamePattern.MatchString(username) {
+		errs.Add(field, "must match the pattern %s", v.cfg.UsernamePattern)
+	}
+	if mixedScripts(username) {
+		errs.Add(field, "must not mix letters from different scripts")
+	}
+	return username
+}
+
+// PostText проверяет текст поста и возвращает его нормализованным
+func (v *Validator) PostText(errs *Errors, field, text string) string {
+	return checkText(errs, field, text, v.cfg.PostMaxLength)
+}
+
+// CommentText проверяет текст комментария и возвращает его нормализованным
+func (v *Validator) CommentText(errs *Errors, field, text string) string {
+	return checkText(errs, field, text, v.cfg.CommentMaxLength)
+}
+
+// checkText приводит текст к форме NFC и проверяет, что он не пустой и не длиннее maxLength.
+// NFC не меняет видимый текст, но одинаковые строки хранятся одинаково
+func checkText(errs *Errors, field, text string, maxLength int) string {
+	text = norm.NFC.String(text)
+	blank := strings.IndexFunc(text, func(r rune) bool { return !isBlank(r) }) < 0
+	if blank {
+		errs.Add(field, "must not be empty or contain only whitespace")
+	}
+	if n := utf8.RuneCountInString(text); n > maxLength {
+		errs.Add(field, "must be at most %d characters long, got %d", maxLength, n)
+	}
+	return text
+}
+
+// isBlank сообщает, что символ не виден в тексте: пробельный или служебный вроде пробела нулевой ширины
+func isBlank(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) || unicode.IsControl(r)
+}
+
+func hasInvisible(s string) bool {
+	return strings.IndexFunc(s, isBlank) >= 0
+}
+
+// Письменности, смешение которых в одном имени используется для подделки имен:
+// латинская "a" и кириллическая "а" выглядят одинаково
+var scripts = []*unicode.RangeTable{
+	unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Armenian, unicode.Georgian,
+	unicode.Arabic, unicode.Hebrew, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+}
+
+// mixedScripts сообщает, что в строке есть буквы больше чем одной письменности. Китайские
+// иероглифы и японские каны считаются одной письменностью, так как используются вместе
+func mixedScripts(s string) bool {
+	var found *unicode.RangeTable
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, script := range scripts {
+			if !unicode.Is(script, r) {
+				continue
+			}
+			if script == unicode.Hiragana || script == unicode.Katakana {
+				script = unicode.Han
+			}
+			if found != nil && found != script {
+				return true
+			}
+			found = script
+			break
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestValidator() *Validator {
+	return NewValidator(&config.ValidationConfig{
+		UsernamePattern:   regexp.MustCompile(`^[\p{L}\p{N}_]+$`),
+		UsernameMinLength: 3,
+		UsernameMaxLength: 20,
+		PostMaxLength:     10,
+		CommentMaxLength:  5,
+	})
+}
+
+// violations возвращает сообщения нарушений из ошибки Errors.Err
+func violations(t *testing.T, errs *Errors) []string {
+	t.Helper()
+	err := errs.Err()
+	if err == nil {
+		return nil
+	}
+	var validationErr *Error
+	require.True(t, errors.As(err, &validationErr))
+	messages := make([]string, 0, len(validationErr.Violations))
+	for _, v := range validationErr.Violations {
+		messages = append(messages, v.Field+": "+v.Message)
+	}
+	return messages
+}
+
+func TestUsername(t *testing.T) {
+	v := newTestValidator()
+
+	tests := []struct {
+		name       string
+		username   string
+		normalized string
+		violations []string
+	}{
+		{name: "valid", username: "alice_42", normalized: "alice_42"},
+		{name: "cyrillic", username: "вадим", normalized: "вадим"},
+		{name: "fullwidth is normalized", username: "ａｌｉｃｅ", normalized: "alice"},
+		{
+			name:       "empty",
+			username:   "",
+			violations: []string{"username: must be between 3 and 20 characters long"},
+		},
+		{
+			name:     "too long with spaces",
+			username: strings.Repeat("a b", 10),
+			violations: []string{
+				"username: must be between 3 and 20 characters long",
+				"username: must not contain whitespace, invisible or control characters",
+			},
+		},
+		{
+			name:       "zero width space",
+			username:   "ali\u200bce",
+			violations: []string{"username: must not contain whitespace, invisible or control characters"},
+		},
+		{
+			name:       "pattern",
+			username:   "alice!",
+			violations: []string{`username: must match the pattern ^[\p{L}\p{N}_]+$`},
+		},
+		{
+			// \u0430 - кириллическая "а"
+			name:       "mixed scripts",
+			username:   "\u0430lice",
+			violations: []string{"username: must not mix letters from different scripts"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs Errors
+			normalized := v.Username(&errs, "username", tt.username)
+			assert.Equal(t, tt.violations, violations(t, &errs))
+			if tt.violations == nil {
+				assert.Equal(t, tt.normalized, normalized)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	v := newTestValidator()
+
+	var errs Errors
+	assert.Equal(t, "héllo", v.PostText(&errs, "text", "he\u0301llo"))
+	assert.Nil(t, errs.Err())
+
+	v.PostText(&errs, "text", " \n\t\u200b ")
+	v.CommentText(&errs, "comment", "too long comment")
+	assert.Equal(t, []string{
+		"text: must not be empty or contain only whitespace",
+		"comment: must be at most 5 characters long, got 16",
+	}, violations(t, &errs))
+	assert.EqualError(t, errs.Err(), "validation failed: text: must not be empty or contain only whitespace; comment: must be at most 5 characters long, got 16")
+}
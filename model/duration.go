@@ -0,0 +1,46 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalDuration выводит скаляр Duration в формате Go, например "24h0m0s"
+func MarshalDuration(d time.Duration) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(d.String()))
+	})
+}
+
+// Наибольшее число дней, которое помещается в time.Duration
+const maxDurationDays = math.MaxInt64 / int64(24*time.Hour)
+
+// UnmarshalDuration разбирает скаляр Duration: строку в формате Go ("90m", "24h")
+// или целое число дней с суффиксом d ("7d")
+func UnmarshalDuration(v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("duration must be a string")
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if n > maxDurationDays || n < -maxDurationDays {
+			return 0, fmt.Errorf("duration %q is too long", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
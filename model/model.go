@@ -1,5 +1,12 @@
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
 // Comment представляет собой структуру комментария
 type Comment struct {
 	ID            string `json:"id"`
@@ -18,12 +25,77 @@ type CommentResponse struct {
 	ParentCommentID *string            `json:"parentCommentID,omitempty"`
 	AuthorComment   *User              `json:"authorComment"`
 	Replies         []*CommentResponse `json:"replies"`
+	Format          TextFormat         `json:"format"`
+	CreatedAt       time.Time          `json:"createdAt"`
+}
+
+// CommentFilter представляет собой фильтр списка комментариев
+type CommentFilter struct {
+	AuthorIds     []string   `json:"authorIds,omitempty"`
+	PostIds       []string   `json:"postIds,omitempty"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	HasReplies    *bool      `json:"hasReplies,omitempty"`
+	TextContains  *string    `json:"textContains,omitempty"`
+}
+
+// Mention представляет собой упоминание @username в тексте поста или комментария.
+// Offset и Length считаются в символах и включают @. UserID равен nil, если пользователь удален
+type Mention struct {
+	UserID *string `json:"-"`
+	Offset int     `json:"offset"`
+	Length int     `json:"length"`
+}
+
+// MentionSource - объект, в тексте которого есть упоминание: пост или комментарий
+type MentionSource interface {
+	IsMentionSource()
+}
+
+func (Post) IsMentionSource()            {}
+func (CommentResponse) IsMentionSource() {}
+
+// MentionConnection представляет собой страницу постов и комментариев с упоминанием пользователя
+type MentionConnection struct {
+	Edges    []*MentionEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+}
+
+// MentionEdge представляет собой пост или комментарий с упоминанием вместе с его курсором
+type MentionEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   MentionSource `json:"node"`
 }
 
 // Mutation представляет собой структуру мутаций GraphQL
 type Mutation struct {
 }
 
+// Notification представляет собой уведомление пользователя. Автор действия, пост и комментарий
+// хранятся по ID и загружаются резольверами полей
+type Notification struct {
+	ID          string           `json:"id"`
+	Kind        NotificationKind `json:"kind"`
+	RecipientID string           `json:"-"`
+	ActorID     string           `json:"-"`
+	PostID      string           `json:"-"`
+	CommentID   *string          `json:"-"`
+	Read        bool             `json:"read"`
+	CreatedAt   time.Time        `json:"createdAt"`
+}
+
+// NotificationConnection представляет собой страницу уведомлений с курсорами
+type NotificationConnection struct {
+	Edges    []*NotificationEdge `json:"edges"`
+	PageInfo *PageInfo           `json:"pageInfo"`
+}
+
+// NotificationEdge представляет собой уведомление на странице вместе с его курсором
+type NotificationEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *Notification `json:"node"`
+}
+
 // Post представляет собой структуру поста
 type Post struct {
 	ID          string             `json:"id"`
@@ -32,22 +104,416 @@ type Post struct {
 	AuthorPost  *User              `json:"authorPost"`
 	Comments    []*CommentResponse `json:"comments"`
 	Commentable bool               `json:"commentable"`
+	Format      TextFormat         `json:"format"`
+	CreatedAt   time.Time          `json:"createdAt"`
+}
+
+// PostFilter представляет собой фильтр списка постов
+type PostFilter struct {
+	AuthorIds     []string   `json:"authorIds,omitempty"`
+	Commentable   *bool      `json:"commentable,omitempty"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	HasComments   *bool      `json:"hasComments,omitempty"`
+	TextContains  *string    `json:"textContains,omitempty"`
+}
+
+// PageInfo описывает страницу результата: курсор последнего элемента и наличие следующей страницы
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+// PostConnection представляет собой страницу постов с курсорами
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+// PostEdge представляет собой пост на странице вместе с его курсором
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
 }
 
 // Query представляет собой структуру запросов GraphQL
 type Query struct {
 }
 
+// ReactionCount представляет собой количество реакций одного вида
+type ReactionCount struct {
+	Kind  ReactionKind `json:"kind"`
+	Count int          `json:"count"`
+}
+
+// ReactionSummary представляет собой реакции на объект после react/unreact
+type ReactionSummary struct {
+	ItemID         string           `json:"itemId"`
+	ReactionCounts []*ReactionCount `json:"reactionCounts"`
+	ViewerReaction []ReactionKind   `json:"viewerReaction"`
+}
+
+// SearchResult - найденный объект: пост или комментарий
+type SearchResult interface {
+	IsSearchResult()
+}
+
+func (Post) IsSearchResult()            {}
+func (CommentResponse) IsSearchResult() {}
+
+// SearchConnection представляет собой страницу результатов поиска
+type SearchConnection struct {
+	Edges    []*SearchEdge `json:"edges"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+}
+
+// SearchEdge представляет собой найденный объект с фрагментом текста и релевантностью
+type SearchEdge struct {
+	Cursor  string       `json:"cursor"`
+	Node    SearchResult `json:"node"`
+	Snippet string       `json:"snippet"`
+	Score   float64      `json:"score"`
+}
+
+// SearchHit - результат поиска на уровне хранилища, до построения курсоров
+type SearchHit struct {
+	Node    SearchResult
+	Snippet string
+	Score   float64
+}
+
+// Subscription представляет собой структуру подписок GraphQL
+type Subscription struct {
+}
+
 // Token представляет собой структуру токена
 type Token struct {
 	Token string `json:"token"`
 }
 
+// TrendingTag представляет собой тег и число его использований за промежуток времени
+type TrendingTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Follow представляет собой подписку: пользователя на другой стороне подписки и время ее оформления
+type Follow struct {
+	User       *User
+	FollowedAt time.Time
+}
+
+// UserConnection представляет собой страницу пользователей с курсорами
+type UserConnection struct {
+	Edges    []*UserEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+// UserEdge представляет собой пользователя на странице подписчиков или подписок
+type UserEdge struct {
+	Cursor     string    `json:"cursor"`
+	Node       *User     `json:"node"`
+	FollowedAt time.Time `json:"followedAt"`
+}
+
 // User представляет собой структуру пользователя
 type User struct {
 	ID       string             `json:"id"`
 	Username string             `json:"username"`
 	Password string             `json:"password"`
+	Role     UserRole           `json:"role"`
+	Disabled bool               `json:"disabled"`
 	Posts    []*Post            `json:"posts"`
 	Comments []*CommentResponse `json:"comments"`
 }
+
+// UserRole - роль пользователя. Роль назначается администратором через CLI и в схеме не видна
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "USER"
+	UserRoleAdmin UserRole = "ADMIN"
+)
+
+var AllUserRole = []UserRole{
+	UserRoleUser,
+	UserRoleAdmin,
+}
+
+func (e UserRole) IsValid() bool {
+	switch e {
+	case UserRoleUser, UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e UserRole) String() string {
+	return string(e)
+}
+
+// CacheControlScope определяет, можно ли хранить ответ в общих кэшах (PUBLIC) или только в кэше клиента (PRIVATE)
+type CacheControlScope string
+
+const (
+	CacheControlScopePublic  CacheControlScope = "PUBLIC"
+	CacheControlScopePrivate CacheControlScope = "PRIVATE"
+)
+
+var AllCacheControlScope = []CacheControlScope{
+	CacheControlScopePublic,
+	CacheControlScopePrivate,
+}
+
+func (e CacheControlScope) IsValid() bool {
+	switch e {
+	case CacheControlScopePublic, CacheControlScopePrivate:
+		return true
+	}
+	return false
+}
+
+func (e CacheControlScope) String() string {
+	return string(e)
+}
+
+func (e *CacheControlScope) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CacheControlScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CacheControlScope", str)
+	}
+	return nil
+}
+
+func (e CacheControlScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// SearchType определяет, среди каких объектов выполняется поиск
+type SearchType string
+
+const (
+	SearchTypePost    SearchType = "POST"
+	SearchTypeComment SearchType = "COMMENT"
+)
+
+var AllSearchType = []SearchType{
+	SearchTypePost,
+	SearchTypeComment,
+}
+
+func (e SearchType) IsValid() bool {
+	switch e {
+	case SearchTypePost, SearchTypeComment:
+		return true
+	}
+	return false
+}
+
+func (e SearchType) String() string {
+	return string(e)
+}
+
+func (e *SearchType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SearchType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SearchType", str)
+	}
+	return nil
+}
+
+func (e SearchType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// ReactionKind определяет вид реакции из фиксированного набора
+type ReactionKind string
+
+const (
+	ReactionKindLike    ReactionKind = "LIKE"
+	ReactionKindDislike ReactionKind = "DISLIKE"
+	ReactionKindLove    ReactionKind = "LOVE"
+	ReactionKindLaugh   ReactionKind = "LAUGH"
+	ReactionKindSad     ReactionKind = "SAD"
+	ReactionKindAngry   ReactionKind = "ANGRY"
+)
+
+var AllReactionKind = []ReactionKind{
+	ReactionKindLike,
+	ReactionKindDislike,
+	ReactionKindLove,
+	ReactionKindLaugh,
+	ReactionKindSad,
+	ReactionKindAngry,
+}
+
+func (e ReactionKind) IsValid() bool {
+	switch e {
+	case ReactionKindLike, ReactionKindDislike, ReactionKindLove, ReactionKindLaugh, ReactionKindSad, ReactionKindAngry:
+		return true
+	}
+	return false
+}
+
+func (e ReactionKind) String() string {
+	return string(e)
+}
+
+func (e *ReactionKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReactionKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReactionKind", str)
+	}
+	return nil
+}
+
+func (e ReactionKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// CommentSort определяет порядок комментариев и ответов
+type CommentSort string
+
+const (
+	CommentSortNew           CommentSort = "NEW"
+	CommentSortOld           CommentSort = "OLD"
+	CommentSortTop           CommentSort = "TOP"
+	CommentSortControversial CommentSort = "CONTROVERSIAL"
+)
+
+var AllCommentSort = []CommentSort{
+	CommentSortNew,
+	CommentSortOld,
+	CommentSortTop,
+	CommentSortControversial,
+}
+
+func (e CommentSort) IsValid() bool {
+	switch e {
+	case CommentSortNew, CommentSortOld, CommentSortTop, CommentSortControversial:
+		return true
+	}
+	return false
+}
+
+func (e CommentSort) String() string {
+	return string(e)
+}
+
+func (e *CommentSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentSort", str)
+	}
+	return nil
+}
+
+func (e CommentSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// NotificationKind определяет причину уведомления
+type NotificationKind string
+
+const (
+	NotificationKindComment NotificationKind = "COMMENT"
+	NotificationKindReply   NotificationKind = "REPLY"
+	NotificationKindMention NotificationKind = "MENTION"
+)
+
+var AllNotificationKind = []NotificationKind{
+	NotificationKindComment,
+	NotificationKindReply,
+	NotificationKindMention,
+}
+
+func (e NotificationKind) IsValid() bool {
+	switch e {
+	case NotificationKindComment, NotificationKindReply, NotificationKindMention:
+		return true
+	}
+	return false
+}
+
+func (e NotificationKind) String() string {
+	return string(e)
+}
+
+func (e *NotificationKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationKind", str)
+	}
+	return nil
+}
+
+func (e NotificationKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// TextFormat определяет формат текста поста или комментария
+type TextFormat string
+
+const (
+	TextFormatPlain    TextFormat = "PLAIN"
+	TextFormatMarkdown TextFormat = "MARKDOWN"
+)
+
+var AllTextFormat = []TextFormat{
+	TextFormatPlain,
+	TextFormatMarkdown,
+}
+
+func (e TextFormat) IsValid() bool {
+	switch e {
+	case TextFormatPlain, TextFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (e TextFormat) String() string {
+	return string(e)
+}
+
+func (e *TextFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TextFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TextFormat", str)
+	}
+	return nil
+}
+
+func (e TextFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
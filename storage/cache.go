@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// Cache - кэш, в котором CachedStorage хранит пользователей и посты. Значения передаются
+// сериализованными, поэтому вместо локального LRU можно подключить внешний кэш, например Redis.
+// Ошибка кэша не ломает запрос: CachedStorage в этом случае читает из хранилища
+type Cache interface {
+	// Get возвращает значение по ключу и false, если его нет или оно устарело
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// LRUCache - локальный кэш в памяти процесса с ограниченным числом записей и временем жизни
+type LRUCache struct {
+	lru *expirable.LRU[string, []byte]
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+// NewLRUCache возвращает кэш не больше чем на size записей, каждая из которых живет ttl
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{lru: expirable.NewLRU[string, []byte](size, nil, ttl)}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := c.lru.Get(key)
+	return value, ok, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, key string, value []byte) error {
+	c.lru.Add(key, value)
+	return nil
+}
+
+func (c *LRUCache) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.lru.Remove(key)
+	}
+	return nil
+}
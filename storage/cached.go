@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/VadimRight/GraphQLOzon/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "github.com/VadimRight/GraphQLOzon/storage"
+
+// CachedStorage - декоратор хранилища, который кэширует чтение пользователей и постов по ID.
+// Остальные методы передаются хранилищу без изменений. Записи, которые меняют пользователя
+// или пост, удаляют его из кэша; внутри транзакции это происходит после ее фиксации,
+// а чтения идут мимо кэша, чтобы видеть незафиксированные изменения. Хэши паролей
+// в кэш не попадают, а IsUserDisabled всегда читает хранилище
+type CachedStorage struct {
+	Storage
+	cache  Cache
+	hits   metric.Int64Counter
+	misses metric.Int64Counter
+	stats  cacheStats
+	// generation увеличивается при каждом удалении из кэша. Прочитанное значение кладется
+	// в кэш, только если за время чтения удалений не было, иначе оно может быть устаревшим.
+	// Проверка и запись идут под fill.RLock, а увеличение - под fill.Lock
+	generation uint64
+	fill       sync.RWMutex
+}
+
+var _ Storage = (*CachedStorage)(nil)
+
+// CacheStats - число попаданий и промахов кэша с момента запуска
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+type cacheStats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachedStorage оборачивает backend кэшем. Попадания и промахи считаются метриками
+// OpenTelemetry storage.cache.hits и storage.cache.misses с атрибутом entity
+func NewCachedStorage(backend Storage, cache Cache) *CachedStorage {
+	meter := otel.Meter(instrumentationName)
+	// Ошибка создания счетчика возвращается вместе с рабочим no-op счетчиком
+	hits, _ := meter.Int64Counter("storage.cache.hits", metric.WithDescription("Storage cache hits"))
+	misses, _ := meter.Int64Counter("storage.cache.misses", metric.WithDescription("Storage cache misses"))
+	return &CachedStorage{Storage: backend, cache: cache, hits: hits, misses: misses}
+}
+
+// Stats возвращает число попаданий и промахов кэша
+func (s *CachedStorage) Stats() CacheStats {
+	return CacheStats{Hits: s.stats.hits.Load(), Misses: s.stats.misses.Load()}
+}
+
+// Unwrap возвращает хранилище, обернутое кэшем
+func (s *CachedStorage) Unwrap() Storage {
+	return s.Storage
+}
+
+func userKey(id string) string {
+	return "user:" + id
+}
+
+func postKey(id string) string {
+	return "post:" + id
+}
+
+func (s *CachedStorage) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
+	return cached(ctx, s, "user", userKey(userID), func() (*model.User, error) {
+		user, err := s.Storage.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		// Кэш может быть внешним, поэтому хэш пароля в него не кладется
+		user.Password = ""
+		return user, nil
+	})
+}
+
+func (s *CachedStorage) GetPostByID(ctx context.Context, postID string) (*model.Post, error) {
+	return cached(ctx, s, "post", postKey(postID), func() (*model.Post, error) {
+		return s.Storage.GetPostByID(ctx, postID)
+	})
+}
+
+func (s *CachedStorage) SetUserRole(ctx context.Context, userID string, role model.UserRole) (*model.User, error) {
+	user, err := s.Storage.SetUserRole(ctx, userID, role)
+	s.invalidate(ctx, userKey(userID))
+	return user, err
+}
+
+func (s *CachedStorage) SetUserDisabled(ctx context.Context, userID string, disabled bool) (*model.User, error) {
+	user, err := s.Storage.SetUserDisabled(ctx, userID, disabled)
+	s.invalidate(ctx, userKey(userID))
+	return user, err
+}
+
+func (s *CachedStorage) SetUserPassword(ctx context.Context, userID string, password string) (*model.User, error) {
+	user, err := s.Storage.SetUserPassword(ctx, userID, password)
+	s.invalidate(ctx, userKey(userID))
+	return user, err
+}
+
+func (s *CachedStorage) UpdatePost(ctx context.Context, id, text string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	post, err := s.Storage.UpdatePost(ctx, id, text, commentable, format)
+	s.invalidate(ctx, postKey(id))
+	return post, err
+}
+
+func (s *CachedStorage) DeletePost(ctx context.Context, id string) error {
+	err := s.Storage.DeletePost(ctx, id)
+	s.invalidate(ctx, postKey(id))
+	return err
+}
+
+// WithTx выполняет fn в транзакции хранилища. Ключи, измененные в транзакции, удаляются
+// из кэша после ее фиксации, поэтому другие запросы не закэшируют незафиксированное значение надолго
+func (s *CachedStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	tx := &cachedTx{}
+	err := s.Storage.WithTx(ctx, func(backend Storage) error {
+		tx.Storage = backend
+		return fn(tx)
+	})
+	if err == nil {
+		s.invalidate(ctx, tx.keys...)
+	}
+	return err
+}
+
+// invalidate удаляет ключи из кэша. Ошибка кэша только логируется: запись уже выполнена,
+// а устаревшее значение пропадет по истечении времени жизни
+func (s *CachedStorage) invalidate(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	s.fill.Lock()
+	s.generation++
+	s.fill.Unlock()
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		logger.FromContext(ctx).Warn("failed to invalidate storage cache", "keys", keys, "error", err)
+	}
+}
+
+// cached возвращает значение из кэша или читает его через load и кладет в кэш, если за время
+// чтения ничего не удалялось из кэша
+func cached[T any](ctx context.Context, s *CachedStorage, entity, key string, load func() (*T, error)) (*T, error) {
+	attrs := metric.WithAttributes(attribute.String("entity", entity))
+	s.fill.RLock()
+	generation := s.generation
+	s.fill.RUnlock()
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to read storage cache", "key", key, "error", err)
+	}
+	if ok {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			s.stats.hits.Add(1)
+			s.hits.Add(ctx, 1, attrs)
+			return &value, nil
+		}
+	}
+	s.stats.misses.Add(1)
+	s.misses.Add(ctx, 1, attrs)
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		s.fill.RLock()
+		if s.generation == generation {
+			if err := s.cache.Set(ctx, key, data); err != nil {
+				logger.FromContext(ctx).Warn("failed to write storage cache", "key", key, "error", err)
+			}
+		}
+		s.fill.RUnlock()
+	}
+	return value, nil
+}
+
+// cachedTx - хранилище внутри транзакции CachedStorage. Чтения идут в транзакцию мимо кэша,
+// а ключи измененных объектов запоминаются, чтобы удалить их из кэша после фиксации
+type cachedTx struct {
+	Storage
+	keys []string
+}
+
+func (t *cachedTx) SetUserRole(ctx context.Context, userID string, role model.UserRole) (*model.User, error) {
+	t.keys = append(t.keys, userKey(userID))
+	return t.Storage.SetUserRole(ctx, userID, role)
+}
+
+func (t *cachedTx) SetUserDisabled(ctx context.Context, userID string, disabled bool) (*model.User, error) {
+	t.keys = append(t.keys, userKey(userID))
+	return t.Storage.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (t *cachedTx) SetUserPassword(ctx context.Context, userID string, password string) (*model.User, error) {
+	t.keys = append(t.keys, userKey(userID))
+	return t.Storage.SetUserPassword(ctx, userID, password)
+}
+
+func (t *cachedTx) UpdatePost(ctx context.Context, id, text string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	t.keys = append(t.keys, postKey(id))
+	return t.Storage.UpdatePost(ctx, id, text, commentable, format)
+}
+
+func (t *cachedTx) DeletePost(ctx context.Context, id string) error {
+	t.keys = append(t.keys, postKey(id))
+	return t.Storage.DeletePost(ctx, id)
+}
+
+// WithTx внутри транзакции выполняет fn в ней же
+func (t *cachedTx) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return fn(t)
+}
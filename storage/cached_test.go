@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache - внешний кэш для тестов: хранит значения в map и может отвечать ошибкой
+type mapCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{values: map[string][]byte{}}
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, false, c.err
+	}
+	value, ok := c.values[key]
+	return value, ok, nil
+}
+
+func (c *mapCache) Set(ctx context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.values[key] = value
+	return nil
+}
+
+func (c *mapCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+func (c *mapCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.values[key]
+	return ok
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+	cache := newMapCache()
+	s := NewCachedStorage(NewInMemoryStorage(), cache)
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	post, err := s.CreatePost(ctx, "post-1", "first", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	got, err := s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	// Изменение полученного поста не меняет закэшированный
+	got.Text = "changed"
+	got, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.Text)
+	// Хэш пароля не попадает в кэш
+	user, err := s.GetUserByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Empty(t, user.Password)
+	assert.NotContains(t, string(cache.values[userKey(alice.ID)]), "hash")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, s.Stats())
+
+	// Блокировка читается мимо кэша, поэтому видна сразу после изменения в обход кэша
+	_, err = s.Unwrap().SetUserDisabled(ctx, alice.ID, true)
+	require.NoError(t, err)
+	disabled, err := s.IsUserDisabled(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.True(t, disabled)
+
+	// Запись удаляет пост из кэша
+	_, err = s.UpdatePost(ctx, post.ID, "second", true, model.TextFormatPlain)
+	require.NoError(t, err)
+	assert.False(t, cache.has(postKey(post.ID)))
+	got, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Text)
+
+	// В транзакции чтения идут мимо кэша, а ключи удаляются только после фиксации
+	err = s.WithTx(ctx, func(tx Storage) error {
+		if _, err := tx.UpdatePost(ctx, post.ID, "third", true, model.TextFormatPlain); err != nil {
+			return err
+		}
+		got, err := tx.GetPostByID(ctx, post.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "third", got.Text)
+		assert.True(t, cache.has(postKey(post.ID)))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+	assert.True(t, cache.has(postKey(post.ID)))
+	got, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Text)
+
+	err = s.WithTx(ctx, func(tx Storage) error {
+		_, err := tx.UpdatePost(ctx, post.ID, "third", true, model.TextFormatPlain)
+		return err
+	})
+	require.NoError(t, err)
+	got, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "third", got.Text)
+
+	require.NoError(t, s.DeletePost(ctx, post.ID))
+	_, err = s.GetPostByID(ctx, post.ID)
+	assert.Error(t, err)
+
+	// Недоступный кэш не ломает чтение
+	cache.err = errors.New("cache is down")
+	user, err = s.GetUserByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+}
+
+// racingStorage вызывает duringGet после чтения поста, но до того, как CachedStorage положит его в кэш
+type racingStorage struct {
+	Storage
+	duringGet func()
+}
+
+func (s *racingStorage) GetPostByID(ctx context.Context, postID string) (*model.Post, error) {
+	post, err := s.Storage.GetPostByID(ctx, postID)
+	if s.duringGet != nil {
+		s.duringGet()
+		s.duringGet = nil
+	}
+	return post, err
+}
+
+func TestCachedStorageDoesNotFillStaleValue(t *testing.T) {
+	ctx := context.Background()
+	cache := newMapCache()
+	backend := &racingStorage{Storage: NewInMemoryStorage()}
+	s := NewCachedStorage(backend, cache)
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	post, err := s.CreatePost(ctx, "post-1", "first", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	// Пост меняется, пока чтение еще не положило прежнее значение в кэш
+	backend.duringGet = func() {
+		_, err := s.UpdatePost(ctx, post.ID, "second", true, model.TextFormatPlain)
+		require.NoError(t, err)
+	}
+	got, err := s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.Text)
+	assert.False(t, cache.has(postKey(post.ID)))
+
+	got, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.Text)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(2, 50*time.Millisecond)
+	require.NoError(t, cache.Set(ctx, "a", []byte("1")))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2")))
+	require.NoError(t, cache.Set(ctx, "c", []byte("3")))
+
+	// Самая старая запись вытеснена
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	value, ok, err := cache.Get(ctx, "c")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, []byte("3"), value)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok, err = cache.Get(ctx, "c")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// sqlBuilder собирает SQL запрос из условий WHERE с позиционными параметрами.
+// Значения никогда не подставляются в текст запроса - только через $N
+type sqlBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg добавляет параметр запроса и возвращает его плейсхолдер
+func (b *sqlBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// where добавляет условие. Плейсхолдеры в условии получаются через arg
+func (b *sqlBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// build дописывает к запросу условия, сортировку и пагинацию. Пустой orderBy - запрос без сортировки
+func (b *sqlBuilder) build(query, orderBy string, limit, offset *int) string {
+	if len(b.conditions) > 0 {
+		query += " WHERE " + strings.Join(b.conditions, " AND ")
+	}
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	if limit != nil {
+		query += " LIMIT " + b.arg(*limit)
+	}
+	if offset != nil {
+		query += " OFFSET " + b.arg(*offset)
+	}
+	return query
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE, чтобы подстрока искалась буквально
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// uuidArray оставляет только ID в формате UUID: иначе приведение к uuid[] в Postgres провалит
+// весь запрос, а хранилище в памяти для таких ID просто ничего не находит. Если не осталось
+// ни одного ID, условие ANY с пустым массивом не выполняется, и результат пуст
+func uuidArray(ids []string) interface{} {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	return pq.Array(valid)
+}
+
+// applyPostFilter переводит фильтр постов в условия запроса к таблице post
+func applyPostFilter(b *sqlBuilder, f *model.PostFilter) {
+	if f == nil {
+		return
+	}
+	if len(f.AuthorIds) > 0 {
+		b.where("post.author_id = ANY(" + b.arg(uuidArray(f.AuthorIds)) + "::uuid[])")
+	}
+	if f.Commentable != nil {
+		b.where("post.commentable = " + b.arg(*f.Commentable))
+	}
+	applyCreatedFilter(b, "post", f.CreatedAfter, f.CreatedBefore)
+	if f.HasComments != nil {
+		b.where(existsPrefix(*f.HasComments) + "EXISTS (SELECT 1 FROM comment c WHERE c.post_id = post.id)")
+	}
+	if f.TextContains != nil && *f.TextContains != "" {
+		b.where("post.text ILIKE '%' || " + b.arg(likeEscaper.Replace(*f.TextContains)) + " || '%'")
+	}
+}
+
+// applyCommentFilter переводит фильтр комментариев в условия запроса к таблице comment
+func applyCommentFilter(b *sqlBuilder, f *model.CommentFilter) {
+	if f == nil {
+		return
+	}
+	if len(f.AuthorIds) > 0 {
+		b.where("comment.author_id = ANY(" + b.arg(uuidArray(f.AuthorIds)) + "::uuid[])")
+	}
+	if len(f.PostIds) > 0 {
+		b.where("comment.post_id = ANY(" + b.arg(uuidArray(f.PostIds)) + "::uuid[])")
+	}
+	applyCreatedFilter(b, "comment", f.CreatedAfter, f.CreatedBefore)
+	if f.HasReplies != nil {
+		b.where(existsPrefix(*f.HasReplies) + "EXISTS (SELECT 1 FROM comment r WHERE r.parent_comment_id = comment.id)")
+	}
+	if f.TextContains != nil && *f.TextContains != "" {
+		b.where("comment.comment ILIKE '%' || " + b.arg(likeEscaper.Replace(*f.TextContains)) + " || '%'")
+	}
+}
+
+func applyCreatedFilter(b *sqlBuilder, table string, after, before *time.Time) {
+	if after != nil {
+		b.where(table + ".created_at > " + b.arg(*after))
+	}
+	if before != nil {
+		b.where(table + ".created_at < " + b.arg(*before))
+	}
+}
+
+func existsPrefix(exists bool) string {
+	if exists {
+		return ""
+	}
+	return "NOT "
+}
+
+// matchPost проверяет пост по фильтру в InMemoryStorage
+func matchPost(f *model.PostFilter, post *model.Post, hasComments bool) bool {
+	if f == nil {
+		return true
+	}
+	return (len(f.AuthorIds) == 0 || contains(f.AuthorIds, post.AuthorID)) &&
+		(f.Commentable == nil || *f.Commentable == post.Commentable) &&
+		matchCreated(post.CreatedAt, f.CreatedAfter, f.CreatedBefore) &&
+		(f.HasComments == nil || *f.HasComments == hasComments) &&
+		matchText(post.Text, f.TextContains)
+}
+
+// matchComment проверяет комментарий по фильтру в InMemoryStorage
+func matchComment(f *model.CommentFilter, comment *model.CommentResponse, hasReplies bool) bool {
+	if f == nil {
+		return true
+	}
+	return (len(f.AuthorIds) == 0 || contains(f.AuthorIds, comment.AuthorID)) &&
+		(len(f.PostIds) == 0 || contains(f.PostIds, comment.PostID)) &&
+		matchCreated(comment.CreatedAt, f.CreatedAfter, f.CreatedBefore) &&
+		(f.HasReplies == nil || *f.HasReplies == hasReplies) &&
+		matchText(comment.Comment, f.TextContains)
+}
+
+func matchCreated(createdAt time.Time, after, before *time.Time) bool {
+	return (after == nil || createdAt.After(*after)) && (before == nil || createdAt.Before(*before))
+}
+
+func matchText(text string, substr *string) bool {
+	return substr == nil || strings.Contains(strings.ToLower(text), strings.ToLower(*substr))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
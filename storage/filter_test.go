@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostFilterSQLIsParameterized(t *testing.T) {
+	commentable, hasComments := true, false
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	text := "50%_off'; DROP TABLE post; --"
+	limit, offset := 10, 20
+
+	const authorID = "7d444840-9dc0-11d1-b245-5ffdce74fad2"
+
+	b := &sqlBuilder{}
+	applyPostFilter(b, &model.PostFilter{
+		AuthorIds:    []string{authorID, "not-a-uuid"},
+		Commentable:  &commentable,
+		CreatedAfter: &after,
+		HasComments:  &hasComments,
+		TextContains: &text,
+	})
+	query := b.build("SELECT id FROM post", "created_at, id", &limit, &offset)
+
+	assert.Equal(t, "SELECT id FROM post WHERE post.author_id = ANY($1::uuid[]) AND post.commentable = $2"+
+		" AND post.created_at > $3 AND NOT EXISTS (SELECT 1 FROM comment c WHERE c.post_id = post.id)"+
+		" AND post.text ILIKE '%' || $4 || '%' ORDER BY created_at, id LIMIT $5 OFFSET $6", query)
+	assert.Equal(t, []interface{}{pq.Array([]string{authorID}), true, after, `50\%\_off'; DROP TABLE post; --`, 10, 20}, b.args)
+}
+
+func TestFilterDropsMalformedIDs(t *testing.T) {
+	// ID не в формате UUID отбрасываются до запроса: иначе Postgres провалит его целиком.
+	// Без ID условие с пустым массивом не выполняется, и результат пуст, как в памяти
+	b := &sqlBuilder{}
+	applyCommentFilter(b, &model.CommentFilter{AuthorIds: []string{"alice"}, PostIds: []string{"1; DROP TABLE comment"}})
+	query := b.build("SELECT id FROM comment", "", nil, nil)
+	assert.Equal(t, "SELECT id FROM comment WHERE comment.author_id = ANY($1::uuid[]) AND comment.post_id = ANY($2::uuid[])", query)
+	assert.Equal(t, []interface{}{pq.Array([]string{}), pq.Array([]string{})}, b.args)
+
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "p1", "post", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	posts, err := s.GetAllPosts(ctx, &model.PostFilter{AuthorIds: []string{"not-a-uuid"}}, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+	comments, err := s.GetAllComments(ctx, &model.CommentFilter{PostIds: []string{"not-a-uuid"}}, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+}
+
+func TestInMemoryFilters(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	alice, _ := s.UserCreate(ctx, "alice", "hash")
+	bob, _ := s.UserCreate(ctx, "bob", "hash")
+	_, err := s.CreatePost(ctx, "p1", "Spam offer", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "p2", "Regular post", bob.ID, false, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "p3", "Another SPAM post", bob.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	parent, err := s.CreateComment(ctx, "first", "p1", bob.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "reply", parent.ID, alice.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	ids := func(posts []*model.Post) []string {
+		var result []string
+		for _, post := range posts {
+			result = append(result, post.ID)
+		}
+		return result
+	}
+	text, commentable, hasComments := "spam", true, false
+
+	posts, err := s.GetAllPosts(ctx, &model.PostFilter{TextContains: &text}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p1", "p3"}, ids(posts))
+
+	posts, err = s.GetAllPosts(ctx, &model.PostFilter{AuthorIds: []string{bob.ID}, Commentable: &commentable, HasComments: &hasComments}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p3"}, ids(posts))
+
+	limit, offset := 1, 1
+	posts, err = s.GetAllPosts(ctx, nil, &limit, &offset)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p2"}, ids(posts))
+
+	hasReplies := true
+	comments, err := s.GetAllComments(ctx, &model.CommentFilter{HasReplies: &hasReplies}, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, parent.ID, comments[0].ID)
+}
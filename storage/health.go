@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/lib/pq"
+)
+
+// Pinger - хранилище, которое умеет проверить доступность базы данных
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping проверяет, что хранилище готово обслуживать запросы. Хранилище без внешней базы
+// данных готово всегда
+func Ping(ctx context.Context, storage Storage) error {
+	if pinger, ok := storage.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Ping проверяет соединение с основной базой. Реплики на готовность не влияют: если они
+// недоступны, чтения идут в основную базу. Каждая реплика проверяется, чтобы исключить
+// недоступные из чтений
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	if err := s.DB.PingContext(ctx); err != nil {
+		return err
+	}
+	if s.replicas != nil {
+		for _, replica := range s.replicas.replicas {
+			if err := replica.db.PingContext(ctx); IsUnavailable(err) {
+				replica.markDown()
+			}
+		}
+	}
+	return nil
+}
+
+// Ping проверяет доступность хранилища под кэшем
+func (s *CachedStorage) Ping(ctx context.Context) error {
+	return Ping(ctx, s.Unwrap())
+}
+
+// IsUnavailable сообщает, что ошибка вызвана недоступностью базы данных - потерей соединения
+// или остановкой сервера, а не самим запросом. Такой запрос можно повторить позже.
+// Отмена запроса и истекший срок контекста недоступностью не считаются
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// Не удалось установить соединение: сервер не запущен или недоступен по сети
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Класс 08 - ошибки соединения, 57P01-57P03 - сервер останавливается или еще запускается
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+	return false
+}
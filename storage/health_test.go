@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, want: true},
+		{name: "read timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, want: false},
+		{name: "canceled", err: fmt.Errorf("query: %w", context.Canceled), want: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: false},
+		{name: "wrapped", err: fmt.Errorf("storage.Migrate: %w", syscall.ECONNRESET), want: true},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "starting up", err: &pq.Error{Code: "57P03"}, want: true},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "wrong password", err: &pq.Error{Code: "28P01"}, want: false},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, want: false},
+		{name: "no rows", err: sql.ErrNoRows, want: false},
+		{name: "other", err: errors.New("boom"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsUnavailable(tt.err))
+		})
+	}
+}
+
+func TestPingInMemory(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, Ping(ctx, NewInMemoryStorage()))
+	assert.NoError(t, Ping(ctx, NewCachedStorage(NewInMemoryStorage(), NewLRUCache(10, 0))))
+}
+
+// connector подключается без базы данных или возвращает заданную ошибку
+type connector struct{ err error }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return conn{}, nil
+}
+
+func (c connector) Driver() driver.Driver { return nil }
+
+type conn struct{}
+
+func (conn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (conn) Close() error                        { return nil }
+func (conn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func TestPostgresPing(t *testing.T) {
+	ctx := context.Background()
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	open := func(err error) *sql.DB {
+		db := sql.OpenDB(connector{err: err})
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	// Без основной базы хранилище не готово, даже если реплики отвечают
+	s := &PostgresStorage{DB: open(refused), replicas: newReplicaSet([]*sql.DB{open(nil)}, time.Second)}
+	assert.Error(t, s.Ping(ctx))
+
+	// Недоступные реплики не мешают: чтения идут в основную базу. Проверяются все реплики,
+	// и недоступные исключаются из чтений
+	s = &PostgresStorage{DB: open(nil), replicas: newReplicaSet([]*sql.DB{open(nil), open(refused), open(refused)}, time.Second)}
+	assert.NoError(t, s.Ping(ctx))
+	assert.True(t, s.replicas.replicas[0].available(time.Now()))
+	assert.False(t, s.replicas.replicas[1].available(time.Now()))
+	assert.False(t, s.replicas.replicas[2].available(time.Now()))
+	assert.Same(t, s.DB, s.reader(ctx).(*replicaReader).primary)
+
+	s.replicas.replicas[0].markDown()
+	assert.Same(t, s.DB, s.reader(ctx))
+
+	s = &PostgresStorage{DB: open(nil)}
+	assert.NoError(t, s.Ping(ctx))
+}
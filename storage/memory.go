@@ -4,27 +4,71 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/internal/hashtag"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/google/uuid"
 )
 
 // InMemoryStorage представляет собой структуру хранения данных в памяти
 type InMemoryStorage struct {
-	users    map[string]*model.User
-	posts    map[string]*model.Post
-	comments map[string]*model.CommentResponse
-	mu       sync.RWMutex
+	*memoryData
+	// journal задан у хранилища, переданного в WithTx: блокировка уже взята транзакцией,
+	// а изменения записываются в журнал для отката
+	journal *journal
+}
+
+// memoryData - данные хранилища, общие для самого хранилища и его транзакций
+type memoryData struct {
+	users          map[string]*model.User
+	posts          map[string]*model.Post
+	comments       map[string]*model.CommentResponse
+	follows        map[followKey]time.Time
+	notifications  map[string]*model.Notification
+	mentions       map[string][]*model.Mention
+	tags           map[string]map[string]time.Time
+	explicitTags   map[string][]string
+	reactions      map[reactionKey]struct{}
+	reactionCounts map[string]map[model.ReactionKind]int
+	index          *searchIndex
+	mu             sync.RWMutex
+}
+
+// followKey - подписка followerID на followeeID
+type followKey struct {
+	followerID string
+	followeeID string
+}
+
+// reactionKey - реакция одного вида одного пользователя на один объект
+type reactionKey struct {
+	itemID string
+	userID string
+	kind   model.ReactionKind
 }
 
 // NewInMemoryStorage возвращает новый объект InMemoryStorage
 func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		users:    make(map[string]*model.User),
-		posts:    make(map[string]*model.Post),
-		comments: make(map[string]*model.CommentResponse),
-	}
+	return &InMemoryStorage{memoryData: &memoryData{
+		users:          make(map[string]*model.User),
+		posts:          make(map[string]*model.Post),
+		comments:       make(map[string]*model.CommentResponse),
+		follows:        make(map[followKey]time.Time),
+		notifications:  make(map[string]*model.Notification),
+		mentions:       make(map[string][]*model.Mention),
+		tags:           make(map[string]map[string]time.Time),
+		explicitTags:   make(map[string][]string),
+		reactions:      make(map[reactionKey]struct{}),
+		reactionCounts: make(map[string]map[model.ReactionKind]int),
+		index:          newSearchIndex(),
+	}}
 }
 
 // InitInMemoryStorage инициализирует хранилище в памяти с начальными данными
@@ -36,80 +80,140 @@ func InitInMemoryStorage() *InMemoryStorage {
 	return storage
 }
 
-// GetUserByUsername возвращает пользователя по имени пользователя
+// GetUserByUsername возвращает пользователя по имени пользователя. Пользователи возвращаются
+// копиями: usecase дополняет их постами и комментариями, и общий экземпляр менялся бы
+// одновременно из разных запросов
 func (s *InMemoryStorage) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
+	if user := s.userByUsername(username); user != nil {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+// userByUsername ищет пользователя по имени без учета регистра. Вызывающий держит блокировку
+func (s *InMemoryStorage) userByUsername(username string) *model.User {
+	key := strings.ToLower(username)
 	for _, user := range s.users {
-		if user.Username == username {
-			return user, nil
+		if strings.ToLower(user.Username) == key {
+			return user
 		}
 	}
-	return nil, fmt.Errorf("user not found")
+	return nil
 }
 
-// UserCreate создает нового пользователя
+// UserCreate создает нового пользователя. Проверка имени и вставка выполняются под одной
+// блокировкой, поэтому одновременные регистрации одного имени не проходят обе
 func (s *InMemoryStorage) UserCreate(ctx context.Context, username string, password string) (*model.User, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.lock()
+	defer s.unlock()
+	if s.userByUsername(username) != nil {
+		return nil, ErrUsernameTaken
+	}
 	id := uuid.New().String()
-	user := &model.User{ID: id, Username: username, Password: password}
+	user := &model.User{ID: id, Username: username, Password: password, Role: model.UserRoleUser}
+	remember(s.journal, s.users, id)
 	s.users[id] = user
-	return user, nil
+	copied := *user
+	return &copied, nil
 }
 
 // GetUserByID возвращает пользователя по его ID
 func (s *InMemoryStorage) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 	user, exists := s.users[userID]
 	if !exists {
 		return nil, fmt.Errorf("user not found")
 	}
-	return user, nil
+	copied := *user
+	return &copied, nil
 }
 
 // GetAllUsers возвращает всех пользователей
 func (s *InMemoryStorage) GetAllUsers(ctx context.Context) ([]*model.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 	users := make([]*model.User, 0, len(s.users))
 	for _, user := range s.users {
-		users = append(users, user)
+		copied := *user
+		users = append(users, &copied)
 	}
 	return users, nil
 }
 
-// GetAllPosts возвращает все посты с поддержкой пагинации
-func (s *InMemoryStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// SetUserRole меняет роль пользователя
+func (s *InMemoryStorage) SetUserRole(ctx context.Context, userID string, role model.UserRole) (*model.User, error) {
+	return s.updateUser(userID, func(user *model.User) { user.Role = role })
+}
 
-	var posts []*model.Post
-	for _, post := range s.posts {
-		posts = append(posts, post)
+// SetUserDisabled блокирует или разблокирует пользователя
+func (s *InMemoryStorage) SetUserDisabled(ctx context.Context, userID string, disabled bool) (*model.User, error) {
+	return s.updateUser(userID, func(user *model.User) { user.Disabled = disabled })
+}
+
+// SetUserPassword заменяет хэш пароля пользователя
+func (s *InMemoryStorage) SetUserPassword(ctx context.Context, userID string, password string) (*model.User, error) {
+	return s.updateUser(userID, func(user *model.User) { user.Password = password })
+}
+
+// IsUserDisabled сообщает, заблокирован ли пользователь
+func (s *InMemoryStorage) IsUserDisabled(ctx context.Context, userID string) (bool, error) {
+	s.rlock()
+	defer s.runlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return false, fmt.Errorf("user not found")
 	}
+	return user.Disabled, nil
+}
 
-	// Пагинация
-	if limit != nil && offset != nil {
-		start := *offset
-		end := *offset + *limit
-		if start > len(posts) {
-			return []*model.Post{}, nil
-		}
-		if end > len(posts) {
-			end = len(posts)
+// updateUser заменяет пользователя измененной копией, чтобы при откате транзакции вернуть прежнюю
+func (s *InMemoryStorage) updateUser(userID string, update func(user *model.User)) (*model.User, error) {
+	s.lock()
+	defer s.unlock()
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, fmt.Errorf("user not found")
+	}
+	updated := *user
+	update(&updated)
+	remember(s.journal, s.users, userID)
+	s.users[userID] = &updated
+	copied := updated
+	return &copied, nil
+}
+
+// GetAllPosts возвращает посты, подходящие под фильтр, с поддержкой пагинации
+func (s *InMemoryStorage) GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	s.rlock()
+	defer s.runlock()
+
+	commented := make(map[string]bool)
+	for _, comment := range s.comments {
+		commented[comment.PostID] = true
+	}
+
+	posts := []*model.Post{}
+	for _, post := range s.posts {
+		if matchPost(filter, post, commented[post.ID]) {
+			posts = append(posts, post)
 		}
-		posts = posts[start:end]
 	}
+	// Порядок как в PostgresStorage: по времени создания, затем по ID
+	sort.Slice(posts, func(i, j int) bool {
+		return createdBefore(posts[i].CreatedAt, posts[i].ID, posts[j].CreatedAt, posts[j].ID)
+	})
 
-	return posts, nil
+	return copyPosts(paginate(posts, limit, offset)), nil
 }
 
 // GetPostsByUserID возвращает посты по ID пользователя с поддержкой пагинации
 func (s *InMemoryStorage) GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 
 	var posts []*model.Post
 	for _, post := range s.posts {
@@ -131,118 +235,210 @@ func (s *InMemoryStorage) GetPostsByUserID(ctx context.Context, userID string, l
 		posts = posts[start:end]
 	}
 
-	return posts, nil
+	return copyPosts(posts), nil
 }
 
 // GetPostByID возвращает пост по его ID
 func (s *InMemoryStorage) GetPostByID(ctx context.Context, postID string) (*model.Post, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 	post, exists := s.posts[postID]
 	if !exists {
 		return nil, fmt.Errorf("post not found")
 	}
-	return post, nil
+	return copyPost(post), nil
 }
 
 // CreatePost создает новый пост
-func (s *InMemoryStorage) CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	post := &model.Post{ID: id, Text: text, AuthorID: authorID, Commentable: commentable}
+func (s *InMemoryStorage) CreatePost(ctx context.Context, id, text, authorID string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	s.lock()
+	defer s.unlock()
+	post := &model.Post{ID: id, Text: text, AuthorID: authorID, Commentable: commentable, Format: format, CreatedAt: time.Now()}
+	remember(s.journal, s.posts, id)
 	s.posts[id] = post
-	return post, nil
+	s.reindex(id, model.SearchTypePost, text)
+	return copyPost(post), nil
 }
 
-// GetAllComments возвращает все комментарии с поддержкой пагинации
-func (s *InMemoryStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	comments := make([]*model.CommentResponse, 0, len(s.comments))
-	for _, comment := range s.comments {
-		comments = append(comments, comment)
+// UpdatePost меняет текст, его формат и возможность комментирования поста. Сохраненный пост заменяется
+// копией, поэтому ранее выданные указатели на пост не меняются
+func (s *InMemoryStorage) UpdatePost(ctx context.Context, id, text string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	s.lock()
+	defer s.unlock()
+	post, exists := s.posts[id]
+	if !exists {
+		return nil, fmt.Errorf("post not found")
 	}
-	return comments, nil
+	updated := *post
+	updated.Text = text
+	updated.Commentable = commentable
+	updated.Format = format
+	remember(s.journal, s.posts, id)
+	s.posts[id] = &updated
+	s.reindex(id, model.SearchTypePost, text)
+	return &updated, nil
 }
 
-// GetCommentsByPostID возвращает комментарии по ID поста с поддержкой пагинации
-func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var comments []*model.CommentResponse
-	for _, comment := range s.comments {
-		if comment.PostID == postID {
-			comments = append(comments, comment)
+// DeletePost удаляет пост вместе с его комментариями, их реакциями, упоминаниями и тегами
+// поста, а также уведомлениями о посте и его комментариях
+func (s *InMemoryStorage) DeletePost(ctx context.Context, id string) error {
+	s.lock()
+	defer s.unlock()
+	if _, exists := s.posts[id]; !exists {
+		return fmt.Errorf("post not found")
+	}
+	items := map[string]struct{}{id: {}}
+	for commentID, comment := range s.comments {
+		if comment.PostID == id {
+			items[commentID] = struct{}{}
+		}
+	}
+
+	remember(s.journal, s.posts, id)
+	delete(s.posts, id)
+	remember(s.journal, s.tags, id)
+	delete(s.tags, id)
+	remember(s.journal, s.explicitTags, id)
+	delete(s.explicitTags, id)
+	for itemID := range items {
+		remember(s.journal, s.comments, itemID)
+		delete(s.comments, itemID)
+		remember(s.journal, s.mentions, itemID)
+		delete(s.mentions, itemID)
+		remember(s.journal, s.reactionCounts, itemID)
+		delete(s.reactionCounts, itemID)
+		s.unindex(itemID)
+	}
+	for key := range s.reactions {
+		if _, exists := items[key.itemID]; exists {
+			remember(s.journal, s.reactions, key)
+			delete(s.reactions, key)
 		}
 	}
+	for notificationID, notification := range s.notifications {
+		if notification.PostID == id {
+			remember(s.journal, s.notifications, notificationID)
+			delete(s.notifications, notificationID)
+		}
+	}
+	return nil
+}
+
+// GetAllComments возвращает комментарии, подходящие под фильтр, с поддержкой пагинации
+func (s *InMemoryStorage) GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error) {
+	s.rlock()
+	defer s.runlock()
 
-	start := 0
-	if offset != nil {
-		start = *offset
+	replied := make(map[string]bool)
+	for _, comment := range s.comments {
+		if comment.ParentCommentID != nil {
+			replied[*comment.ParentCommentID] = true
+		}
 	}
-	end := len(comments)
-	if limit != nil && start+*limit < end {
-		end = start + *limit
+
+	comments := []*model.CommentResponse{}
+	for _, comment := range s.comments {
+		if matchComment(filter, comment, replied[comment.ID]) {
+			comments = append(comments, comment)
+		}
 	}
+	sort.Slice(comments, func(i, j int) bool {
+		return createdBefore(comments[i].CreatedAt, comments[i].ID, comments[j].CreatedAt, comments[j].ID)
+	})
 
-	return comments[start:end], nil
+	return copyComments(paginate(comments, limit, offset)), nil
 }
 
-// GetCommentsByParentID возвращает ответы на комментарии по ID родительского комментария с поддержкой пагинации
-func (s *InMemoryStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	var comments []*model.CommentResponse
+// GetCommentsByPostID возвращает комментарии к посту в заданном порядке с поддержкой пагинации
+func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	s.rlock()
+	defer s.runlock()
+	comments := []*model.CommentResponse{}
 	for _, comment := range s.comments {
-		if comment.ParentCommentID != nil && *comment.ParentCommentID == parentID {
+		if comment.PostID == postID {
 			comments = append(comments, comment)
 		}
 	}
+	s.sortComments(comments, sort)
+	return copyComments(paginate(comments, limit, offset)), nil
+}
 
-	// Применяем лимит и смещение, если они заданы
-	if limit != nil && offset != nil {
-		start := *offset
-		end := start + *limit
-		if start > len(comments) {
-			return []*model.CommentResponse{}, nil
-		}
-		if end > len(comments) {
-			end = len(comments)
+// GetCommentsByParentID возвращает ответы на комментарий в заданном порядке с поддержкой пагинации
+func (s *InMemoryStorage) GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	s.rlock()
+	defer s.runlock()
+	comments := []*model.CommentResponse{}
+	for _, comment := range s.comments {
+		if comment.ParentCommentID != nil && *comment.ParentCommentID == parentID {
+			comments = append(comments, comment)
 		}
-		return comments[start:end], nil
 	}
+	s.sortComments(comments, sort)
+	return copyComments(paginate(comments, limit, offset)), nil
+}
 
-	return comments, nil
+// sortComments сортирует комментарии так же, как commentOrderSQL в PostgresStorage
+func (s *InMemoryStorage) sortComments(comments []*model.CommentResponse, order model.CommentSort) {
+	rank := func(c *model.CommentResponse) float64 {
+		up, down := s.reactionCounts[c.ID][upvoteReaction], s.reactionCounts[c.ID][downvoteReaction]
+		switch order {
+		case model.CommentSortTop:
+			return commentScore(up, down)
+		case model.CommentSortControversial:
+			return commentControversy(up, down)
+		}
+		return 0
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		switch order {
+		case model.CommentSortNew:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		case model.CommentSortTop, model.CommentSortControversial:
+			if rankA, rankB := rank(a), rank(b); rankA != rankB {
+				return rankA > rankB
+			}
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		default:
+			return createdBefore(a.CreatedAt, a.ID, b.CreatedAt, b.ID)
+		}
+	})
 }
 
 // GetCommentByID возвращает комментарий по его ID
 func (s *InMemoryStorage) GetCommentByID(ctx context.Context, id string) (*model.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 	comment, exists := s.comments[id]
 	if !exists {
 		return nil, fmt.Errorf("comment not found")
 	}
-	return comment, nil
+	return copyComment(comment), nil
 }
 
 // GetCommentsByUserID возвращает комментарии по ID пользователя
 func (s *InMemoryStorage) GetCommentsByUserID(ctx context.Context, userID string) ([]*model.CommentResponse, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.rlock()
+	defer s.runlock()
 	var comments []*model.CommentResponse
 	for _, comment := range s.comments {
 		if comment.AuthorID == userID {
 			comments = append(comments, comment)
 		}
 	}
-	return comments, nil
+	return copyComments(comments), nil
 }
 
 // CreateComment создает новый комментарий
-func (s *InMemoryStorage) CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *InMemoryStorage) CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error) {
+	s.lock()
+	defer s.unlock()
 
 	var isReply bool
 	var parentCommentID *string
@@ -271,12 +467,513 @@ func (s *InMemoryStorage) CreateComment(ctx context.Context, commentText, itemId
 	var newComment *model.CommentResponse
 	if isReply {
 		// Если это ответ на комментарий
-		newComment = &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, ParentCommentID: parentCommentID}
+		newComment = &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, ParentCommentID: parentCommentID, Format: format, CreatedAt: time.Now()}
 	} else {
 		// Если это комментарий к посту
-		newComment = &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID}
+		newComment = &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, Format: format, CreatedAt: time.Now()}
 	}
+	remember(s.journal, s.comments, id)
 	s.comments[id] = newComment
+	s.reindex(id, model.SearchTypeComment, commentText)
+
+	return copyComment(newComment), nil
+}
+
+// Search ищет посты и комментарии по инвертированному индексу и возвращает страницу результатов по убыванию релевантности
+func (s *InMemoryStorage) Search(ctx context.Context, query string, types []model.SearchType, limit, offset int) ([]*model.SearchHit, error) {
+	s.rlock()
+	defer s.runlock()
+
+	results := s.index.search(query, types)
+	if offset < 0 || offset >= len(results) {
+		return []*model.SearchHit{}, nil
+	}
+	results = results[offset:]
+	if limit < len(results) {
+		results = results[:limit]
+	}
+
+	hits := make([]*model.SearchHit, 0, len(results))
+	for _, result := range results {
+		hit := &model.SearchHit{Snippet: s.index.snippet(result.id, query), Score: result.score}
+		if result.kind == model.SearchTypePost {
+			hit.Node = copyPost(s.posts[result.id])
+		} else {
+			hit.Node = copyComment(s.comments[result.id])
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// Follow подписывает followerID на followeeID. Повторная подписка ничего не меняет
+func (s *InMemoryStorage) Follow(ctx context.Context, followerID, followeeID string) error {
+	s.lock()
+	defer s.unlock()
+	if _, exists := s.users[followeeID]; !exists {
+		return fmt.Errorf("user not found")
+	}
+	key := followKey{followerID: followerID, followeeID: followeeID}
+	if _, exists := s.follows[key]; !exists {
+		remember(s.journal, s.follows, key)
+		s.follows[key] = time.Now()
+	}
+	return nil
+}
+
+// Unfollow отменяет подписку followerID на followeeID
+func (s *InMemoryStorage) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	s.lock()
+	defer s.unlock()
+	key := followKey{followerID: followerID, followeeID: followeeID}
+	remember(s.journal, s.follows, key)
+	delete(s.follows, key)
+	return nil
+}
+
+// GetFollowers возвращает подписчиков пользователя от новых подписок к старым
+func (s *InMemoryStorage) GetFollowers(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error) {
+	s.rlock()
+	defer s.runlock()
+	var follows []*model.Follow
+	for key, followedAt := range s.follows {
+		if key.followeeID == userID {
+			follows = append(follows, &model.Follow{User: s.users[key.followerID], FollowedAt: followedAt})
+		}
+	}
+	return pageFollows(follows, after, limit), nil
+}
+
+// GetFollowing возвращает пользователей, на которых подписан пользователь, от новых подписок к старым
+func (s *InMemoryStorage) GetFollowing(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error) {
+	s.rlock()
+	defer s.runlock()
+	var follows []*model.Follow
+	for key, followedAt := range s.follows {
+		if key.followerID == userID {
+			follows = append(follows, &model.Follow{User: s.users[key.followeeID], FollowedAt: followedAt})
+		}
+	}
+	return pageFollows(follows, after, limit), nil
+}
+
+// GetFeed возвращает посты пользователей, на которых подписан userID, от новых к старым
+func (s *InMemoryStorage) GetFeed(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Post, error) {
+	s.rlock()
+	defer s.runlock()
+	posts := []*model.Post{}
+	for _, post := range s.posts {
+		if _, following := s.follows[followKey{followerID: userID, followeeID: post.AuthorID}]; !following {
+			continue
+		}
+		key := cursor.Key{CreatedAt: post.CreatedAt, ID: post.ID}
+		if after == nil || key.Less(*after) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return cursor.Key{CreatedAt: posts[j].CreatedAt, ID: posts[j].ID}.Less(cursor.Key{CreatedAt: posts[i].CreatedAt, ID: posts[i].ID})
+	})
+	return copyPosts(paginate(posts, &limit, nil)), nil
+}
+
+// SetPostTags заменяет теги поста: теги из текста и явно переданные. Явные теги запоминаются
+// отдельно, чтобы при смене текста пересчитывались только теги из него. У тегов, которые
+// уже были у поста, сохраняется время добавления
+func (s *InMemoryStorage) SetPostTags(ctx context.Context, postID string, fromText, explicit []string) error {
+	s.lock()
+	defer s.unlock()
+	if _, exists := s.posts[postID]; !exists {
+		return fmt.Errorf("post not found")
+	}
+	now := time.Now()
+	updated := make(map[string]time.Time, len(fromText)+len(explicit))
+	for _, tag := range hashtag.Merge(fromText, explicit) {
+		taggedAt, exists := s.tags[postID][tag]
+		if !exists {
+			taggedAt = now
+		}
+		updated[tag] = taggedAt
+	}
+	remember(s.journal, s.tags, postID)
+	s.tags[postID] = updated
+	remember(s.journal, s.explicitTags, postID)
+	if len(explicit) == 0 {
+		delete(s.explicitTags, postID)
+	} else {
+		stored := hashtag.Merge(explicit)
+		slices.Sort(stored)
+		s.explicitTags[postID] = stored
+	}
+	return nil
+}
+
+// GetPostTags возвращает теги поста по алфавиту
+func (s *InMemoryStorage) GetPostTags(ctx context.Context, postID string) ([]string, error) {
+	s.rlock()
+	defer s.runlock()
+	tags := make([]string, 0, len(s.tags[postID]))
+	for tag := range s.tags[postID] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// GetPostExplicitTags возвращает явно переданные теги поста по алфавиту
+func (s *InMemoryStorage) GetPostExplicitTags(ctx context.Context, postID string) ([]string, error) {
+	s.rlock()
+	defer s.runlock()
+	return append([]string{}, s.explicitTags[postID]...), nil
+}
+
+// GetPostsByTag возвращает посты с тегом от новых к старым
+func (s *InMemoryStorage) GetPostsByTag(ctx context.Context, tag string, after *cursor.Key, limit int) ([]*model.Post, error) {
+	s.rlock()
+	defer s.runlock()
+	key := func(p *model.Post) cursor.Key {
+		return cursor.Key{CreatedAt: p.CreatedAt, ID: p.ID}
+	}
+	posts := []*model.Post{}
+	for postID, tags := range s.tags {
+		if _, tagged := tags[tag]; !tagged {
+			continue
+		}
+		post := s.posts[postID]
+		if after == nil || key(post).Less(*after) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return key(posts[j]).Less(key(posts[i])) })
+	return copyPosts(paginate(posts, &limit, nil)), nil
+}
+
+// GetTrendingTags возвращает теги, чаще всего добавлявшиеся к постам после since.
+// При равном числе использований теги идут по алфавиту
+func (s *InMemoryStorage) GetTrendingTags(ctx context.Context, since time.Time, limit int) ([]*model.TrendingTag, error) {
+	s.rlock()
+	defer s.runlock()
+	counts := map[string]int{}
+	for _, tags := range s.tags {
+		for tag, taggedAt := range tags {
+			if taggedAt.After(since) {
+				counts[tag]++
+			}
+		}
+	}
+	trending := make([]*model.TrendingTag, 0, len(counts))
+	for tag, count := range counts {
+		trending = append(trending, &model.TrendingTag{Tag: tag, Count: count})
+	}
+	sort.Slice(trending, func(i, j int) bool {
+		if trending[i].Count != trending[j].Count {
+			return trending[i].Count > trending[j].Count
+		}
+		return trending[i].Tag < trending[j].Tag
+	})
+	return paginate(trending, &limit, nil), nil
+}
+
+// SaveMentions заменяет упоминания в посте или комментарии
+func (s *InMemoryStorage) SaveMentions(ctx context.Context, itemID string, mentions []*model.Mention) error {
+	s.lock()
+	defer s.unlock()
+	if !s.itemExists(itemID) {
+		return errors.New("item not found")
+	}
+	remember(s.journal, s.mentions, itemID)
+	if len(mentions) == 0 {
+		delete(s.mentions, itemID)
+		return nil
+	}
+	stored := make([]*model.Mention, 0, len(mentions))
+	for _, m := range mentions {
+		mention := *m
+		stored = append(stored, &mention)
+	}
+	s.mentions[itemID] = stored
+	return nil
+}
+
+// GetMentions возвращает упоминания в посте или комментарии в порядке следования в тексте.
+// Упоминание пользователя, которого уже нет, возвращается без UserID, как ON DELETE SET NULL в Postgres
+func (s *InMemoryStorage) GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error) {
+	s.rlock()
+	defer s.runlock()
+	mentions := make([]*model.Mention, 0, len(s.mentions[itemID]))
+	for _, m := range s.mentions[itemID] {
+		mention := *m
+		if mention.UserID != nil {
+			if _, exists := s.users[*mention.UserID]; !exists {
+				mention.UserID = nil
+			}
+		}
+		mentions = append(mentions, &mention)
+	}
+	sort.Slice(mentions, func(i, j int) bool { return mentions[i].Offset < mentions[j].Offset })
+	return mentions, nil
+}
+
+// GetMentionedIn возвращает посты и комментарии с упоминанием пользователя от новых к старым
+func (s *InMemoryStorage) GetMentionedIn(ctx context.Context, userID string, after *cursor.Key, limit int) ([]model.MentionSource, error) {
+	s.rlock()
+	defer s.runlock()
+	type item struct {
+		key  cursor.Key
+		node model.MentionSource
+	}
+	var items []item
+	for itemID, mentions := range s.mentions {
+		mentioned := false
+		for _, m := range mentions {
+			if m.UserID != nil && *m.UserID == userID {
+				mentioned = true
+				break
+			}
+		}
+		if !mentioned {
+			continue
+		}
+		var it item
+		if post, exists := s.posts[itemID]; exists {
+			it = item{key: cursor.Key{CreatedAt: post.CreatedAt, ID: post.ID}, node: copyPost(post)}
+		} else if comment, exists := s.comments[itemID]; exists {
+			it = item{key: cursor.Key{CreatedAt: comment.CreatedAt, ID: comment.ID}, node: copyComment(comment)}
+		} else {
+			continue
+		}
+		if after == nil || it.key.Less(*after) {
+			items = append(items, it)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[j].key.Less(items[i].key) })
+	items = paginate(items, &limit, nil)
+	nodes := make([]model.MentionSource, 0, len(items))
+	for _, it := range items {
+		nodes = append(nodes, it.node)
+	}
+	return nodes, nil
+}
+
+// CreateNotifications сохраняет уведомления, заполняя их ID и время создания
+func (s *InMemoryStorage) CreateNotifications(ctx context.Context, notifications []*model.Notification) error {
+	s.lock()
+	defer s.unlock()
+	now := time.Now()
+	for _, notification := range notifications {
+		notification.ID = uuid.New().String()
+		notification.CreatedAt = now
+		stored := *notification
+		remember(s.journal, s.notifications, stored.ID)
+		s.notifications[stored.ID] = &stored
+	}
+	return nil
+}
+
+// GetNotifications возвращает уведомления пользователя от новых к старым
+func (s *InMemoryStorage) GetNotifications(ctx context.Context, userID string, unreadOnly bool, after *cursor.Key, limit int) ([]*model.Notification, error) {
+	s.rlock()
+	defer s.runlock()
+	key := func(n *model.Notification) cursor.Key {
+		return cursor.Key{CreatedAt: n.CreatedAt, ID: n.ID}
+	}
+	notifications := []*model.Notification{}
+	for _, notification := range s.notifications {
+		if notification.RecipientID != userID || (unreadOnly && notification.Read) {
+			continue
+		}
+		if after == nil || key(notification).Less(*after) {
+			// Копия, чтобы отметка о прочтении не меняла уже выданные объекты
+			n := *notification
+			notifications = append(notifications, &n)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return key(notifications[j]).Less(key(notifications[i]))
+	})
+	return paginate(notifications, &limit, nil), nil
+}
+
+// CountUnreadNotifications возвращает число непрочитанных уведомлений пользователя
+func (s *InMemoryStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	s.rlock()
+	defer s.runlock()
+	count := 0
+	for _, notification := range s.notifications {
+		if notification.RecipientID == userID && !notification.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MarkNotificationsRead отмечает прочитанными уведомления пользователя с указанными ID,
+// а если ids равен nil - все его уведомления. Возвращает число отмеченных уведомлений
+func (s *InMemoryStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	s.lock()
+	defer s.unlock()
+	marked := 0
+	// Уведомление заменяется копией, а не меняется на месте, чтобы откат транзакции мог вернуть прежнее
+	mark := func(notification *model.Notification) {
+		if notification.RecipientID == userID && !notification.Read {
+			read := *notification
+			read.Read = true
+			remember(s.journal, s.notifications, read.ID)
+			s.notifications[read.ID] = &read
+			marked++
+		}
+	}
+	if ids == nil {
+		for _, notification := range s.notifications {
+			mark(notification)
+		}
+		return marked, nil
+	}
+	for _, id := range ids {
+		if notification, exists := s.notifications[id]; exists {
+			mark(notification)
+		}
+	}
+	return marked, nil
+}
+
+// pageFollows сортирует подписки от новых к старым и возвращает страницу после ключа after
+func pageFollows(follows []*model.Follow, after *cursor.Key, limit int) []*model.Follow {
+	key := func(f *model.Follow) cursor.Key {
+		return cursor.Key{CreatedAt: f.FollowedAt, ID: f.User.ID}
+	}
+	page := []*model.Follow{}
+	for _, f := range follows {
+		if after == nil || key(f).Less(*after) {
+			page = append(page, f)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool {
+		return key(page[j]).Less(key(page[i]))
+	})
+	return paginate(page, &limit, nil)
+}
+
+// itemExists проверяет, что itemID - существующий пост или комментарий
+func (s *InMemoryStorage) itemExists(itemID string) bool {
+	if _, exists := s.posts[itemID]; exists {
+		return true
+	}
+	_, exists := s.comments[itemID]
+	return exists
+}
+
+// React ставит реакцию на пост или комментарий. Повторная реакция того же вида ничего не меняет
+func (s *InMemoryStorage) React(ctx context.Context, itemID, userID string, kind model.ReactionKind) error {
+	s.lock()
+	defer s.unlock()
+	if !s.itemExists(itemID) {
+		return errors.New("item not found")
+	}
+	key := reactionKey{itemID: itemID, userID: userID, kind: kind}
+	if _, exists := s.reactions[key]; exists {
+		return nil
+	}
+	remember(s.journal, s.reactions, key)
+	s.reactions[key] = struct{}{}
+	s.addReactionCount(itemID, kind, 1)
+	return nil
+}
+
+// Unreact снимает реакцию и уменьшает счетчик, если реакция была
+func (s *InMemoryStorage) Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) error {
+	s.lock()
+	defer s.unlock()
+	if !s.itemExists(itemID) {
+		return errors.New("item not found")
+	}
+	key := reactionKey{itemID: itemID, userID: userID, kind: kind}
+	if _, exists := s.reactions[key]; !exists {
+		return nil
+	}
+	remember(s.journal, s.reactions, key)
+	delete(s.reactions, key)
+	s.addReactionCount(itemID, kind, -1)
+	return nil
+}
+
+// addReactionCount меняет счетчик реакций объекта. Счетчики объекта заменяются копией,
+// а не меняются на месте, чтобы откат транзакции мог вернуть прежние
+func (s *InMemoryStorage) addReactionCount(itemID string, kind model.ReactionKind, delta int) {
+	counts := make(map[model.ReactionKind]int, len(s.reactionCounts[itemID])+1)
+	maps.Copy(counts, s.reactionCounts[itemID])
+	counts[kind] += delta
+	remember(s.journal, s.reactionCounts, itemID)
+	s.reactionCounts[itemID] = counts
+}
+
+// GetReactionCounts возвращает ненулевые счетчики реакций объекта в порядке model.AllReactionKind
+func (s *InMemoryStorage) GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error) {
+	s.rlock()
+	defer s.runlock()
+	return orderedReactionCounts(s.reactionCounts[itemID]), nil
+}
 
-	return newComment, nil
+// GetUserReactions возвращает реакции пользователя на объект в порядке model.AllReactionKind
+func (s *InMemoryStorage) GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error) {
+	s.rlock()
+	defer s.runlock()
+	reacted := make(map[model.ReactionKind]bool)
+	for _, kind := range model.AllReactionKind {
+		if _, exists := s.reactions[reactionKey{itemID: itemID, userID: userID, kind: kind}]; exists {
+			reacted[kind] = true
+		}
+	}
+	return orderedReactionKinds(reacted), nil
+}
+
+// copyPost возвращает копию поста. Как и пользователи, посты и комментарии отдаются копиями,
+// потому что резольверы дополняют их авторами и комментариями
+func copyPost(post *model.Post) *model.Post {
+	copied := *post
+	return &copied
+}
+
+// copyPosts заменяет посты в срезе их копиями
+func copyPosts(posts []*model.Post) []*model.Post {
+	for i, post := range posts {
+		posts[i] = copyPost(post)
+	}
+	return posts
+}
+
+// copyComment возвращает копию комментария
+func copyComment(comment *model.CommentResponse) *model.CommentResponse {
+	copied := *comment
+	return &copied
+}
+
+// copyComments заменяет комментарии в срезе их копиями
+func copyComments(comments []*model.CommentResponse) []*model.CommentResponse {
+	for i, comment := range comments {
+		comments[i] = copyComment(comment)
+	}
+	return comments
+}
+
+// createdBefore сравнивает объекты по времени создания, а при равенстве - по ID
+func createdBefore(aCreatedAt time.Time, aID string, bCreatedAt time.Time, bID string) bool {
+	if !aCreatedAt.Equal(bCreatedAt) {
+		return aCreatedAt.Before(bCreatedAt)
+	}
+	return aID < bID
+}
+
+// paginate применяет к отсортированному списку смещение и лимит
+func paginate[T any](items []T, limit, offset *int) []T {
+	if offset != nil && *offset > 0 {
+		if *offset >= len(items) {
+			return items[:0]
+		}
+		items = items[*offset:]
+	}
+	if limit != nil && *limit >= 0 && *limit < len(items) {
+		items = items[:*limit]
+	}
+	return items
 }
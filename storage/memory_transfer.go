@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// RestoreUser сохраняет пользователя из выгрузки с его ID, ролью и хэшем пароля. Пользователь
+// с тем же ID и именем пропускается, с тем же ID и другим именем - ошибка ErrRestoreConflict
+func (s *InMemoryStorage) RestoreUser(ctx context.Context, user *model.User) (bool, error) {
+	s.lock()
+	defer s.unlock()
+	if existing, exists := s.users[user.ID]; exists {
+		if existing.Username != user.Username {
+			return false, fmt.Errorf("%w: user %s is %s", ErrRestoreConflict, user.ID, existing.Username)
+		}
+		return false, nil
+	}
+	if s.userByUsername(user.Username) != nil {
+		return false, ErrUsernameTaken
+	}
+	restored := &model.User{ID: user.ID, Username: user.Username, Password: user.Password, Role: user.Role, Disabled: user.Disabled}
+	remember(s.journal, s.users, user.ID)
+	s.users[user.ID] = restored
+	return true, nil
+}
+
+// RestorePost сохраняет пост из выгрузки с его ID и временем создания
+func (s *InMemoryStorage) RestorePost(ctx context.Context, post *model.Post) (bool, error) {
+	s.lock()
+	defer s.unlock()
+	if _, exists := s.posts[post.ID]; exists {
+		return false, nil
+	}
+	if _, exists := s.users[post.AuthorID]; !exists {
+		return false, fmt.Errorf("%w: author %s", ErrMissingReference, post.AuthorID)
+	}
+	restored := &model.Post{ID: post.ID, Text: post.Text, AuthorID: post.AuthorID, Commentable: post.Commentable, Format: post.Format, CreatedAt: post.CreatedAt}
+	remember(s.journal, s.posts, post.ID)
+	s.posts[post.ID] = restored
+	s.reindex(post.ID, model.SearchTypePost, post.Text)
+	return true, nil
+}
+
+// RestoreComment сохраняет комментарий из выгрузки с его ID, родителем и временем создания.
+// Запрет комментирования поста не проверяется: комментарий мог быть оставлен до запрета
+func (s *InMemoryStorage) RestoreComment(ctx context.Context, comment *model.CommentResponse) (bool, error) {
+	s.lock()
+	defer s.unlock()
+	if _, exists := s.comments[comment.ID]; exists {
+		return false, nil
+	}
+	if _, exists := s.users[comment.AuthorID]; !exists {
+		return false, fmt.Errorf("%w: author %s", ErrMissingReference, comment.AuthorID)
+	}
+	if _, exists := s.posts[comment.PostID]; !exists {
+		return false, fmt.Errorf("%w: post %s", ErrMissingReference, comment.PostID)
+	}
+	if comment.ParentCommentID != nil {
+		if _, exists := s.comments[*comment.ParentCommentID]; !exists {
+			return false, fmt.Errorf("%w: parent comment %s", ErrMissingReference, *comment.ParentCommentID)
+		}
+	}
+	restored := &model.CommentResponse{
+		ID:              comment.ID,
+		Comment:         comment.Comment,
+		AuthorID:        comment.AuthorID,
+		PostID:          comment.PostID,
+		ParentCommentID: comment.ParentCommentID,
+		Format:          comment.Format,
+		CreatedAt:       comment.CreatedAt,
+	}
+	remember(s.journal, s.comments, comment.ID)
+	s.comments[comment.ID] = restored
+	s.reindex(comment.ID, model.SearchTypeComment, comment.Comment)
+	return true, nil
+}
+
+// ScanUsers передает fn копии пользователей по возрастанию ID. Под блокировкой берется только
+// список указателей: записи не меняются на месте, а заменяются копиями
+func (s *InMemoryStorage) ScanUsers(ctx context.Context, fn func(*model.User) error) error {
+	s.rlock()
+	users := make([]*model.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	s.runlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, user := range users {
+		copied := *user
+		if err := fn(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanPosts передает fn копии постов по возрастанию ID
+func (s *InMemoryStorage) ScanPosts(ctx context.Context, fn func(*model.Post) error) error {
+	s.rlock()
+	posts := make([]*model.Post, 0, len(s.posts))
+	for _, post := range s.posts {
+		posts = append(posts, post)
+	}
+	s.runlock()
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	for _, post := range posts {
+		copied := *post
+		if err := fn(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanComments передает fn копии комментариев по времени создания, затем по ID
+func (s *InMemoryStorage) ScanComments(ctx context.Context, fn func(*model.CommentResponse) error) error {
+	s.rlock()
+	comments := make([]*model.CommentResponse, 0, len(s.comments))
+	for _, comment := range s.comments {
+		comments = append(comments, comment)
+	}
+	s.runlock()
+	sort.Slice(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+		}
+		return comments[i].ID < comments[j].ID
+	})
+	for _, comment := range comments {
+		copied := *comment
+		if err := fn(&copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
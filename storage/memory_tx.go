@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// journal - журнал изменений транзакции in-memory хранилища. Для каждого изменения хранится
+// функция, которая его отменяет
+type journal struct {
+	undo []func()
+}
+
+// onRollback добавляет в журнал функцию отмены. Вне транзакции журнала нет, и вызов ничего не делает
+func (j *journal) onRollback(fn func()) {
+	if j != nil {
+		j.undo = append(j.undo, fn)
+	}
+}
+
+// rollback отменяет изменения в обратном порядке
+func (j *journal) rollback() {
+	for i := len(j.undo) - 1; i >= 0; i-- {
+		j.undo[i]()
+	}
+	j.undo = nil
+}
+
+// remember запоминает значение ключа key в m, чтобы откат вернул его или удалил ключ, если его не было.
+// Поэтому значения в map хранилища заменяются целиком и не меняются на месте
+func remember[K comparable, V any](j *journal, m map[K]V, key K) {
+	if j == nil {
+		return
+	}
+	old, existed := m[key]
+	j.onRollback(func() {
+		if existed {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+// WithTx выполняет fn под блокировкой хранилища на запись. Если fn возвращает ошибку или паникует,
+// все изменения, сделанные через tx, отменяются по журналу. Вложенный вызов выполняется в той же транзакции.
+// Внутри fn нужно обращаться только к tx: обращение к самому хранилищу заблокируется
+func (s *InMemoryStorage) WithTx(ctx context.Context, fn func(tx Storage) error) (err error) {
+	if s.journal != nil {
+		return fn(s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &InMemoryStorage{memoryData: s.memoryData, journal: &journal{}}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.journal.rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.journal.rollback()
+		}
+	}()
+	return fn(tx)
+}
+
+// Методы хранилища берут блокировку сами, кроме вызовов внутри транзакции, которая уже держит ее на запись
+
+func (s *InMemoryStorage) lock() {
+	if s.journal == nil {
+		s.mu.Lock()
+	}
+}
+
+func (s *InMemoryStorage) unlock() {
+	if s.journal == nil {
+		s.mu.Unlock()
+	}
+}
+
+func (s *InMemoryStorage) rlock() {
+	if s.journal == nil {
+		s.mu.RLock()
+	}
+}
+
+func (s *InMemoryStorage) runlock() {
+	if s.journal == nil {
+		s.mu.RUnlock()
+	}
+}
+
+// reindex заменяет текст объекта в поисковом индексе, запоминая прежний для отката
+func (s *InMemoryStorage) reindex(id string, kind model.SearchType, text string) {
+	s.rememberIndex(id)
+	s.index.remove(id)
+	s.index.add(id, kind, text)
+}
+
+// unindex удаляет объект из поискового индекса, запоминая его текст для отката
+func (s *InMemoryStorage) unindex(id string) {
+	s.rememberIndex(id)
+	s.index.remove(id)
+}
+
+func (s *InMemoryStorage) rememberIndex(id string) {
+	old, existed := s.index.docs[id]
+	s.journal.onRollback(func() {
+		s.index.remove(id)
+		if existed {
+			s.index.add(id, old.kind, old.text)
+		}
+	})
+}
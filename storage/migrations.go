@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration - одна версия схемы базы данных. Примененные версии записываются
+// в таблицу schema_migrations, поэтому каждая миграция выполняется ровно один раз
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Ключ advisory lock, под которым миграции применяются, чтобы несколько
+// экземпляров приложения не мигрировали базу одновременно
+const migrationsLockKey = 7_300_210_942
+
+// Список миграций в порядке применения. Уже выпущенные миграции не редактируются -
+// изменения схемы добавляются новой версией в конец списка
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create users, post and comment tables",
+		sql: `
+		CREATE TABLE IF NOT EXISTS users (
+			id UUID PRIMARY KEY,
+			username VARCHAR(20) NOT NULL UNIQUE,
+			password CHAR(60) NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS post (
+			id UUID PRIMARY KEY,
+			text TEXT NOT NULL,
+			author_id UUID NOT NULL,
+			commentable BOOLEAN NOT NULL,
+			FOREIGN KEY (author_id) REFERENCES users(id)
+		);
+		CREATE TABLE IF NOT EXISTS comment (
+			id UUID PRIMARY KEY,
+			comment VARCHAR(2000),
+			author_id UUID NOT NULL,
+			post_id UUID NOT NULL,
+			parent_comment_id UUID,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (author_id) REFERENCES users(id),
+			FOREIGN KEY (post_id) REFERENCES post(id),
+			FOREIGN KEY (parent_comment_id) REFERENCES comment(id)
+		);`,
+	},
+	{
+		// Пользователи пишут и по-русски, и по-английски, поэтому текст индексируется
+		// обеими конфигурациями сразу. Колонки генерируемые - их поддерживает сама база
+		version: 2,
+		name:    "full-text search over posts and comments",
+		sql: `
+		ALTER TABLE post ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				to_tsvector('english', coalesce(text, '')) || to_tsvector('russian', coalesce(text, ''))
+			) STORED;
+		CREATE INDEX IF NOT EXISTS post_search_vector_idx ON post USING GIN (search_vector);
+		ALTER TABLE comment ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				to_tsvector('english', coalesce(comment, '')) || to_tsvector('russian', coalesce(comment, ''))
+			) STORED;
+		CREATE INDEX IF NOT EXISTS comment_search_vector_idx ON comment USING GIN (search_vector);`,
+	},
+	{
+		// Фильтры списков постов и комментариев: дата создания и индексы под условия фильтров
+		version: 3,
+		name:    "post creation time and filter indexes",
+		sql: `
+		ALTER TABLE post ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+		UPDATE comment SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+		ALTER TABLE comment ALTER COLUMN created_at SET NOT NULL;
+		CREATE INDEX IF NOT EXISTS post_created_at_idx ON post (created_at, id);
+		CREATE INDEX IF NOT EXISTS post_author_id_idx ON post (author_id);
+		CREATE INDEX IF NOT EXISTS comment_created_at_idx ON comment (created_at, id);
+		CREATE INDEX IF NOT EXISTS comment_author_id_idx ON comment (author_id);
+		CREATE INDEX IF NOT EXISTS comment_post_id_idx ON comment (post_id);
+		CREATE INDEX IF NOT EXISTS comment_parent_comment_id_idx ON comment (parent_comment_id);`,
+	},
+	{
+		// Реакции хранятся построчно, а их количество - в отдельной таблице счетчиков,
+		// которая обновляется тем же запросом, что и реакции, поэтому чтение счетчиков дешевое
+		version: 4,
+		name:    "reactions on posts and comments",
+		sql: `
+		CREATE TABLE IF NOT EXISTS reaction (
+			item_id UUID NOT NULL,
+			item_type VARCHAR(16) NOT NULL CHECK (item_type IN ('POST', 'COMMENT')),
+			user_id UUID NOT NULL REFERENCES users(id),
+			kind VARCHAR(16) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (item_id, user_id, kind)
+		);
+		CREATE TABLE IF NOT EXISTS reaction_count (
+			item_id UUID NOT NULL,
+			kind VARCHAR(16) NOT NULL,
+			count INTEGER NOT NULL CHECK (count >= 0),
+			PRIMARY KEY (item_id, kind)
+		);`,
+	},
+	{
+		// Индексы подписок покрывают выборки подписчиков и подписок от новых к старым,
+		// а индекс постов по автору и времени - ленту, которая собирается одним запросом
+		version: 5,
+		name:    "follows and home feed",
+		sql: `
+		CREATE TABLE IF NOT EXISTS follow (
+			follower_id UUID NOT NULL REFERENCES users(id),
+			followee_id UUID NOT NULL REFERENCES users(id),
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (follower_id, followee_id),
+			CHECK (follower_id <> followee_id)
+		);
+		CREATE INDEX IF NOT EXISTS follow_follower_created_at_idx ON follow (follower_id, created_at DESC, followee_id DESC);
+		CREATE INDEX IF NOT EXISTS follow_followee_created_at_idx ON follow (followee_id, created_at DESC, follower_id DESC);
+		CREATE INDEX IF NOT EXISTS post_author_created_at_idx ON post (author_id, created_at DESC, id DESC);`,
+	},
+	{
+		// Уведомления выбираются страницами по получателю от новых к старым, а счетчик
+		// непрочитанных считается по частичному индексу
+		version: 6,
+		name:    "notifications",
+		sql: `
+		CREATE TABLE IF NOT EXISTS notification (
+			id UUID PRIMARY KEY,
+			recipient_id UUID NOT NULL REFERENCES users(id),
+			actor_id UUID NOT NULL REFERENCES users(id),
+			kind VARCHAR(16) NOT NULL CHECK (kind IN ('COMMENT', 'REPLY', 'MENTION')),
+			post_id UUID NOT NULL REFERENCES post(id),
+			comment_id UUID REFERENCES comment(id),
+			read BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS notification_recipient_idx ON notification (recipient_id, created_at DESC, id DESC);
+		CREATE INDEX IF NOT EXISTS notification_unread_idx ON notification (recipient_id) WHERE NOT read;`,
+	},
+	{
+		// Упоминание ссылается на пользователя по ID, а смещения относятся к тексту объекта,
+		// поэтому переименование пользователя их не сдвигает. При удалении пользователя
+		// упоминание остается в тексте без ссылки
+		version: 7,
+		name:    "mentions in posts and comments",
+		sql: `
+		CREATE TABLE IF NOT EXISTS mention (
+			item_id UUID NOT NULL,
+			item_type VARCHAR(16) NOT NULL CHECK (item_type IN ('POST', 'COMMENT')),
+			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			start_offset INTEGER NOT NULL CHECK (start_offset >= 0),
+			length INTEGER NOT NULL CHECK (length > 0),
+			PRIMARY KEY (item_id, start_offset)
+		);
+		CREATE INDEX IF NOT EXISTS mention_user_id_idx ON mention (user_id, item_id);`,
+	},
+	{
+		// Время создания поста продублировано в post_tag, чтобы страница postsByTag читалась
+		// по одному индексу. tagged_at - время, когда тег появился у поста, по нему считаются популярные теги
+		version: 8,
+		name:    "post tags",
+		sql: `
+		CREATE TABLE IF NOT EXISTS post_tag (
+			post_id UUID NOT NULL REFERENCES post(id),
+			tag VARCHAR(64) NOT NULL,
+			post_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			tagged_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (post_id, tag)
+		);
+		CREATE INDEX IF NOT EXISTS post_tag_tag_idx ON post_tag (tag, post_created_at DESC, post_id DESC);
+		CREATE INDEX IF NOT EXISTS post_tag_tagged_at_idx ON post_tag (tagged_at);`,
+	},
+	{
+		// Хранится исходный текст, HTML отрисовывается при чтении. Существующие записи - обычный текст
+		version: 9,
+		name:    "text format of posts and comments",
+		sql: `
+		ALTER TABLE post ADD COLUMN IF NOT EXISTS format VARCHAR(16) NOT NULL DEFAULT 'PLAIN'
+			CHECK (format IN ('PLAIN', 'MARKDOWN'));
+		ALTER TABLE comment ADD COLUMN IF NOT EXISTS format VARCHAR(16) NOT NULL DEFAULT 'PLAIN'
+			CHECK (format IN ('PLAIN', 'MARKDOWN'));`,
+	},
+	{
+		// Имена, отличающиеся только регистром, считаются одним именем. Если такие пользователи
+		// уже есть, миграция завершится ошибкой и их нужно переименовать вручную
+		version: 10,
+		name:    "case-insensitive unique usernames",
+		sql: `
+		CREATE UNIQUE INDEX IF NOT EXISTS users_username_lower_idx ON users (lower(username));`,
+	},
+	{
+		// Роль и блокировка меняются администратором из CLI. Заблокированный пользователь
+		// не может войти, а его выданные токены отклоняются
+		version: 11,
+		name:    "user roles and disabling",
+		sql: `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'USER'
+			CHECK (role IN ('USER', 'ADMIN'));
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;`,
+	},
+	{
+		// Явно переданные теги отмечаются, чтобы при смене текста пересчитывались только теги
+		// из него. У существующих постов явными считаются теги, которых нет в тексте как #тег
+		version: 12,
+		name:    "explicit post tags",
+		sql: `
+		ALTER TABLE post_tag ADD COLUMN IF NOT EXISTS explicit BOOLEAN NOT NULL DEFAULT FALSE;
+		UPDATE post_tag pt SET explicit = TRUE FROM post p
+			WHERE p.id = pt.post_id AND position('#' || pt.tag IN lower(p.text)) = 0;`,
+	},
+}
+
+// Migrate применяет к базе данных все еще не примененные миграции
+func Migrate(ctx context.Context, db *sql.DB) error {
+	const op = "storage.Migrate"
+
+	// Advisory lock держится на соединении, поэтому вся работа идет через одно соединение
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+
+	_, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("%s: migration %d (%s): %w", op, m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration выполняет миграцию и записывает ее версию в одной транзакции
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion возвращает номер последней примененной миграции
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
@@ -5,17 +5,73 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"html"
 	"log"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/VadimRight/GraphQLOzon/internal/backoff"
 	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
+	"github.com/VadimRight/GraphQLOzon/internal/hashtag"
 	"github.com/VadimRight/GraphQLOzon/model"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// Код ошибки Postgres при нарушении уникального ограничения
+const uniqueViolation = "23505"
+
 // PostgresStorage представляет собой структуру для работы с базой данных PostgreSQL
 type PostgresStorage struct {
 	DB *sql.DB
+	// tx задан у хранилища, переданного в WithTx: все запросы выполняются в этой транзакции
+	tx *sql.Tx
+	// replicas - реплики для чтения, nil - все запросы идут в DB
+	replicas *replicaSet
+}
+
+// querier - общие методы *sql.DB и *sql.Tx для выполнения запросов
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn возвращает транзакцию, если хранилище работает внутри нее, иначе пул соединений основной базы
+func (s *PostgresStorage) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
+}
+
+// WithTx выполняет fn в транзакции: если fn возвращает ошибку или паникует, транзакция откатывается.
+// Вложенный вызов выполняется в той же транзакции
+func (s *PostgresStorage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	return s.inTx(ctx, func(tx *PostgresStorage) error { return fn(tx) })
+}
+
+// inTx выполняет fn в транзакции или, если хранилище уже внутри транзакции, в ней же.
+// Так методы, которым нужно несколько запросов, атомарны и при вызове из WithTx
+func (s *PostgresStorage) inTx(ctx context.Context, fn func(tx *PostgresStorage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&PostgresStorage{DB: s.DB, tx: tx, replicas: s.replicas}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.markWrite(ctx)
+	return nil
 }
 
 // NewPostgresStorage возвращает объект PostgresStorage
@@ -27,103 +83,123 @@ func NewPostgresStorage(db *sql.DB) *PostgresStorage {
 func InitPostgresDatabase(cfg *config.Config) *PostgresStorage {
 	const op = "postgres.InitPostgresDatabase"
 
-	dbHost := cfg.Postgres.PostgresHost
-	dbPort := cfg.Postgres.PostgresPort
-	dbUser := cfg.Postgres.PostgresUser
-	dbPasswd := cfg.Postgres.PostgresPassword
-	dbName := cfg.Postgres.DatabaseName
-
-	postgresUrl := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPasswd, dbName)
-	db, err := sql.Open("postgres", postgresUrl)
+	db, err := openPostgres(postgresDSN(cfg.Postgres), cfg.Postgres)
 	if err != nil {
 		log.Fatalf("%s: %v", op, err)
 	}
 
-	// Создание таблицы пользователей
-	createUserTable, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS users (
-		id UUID PRIMARY KEY,
-		username VARCHAR(20) NOT NULL UNIQUE,
-		password CHAR(60) NOT NULL UNIQUE
-	);`)
-	if err != nil {
-		log.Fatalf("%s: %v", op, err)
-	}
-	_, err = createUserTable.Exec()
-	if err != nil {
+	// Таблицы и индексы создаются миграциями. База может подняться позже приложения,
+	// поэтому подключение и миграции повторяются, пока не истечет ConnectTimeout
+	if err := connectPostgres(context.Background(), db, cfg.Postgres); err != nil {
 		log.Fatalf("%s: %v", op, err)
 	}
 
-	// Создание таблицы постов
-	createPostTable, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS post (
-		id UUID PRIMARY KEY,
-		text TEXT NOT NULL,
-		author_id UUID NOT NULL,
-		commentable BOOLEAN NOT NULL,
-		FOREIGN KEY (author_id) REFERENCES users(id));
-	`)
-	if err != nil {
-		log.Fatalf("%s: %v", op, err)
-	}
-	_, err = createPostTable.Exec()
-	if err != nil {
-		log.Fatalf("%s: %v", op, err)
+	storage := &PostgresStorage{DB: db}
+	if len(cfg.Postgres.ReplicaDSNs) > 0 {
+		replicas := make([]*sql.DB, 0, len(cfg.Postgres.ReplicaDSNs))
+		for _, raw := range cfg.Postgres.ReplicaDSNs {
+			dsn, err := replicaDSN(raw, cfg.Postgres)
+			if err != nil {
+				log.Fatalf("%s: replica: %v", op, err)
+			}
+			replica, err := openPostgres(dsn, cfg.Postgres)
+			if err != nil {
+				log.Fatalf("%s: replica: %v", op, err)
+			}
+			replicas = append(replicas, replica)
+		}
+		storage.replicas = newReplicaSet(replicas, cfg.Postgres.ReadYourWritesWindow)
+		if err := pingReplicas(context.Background(), storage.replicas, cfg.Postgres.ConnectTimeout); err != nil {
+			log.Fatalf("%s: replica: %v", op, err)
+		}
 	}
+	return storage
+}
 
-	// Создание таблицы комментариев
-	createCommentTable, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS comment (
-		id UUID PRIMARY KEY,
-		comment VARCHAR(2000),
-		author_id UUID NOT NULL,
-		post_id UUID NOT NULL,
-		parent_comment_id UUID,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (author_id) REFERENCES users(id),
-		FOREIGN KEY (post_id) REFERENCES post(id),
-		FOREIGN KEY (parent_comment_id) REFERENCES comment(id)
-	);`)
-	if err != nil {
-		log.Fatalf("%s: %v", op, err)
-	}
-	_, err = createCommentTable.Exec()
-	if err != nil {
-		log.Fatalf("%s: %v", op, err)
+// connectPostgres дожидается доступности базы и применяет миграции. Ошибки соединения
+// повторяются с экспоненциальной задержкой, остальные ошибки, например неверный пароль
+// или ошибка в миграции, возвращаются сразу
+func connectPostgres(ctx context.Context, db *sql.DB, cfg *config.PostgresConfig) error {
+	policy := backoff.Policy{
+		Initial:    cfg.ConnectBackoffInitial,
+		Max:        cfg.ConnectBackoffMax,
+		MaxElapsed: cfg.ConnectTimeout,
 	}
+	return backoff.Retry(ctx, policy, func(ctx context.Context) error {
+		err := db.PingContext(ctx)
+		if err == nil {
+			err = Migrate(ctx, db)
+		}
+		if err != nil && !IsUnavailable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, func(attempt int, delay time.Duration, err error) {
+		slog.Warn("postgres is unavailable, retrying", "attempt", attempt, "delay", delay, "error", err)
+	})
+}
 
-	return &PostgresStorage{DB: db}
+// pingReplicas проверяет реплики при запуске. Ошибка настройки, например неверный пароль,
+// возвращается сразу. Недоступная реплика не мешает запуску: она исключается из чтений
+// и проверяется снова при следующем запросе после replicaRetryAfter
+func pingReplicas(ctx context.Context, replicas *replicaSet, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for i, replica := range replicas.replicas {
+		err := replica.db.PingContext(ctx)
+		if IsUnavailable(err) {
+			slog.Warn("postgres replica is unavailable", "replica", i, "error", err)
+			replica.markDown()
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i, err)
+		}
+	}
+	return nil
 }
 
-// ClosePostgres закрывает соединение с базой данных
+// ClosePostgres закрывает соединения с основной базой и репликами
 func (s *PostgresStorage) ClosePostgres() error {
-	return s.DB.Close()
+	err := s.DB.Close()
+	if s.replicas != nil {
+		for _, replica := range s.replicas.replicas {
+			err = errors.Join(err, replica.db.Close())
+		}
+	}
+	return err
 }
 
-// GetUserByUsername возвращает пользователя по его имени
+// GetUserByUsername возвращает пользователя по его имени. Запрос всегда идет в основную базу:
+// по нему выполняется вход, в том числе сразу после регистрации, когда пользователь еще анонимен
 func (s *PostgresStorage) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
 	var user model.User
-	err := s.DB.QueryRowContext(ctx, "SELECT id, username, password FROM users WHERE username=$1", username).Scan(&user.ID, &user.Username, &user.Password)
+	err := s.conn().QueryRowContext(ctx, "SELECT id, username, password, role, disabled FROM users WHERE lower(username) = lower($1)", username).Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Disabled)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// UserCreate создает нового пользователя
+// UserCreate создает нового пользователя. Уникальность имени без учета регистра обеспечивает
+// индекс по lower(username), поэтому из одновременных регистраций одного имени проходит одна
 func (s *PostgresStorage) UserCreate(ctx context.Context, username string, password string) (*model.User, error) {
 	id := uuid.New().String()
-	_, err := s.DB.ExecContext(ctx, "INSERT INTO users (id, username, password) VALUES ($1, $2, $3)", id, username, password)
+	_, err := s.writer(ctx).ExecContext(ctx, "INSERT INTO users (id, username, password) VALUES ($1, $2, $3)", id, username, password)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return nil, ErrUsernameTaken
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &model.User{ID: id, Username: username, Password: password}, nil
+	return &model.User{ID: id, Username: username, Password: password, Role: model.UserRoleUser}, nil
 }
 
 // GetUserByID возвращает пользователя по его ID
 func (s *PostgresStorage) GetUserByID(ctx context.Context, userID string) (*model.User, error) {
 	var user model.User
-	err := s.DB.QueryRowContext(ctx, "SELECT id, username FROM users WHERE id=$1", userID).Scan(&user.ID, &user.Username)
+	err := s.reader(ctx).QueryRowContext(ctx, "SELECT id, username, role, disabled FROM users WHERE id=$1", userID).Scan(&user.ID, &user.Username, &user.Role, &user.Disabled)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +208,7 @@ func (s *PostgresStorage) GetUserByID(ctx context.Context, userID string) (*mode
 
 // GetAllUsers возвращает всех пользователей
 func (s *PostgresStorage) GetAllUsers(ctx context.Context) ([]*model.User, error) {
-	rows, err := s.DB.QueryContext(ctx, "SELECT id, username FROM users")
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT id, username, role, disabled FROM users")
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +217,7 @@ func (s *PostgresStorage) GetAllUsers(ctx context.Context) ([]*model.User, error
 	var users []*model.User
 	for rows.Next() {
 		var user model.User
-		if err := rows.Scan(&user.ID, &user.Username); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.Disabled); err != nil {
 			return nil, err
 		}
 		users = append(users, &user)
@@ -149,19 +225,45 @@ func (s *PostgresStorage) GetAllUsers(ctx context.Context) ([]*model.User, error
 	return users, nil
 }
 
-// GetAllPosts возвращает все посты с поддержкой пагинации
-func (s *PostgresStorage) GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error) {
-	query := "SELECT id, text, author_id, commentable FROM post"
-	var rows *sql.Rows
-	var err error
+// SetUserRole меняет роль пользователя
+func (s *PostgresStorage) SetUserRole(ctx context.Context, userID string, role model.UserRole) (*model.User, error) {
+	return s.updateUser(ctx, "UPDATE users SET role=$2 WHERE id=$1 RETURNING id, username, role, disabled", userID, role)
+}
 
-	if limit != nil && offset != nil {
-		query += " LIMIT $1 OFFSET $2"
-		rows, err = s.DB.QueryContext(ctx, query, *limit, *offset)
-	} else {
-		rows, err = s.DB.QueryContext(ctx, query)
+// SetUserDisabled блокирует или разблокирует пользователя
+func (s *PostgresStorage) SetUserDisabled(ctx context.Context, userID string, disabled bool) (*model.User, error) {
+	return s.updateUser(ctx, "UPDATE users SET disabled=$2 WHERE id=$1 RETURNING id, username, role, disabled", userID, disabled)
+}
+
+// SetUserPassword заменяет хэш пароля пользователя
+func (s *PostgresStorage) SetUserPassword(ctx context.Context, userID string, password string) (*model.User, error) {
+	return s.updateUser(ctx, "UPDATE users SET password=$2 WHERE id=$1 RETURNING id, username, role, disabled", userID, password)
+}
+
+// IsUserDisabled сообщает, заблокирован ли пользователь. Проверка выполняется на каждый запрос
+// с токеном, поэтому читается основная база: реплика может еще не знать о блокировке
+func (s *PostgresStorage) IsUserDisabled(ctx context.Context, userID string) (bool, error) {
+	var disabled bool
+	err := s.conn().QueryRowContext(ctx, "SELECT disabled FROM users WHERE id=$1", userID).Scan(&disabled)
+	return disabled, err
+}
+
+func (s *PostgresStorage) updateUser(ctx context.Context, query string, args ...any) (*model.User, error) {
+	var user model.User
+	err := s.writer(ctx).QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.Username, &user.Role, &user.Disabled)
+	if err != nil {
+		return nil, err
 	}
+	return &user, nil
+}
+
+// GetAllPosts возвращает посты, подходящие под фильтр, с поддержкой пагинации
+func (s *PostgresStorage) GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error) {
+	b := &sqlBuilder{}
+	applyPostFilter(b, filter)
+	query := b.build("SELECT id, text, author_id, commentable, format, created_at FROM post", "created_at, id", limit, offset)
 
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +272,7 @@ func (s *PostgresStorage) GetAllPosts(ctx context.Context, limit, offset *int) (
 	var posts []*model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable); err != nil {
+		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt); err != nil {
 			return nil, err
 		}
 		posts = append(posts, &post)
@@ -180,15 +282,15 @@ func (s *PostgresStorage) GetAllPosts(ctx context.Context, limit, offset *int) (
 
 // GetPostsByUserID возвращает посты пользователя с поддержкой пагинации
 func (s *PostgresStorage) GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error) {
-	query := "SELECT id, text, author_id, commentable FROM post WHERE author_id = $1"
+	query := "SELECT id, text, author_id, commentable, format, created_at FROM post WHERE author_id = $1"
 	var rows *sql.Rows
 	var err error
 
 	if limit != nil && offset != nil {
 		query += " LIMIT $2 OFFSET $3"
-		rows, err = s.DB.QueryContext(ctx, query, userID, *limit, *offset)
+		rows, err = s.reader(ctx).QueryContext(ctx, query, userID, *limit, *offset)
 	} else {
-		rows, err = s.DB.QueryContext(ctx, query, userID)
+		rows, err = s.reader(ctx).QueryContext(ctx, query, userID)
 	}
 
 	if err != nil {
@@ -199,7 +301,7 @@ func (s *PostgresStorage) GetPostsByUserID(ctx context.Context, userID string, l
 	var posts []*model.Post
 	for rows.Next() {
 		var post model.Post
-		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable); err != nil {
+		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt); err != nil {
 			return nil, err
 		}
 		posts = append(posts, &post)
@@ -210,7 +312,7 @@ func (s *PostgresStorage) GetPostsByUserID(ctx context.Context, userID string, l
 // GetPostByID возвращает пост по его ID
 func (s *PostgresStorage) GetPostByID(ctx context.Context, postID string) (*model.Post, error) {
 	var post model.Post
-	err := s.DB.QueryRowContext(ctx, "SELECT id, text, author_id, commentable FROM post WHERE id=$1", postID).Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable)
+	err := s.reader(ctx).QueryRowContext(ctx, "SELECT id, text, author_id, commentable, format, created_at FROM post WHERE id=$1", postID).Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -218,95 +320,93 @@ func (s *PostgresStorage) GetPostByID(ctx context.Context, postID string) (*mode
 }
 
 // CreatePost создает новый пост
-func (s *PostgresStorage) CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error) {
-	_, err := s.DB.ExecContext(ctx, "INSERT INTO post (id, text, author_id, commentable) VALUES ($1, $2, $3, $4)", id, text, authorID, commentable)
+func (s *PostgresStorage) CreatePost(ctx context.Context, id, text, authorID string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	post := &model.Post{ID: id, Text: text, AuthorID: authorID, Commentable: commentable, Format: format}
+	err := s.writer(ctx).QueryRowContext(ctx, "INSERT INTO post (id, text, author_id, commentable, format) VALUES ($1, $2, $3, $4, $5) RETURNING created_at", id, text, authorID, commentable, format).Scan(&post.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
-	return &model.Post{ID: id, Text: text, AuthorID: authorID, Commentable: commentable}, nil
+	return post, nil
 }
 
-// GetAllComments возвращает все комментарии с поддержкой пагинации
-func (s *PostgresStorage) GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error) {
-	query := "SELECT id, comment, author_id, post_id, parent_comment_id FROM comment"
-	params := []interface{}{}
-
-	if limit != nil && offset != nil {
-		query += " LIMIT $1 OFFSET $2"
-		params = append(params, *limit, *offset)
-	} else if limit != nil {
-		query += " LIMIT $1"
-		params = append(params, *limit)
-	} else if offset != nil {
-		query += " OFFSET $1"
-		params = append(params, *offset)
+// UpdatePost меняет текст, его формат и возможность комментирования поста
+func (s *PostgresStorage) UpdatePost(ctx context.Context, id, text string, commentable bool, format model.TextFormat) (*model.Post, error) {
+	post := &model.Post{ID: id, Text: text, Commentable: commentable, Format: format}
+	err := s.writer(ctx).QueryRowContext(ctx, "UPDATE post SET text = $2, commentable = $3, format = $4 WHERE id = $1 RETURNING author_id, created_at", id, text, commentable, format).Scan(&post.AuthorID, &post.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("post not found")
 	}
-
-	rows, err := s.DB.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return post, nil
+}
 
-	var comments []*model.CommentResponse
-	for rows.Next() {
-		var comment model.CommentResponse
-		if err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID); err != nil {
-			return nil, err
+// DeletePost удаляет пост вместе с его комментариями, их реакциями, упоминаниями и тегами поста,
+// а также уведомлениями о посте и его комментариях. Строка поста блокируется FOR UPDATE, поэтому
+// новые комментарии, которые блокируют пост FOR SHARE, ждут удаления и не остаются без поста
+func (s *PostgresStorage) DeletePost(ctx context.Context, id string) error {
+	return s.inTx(ctx, func(tx *PostgresStorage) error {
+		var postID string
+		err := tx.conn().QueryRowContext(ctx, "SELECT id FROM post WHERE id = $1 FOR UPDATE", id).Scan(&postID)
+		if err == sql.ErrNoRows {
+			return errors.New("post not found")
 		}
-		comments = append(comments, &comment)
-	}
-	return comments, nil
+		if err != nil {
+			return err
+		}
+		// Посты и комментарии, на которые ссылаются реакции и упоминания
+		const items = "(SELECT $1::uuid UNION ALL SELECT id FROM comment WHERE post_id = $1)"
+		queries := []string{
+			"DELETE FROM notification WHERE post_id = $1",
+			"DELETE FROM mention WHERE item_id IN " + items,
+			"DELETE FROM reaction WHERE item_id IN " + items,
+			"DELETE FROM reaction_count WHERE item_id IN " + items,
+			"DELETE FROM post_tag WHERE post_id = $1",
+			"DELETE FROM comment WHERE post_id = $1",
+			"DELETE FROM post WHERE id = $1",
+		}
+		for _, query := range queries {
+			if _, err := tx.conn().ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// GetCommentsByPostID возвращает комментарии к посту с поддержкой пагинации
-func (s *PostgresStorage) GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	query := "SELECT id, comment, author_id, post_id, parent_comment_id FROM comment WHERE post_id=$1"
-	args := []interface{}{postID}
-
-	if limit != nil {
-		query += " LIMIT $2"
-		args = append(args, *limit)
-	}
-	if offset != nil {
-		query += " OFFSET $3"
-		args = append(args, *offset)
-	}
+// GetAllComments возвращает комментарии, подходящие под фильтр, с поддержкой пагинации
+func (s *PostgresStorage) GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error) {
+	b := &sqlBuilder{}
+	applyCommentFilter(b, filter)
+	query := b.build("SELECT id, comment, author_id, post_id, parent_comment_id, format, created_at FROM comment", "created_at, id", limit, offset)
 
-	rows, err := s.DB.QueryContext(ctx, query, args...)
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var comments []*model.CommentResponse
-	for rows.Next() {
-		var comment model.CommentResponse
-		err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
-	}
-	return comments, nil
+	return scanComments(rows)
 }
 
-// GetCommentsByParentID возвращает комментарии по ID родительского комментария с поддержкой пагинации
-func (s *PostgresStorage) GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error) {
-	query := "SELECT id, comment, author_id, post_id, parent_comment_id FROM comment WHERE parent_comment_id=$1"
+// GetCommentsByPostID возвращает комментарии к посту в заданном порядке с поддержкой пагинации
+func (s *PostgresStorage) GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	b := &sqlBuilder{}
+	b.where("comment.post_id = " + b.arg(postID))
+	return s.queryComments(ctx, b, sort, limit, offset)
+}
 
-	// Добавляем лимит и смещение, если они заданы
-	if limit != nil && offset != nil {
-		query += " LIMIT $2 OFFSET $3"
-		rows, err := s.DB.QueryContext(ctx, query, parentID, *limit, *offset)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		return scanComments(rows)
-	}
+// GetCommentsByParentID возвращает ответы на комментарий в заданном порядке с поддержкой пагинации
+func (s *PostgresStorage) GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	b := &sqlBuilder{}
+	b.where("comment.parent_comment_id = " + b.arg(parentID))
+	return s.queryComments(ctx, b, sort, limit, offset)
+}
 
-	rows, err := s.DB.QueryContext(ctx, query, parentID)
+// queryComments выбирает комментарии по условиям b, сортируя их с учетом рейтинга
+func (s *PostgresStorage) queryComments(ctx context.Context, b *sqlBuilder, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error) {
+	query := b.build(commentsWithVotesQuery, commentOrder(sort), limit, offset)
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -319,7 +419,7 @@ func scanComments(rows *sql.Rows) ([]*model.CommentResponse, error) {
 	var comments []*model.CommentResponse
 	for rows.Next() {
 		var comment model.CommentResponse
-		err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID)
+		err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID, &comment.Format, &comment.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -334,7 +434,7 @@ func scanComments(rows *sql.Rows) ([]*model.CommentResponse, error) {
 // GetCommentByID возвращает комментарий по его ID
 func (s *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*model.CommentResponse, error) {
 	var comment model.CommentResponse
-	err := s.DB.QueryRowContext(ctx, "SELECT id, comment, author_id, post_id, parent_comment_id FROM comment WHERE id=$1", id).Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID)
+	err := s.reader(ctx).QueryRowContext(ctx, "SELECT id, comment, author_id, post_id, parent_comment_id, format, created_at FROM comment WHERE id=$1", id).Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID, &comment.Format, &comment.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -343,7 +443,7 @@ func (s *PostgresStorage) GetCommentByID(ctx context.Context, id string) (*model
 
 // GetCommentsByUserID возвращает комментарии пользователя
 func (s *PostgresStorage) GetCommentsByUserID(ctx context.Context, userID string) ([]*model.CommentResponse, error) {
-	rows, err := s.DB.QueryContext(ctx, "SELECT id, comment, author_id, post_id, parent_comment_id FROM comment WHERE author_id=$1", userID)
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT id, comment, author_id, post_id, parent_comment_id, format, created_at FROM comment WHERE author_id=$1", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -352,7 +452,7 @@ func (s *PostgresStorage) GetCommentsByUserID(ctx context.Context, userID string
 	var comments []*model.CommentResponse
 	for rows.Next() {
 		var comment model.CommentResponse
-		if err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID, &comment.Format, &comment.CreatedAt); err != nil {
 			return nil, err
 		}
 		comments = append(comments, &comment)
@@ -360,18 +460,33 @@ func (s *PostgresStorage) GetCommentsByUserID(ctx context.Context, userID string
 	return comments, nil
 }
 
-// CreateComment создает новый комментарий
-func (s *PostgresStorage) CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error) {
+// CreateComment создает новый комментарий. Проверка поста и вставка выполняются в одной транзакции,
+// а строка поста блокируется FOR SHARE: пока комментарий не сохранен, автор не может выключить
+// комментарии или удалить пост
+func (s *PostgresStorage) CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error) {
+	var comment *model.CommentResponse
+	err := s.inTx(ctx, func(tx *PostgresStorage) error {
+		var err error
+		comment, err = tx.createComment(ctx, commentText, itemId, userID, format)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *PostgresStorage) createComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error) {
 	var isReply bool
 	var parentCommentID *string
 	var postID string
 	var commentAble bool
 
 	// Сначала проверяем, является ли itemId постом и включены ли комментарии
-	err := s.DB.QueryRowContext(ctx, "SELECT commentable FROM post WHERE id=$1", itemId).Scan(&commentAble)
+	err := s.conn().QueryRowContext(ctx, "SELECT commentable FROM post WHERE id=$1 FOR SHARE", itemId).Scan(&commentAble)
 	if err == sql.ErrNoRows {
-		// Если itemId не является постом, это может быть комментарием
-		err = s.DB.QueryRowContext(ctx, "SELECT post_id FROM comment WHERE id=$1", itemId).Scan(&postID)
+		// Если itemId не является постом, это может быть комментарием. Блокируются и комментарий, и его пост
+		err = s.conn().QueryRowContext(ctx, "SELECT c.post_id FROM comment c JOIN post p ON p.id = c.post_id WHERE c.id=$1 FOR SHARE", itemId).Scan(&postID)
 		if err == sql.ErrNoRows {
 			return nil, errors.New("item not found")
 		} else if err != nil {
@@ -394,19 +509,595 @@ func (s *PostgresStorage) CreateComment(ctx context.Context, commentText, itemId
 	var query string
 	if isReply {
 		// Если это ответ на комментарий
-		query = "INSERT INTO comment (id, comment, author_id, post_id, parent_comment_id) VALUES ($1, $2, $3, $4, $5)"
-		_, err := s.DB.ExecContext(ctx, query, id, commentText, userID, postID, itemId)
+		query = "INSERT INTO comment (id, comment, author_id, post_id, parent_comment_id, format) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at"
+		comment := &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, ParentCommentID: parentCommentID, Format: format}
+		err := s.conn().QueryRowContext(ctx, query, id, commentText, userID, postID, itemId, format).Scan(&comment.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
-		return &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, ParentCommentID: parentCommentID}, nil
+		return comment, nil
 	} else {
 		// Если это комментарий к посту
-		query = "INSERT INTO comment (id, comment, author_id, post_id, parent_comment_id) VALUES ($1, $2, $3, $4, NULL)"
-		_, err := s.DB.ExecContext(ctx, query, id, commentText, userID, postID)
+		query = "INSERT INTO comment (id, comment, author_id, post_id, parent_comment_id, format) VALUES ($1, $2, $3, $4, NULL, $5) RETURNING created_at"
+		comment := &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID, Format: format}
+		err := s.conn().QueryRowContext(ctx, query, id, commentText, userID, postID, format).Scan(&comment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		return comment, nil
+	}
+}
+
+// Маркеры, которыми ts_headline выделяет найденные слова. Символы из области частного
+// использования Unicode заменяются на <b></b> после экранирования фрагмента
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+// Запрос полнотекстового поиска. Запрос пользователя разбирается и английской, и русской
+// конфигурацией, документ находится, если подходит хотя бы один из вариантов. Фрагмент
+// текста строится той конфигурацией, на которую документ откликнулся. ts_headline не
+// экранирует текст, поэтому совпадения выделяются маркерами, а HTML собирается в escapeHeadline
+const searchQuery = `
+WITH q AS (
+	SELECT websearch_to_tsquery('english', $1) AS en, websearch_to_tsquery('russian', $1) AS ru
+)
+SELECT kind, id, body, author_id, post_id, parent_comment_id, commentable, format, created_at, score,
+	CASE WHEN to_tsvector('russian', body) @@ ru
+		THEN ts_headline('russian', body, ru, 'StartSel=` + headlineStart + `, StopSel=` + headlineStop + `')
+		ELSE ts_headline('english', body, en, 'StartSel=` + headlineStart + `, StopSel=` + headlineStop + `')
+	END AS snippet
+FROM (
+	SELECT 'POST' AS kind, p.id, p.text AS body, p.author_id, p.id AS post_id, NULL::uuid AS parent_comment_id,
+		p.commentable, p.format, p.created_at, ts_rank(p.search_vector, q.en || q.ru) AS score, q.en, q.ru
+	FROM post p, q
+	WHERE 'POST' = ANY($2) AND p.search_vector @@ (q.en || q.ru)
+	UNION ALL
+	SELECT 'COMMENT', c.id, c.comment, c.author_id, c.post_id, c.parent_comment_id,
+		false, c.format, c.created_at, ts_rank(c.search_vector, q.en || q.ru), q.en, q.ru
+	FROM comment c, q
+	WHERE 'COMMENT' = ANY($2) AND c.search_vector @@ (q.en || q.ru)
+) found
+ORDER BY score DESC, id
+LIMIT $3 OFFSET $4`
+
+// Search ищет посты и комментарии по tsvector колонкам и возвращает страницу результатов по убыванию релевантности
+func (s *PostgresStorage) Search(ctx context.Context, query string, types []model.SearchType, limit, offset int) ([]*model.SearchHit, error) {
+	if offset < 0 {
+		return []*model.SearchHit{}, nil
+	}
+	if len(types) == 0 {
+		types = model.AllSearchType
+	}
+	kinds := make([]string, 0, len(types))
+	for _, t := range types {
+		kinds = append(kinds, string(t))
+	}
+
+	rows, err := s.reader(ctx).QueryContext(ctx, searchQuery, query, pq.Array(kinds), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []*model.SearchHit{}
+	for rows.Next() {
+		var (
+			kind, id, body, authorID, postID string
+			parentCommentID                  *string
+			commentable                      bool
+			format                           model.TextFormat
+			createdAt                        time.Time
+			hit                              model.SearchHit
+		)
+		if err := rows.Scan(&kind, &id, &body, &authorID, &postID, &parentCommentID, &commentable, &format, &createdAt, &hit.Score, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		hit.Snippet = escapeHeadline(hit.Snippet)
+		if model.SearchType(kind) == model.SearchTypePost {
+			hit.Node = &model.Post{ID: id, Text: body, AuthorID: authorID, Commentable: commentable, Format: format, CreatedAt: createdAt}
+		} else {
+			hit.Node = &model.CommentResponse{ID: id, Comment: body, AuthorID: authorID, PostID: postID, ParentCommentID: parentCommentID, Format: format, CreatedAt: createdAt}
+		}
+		hits = append(hits, &hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// escapeHeadline экранирует фрагмент ts_headline и заменяет маркеры совпадений на <b></b>.
+// Маркеры, встреченные в самом тексте, тоже становятся тегами, но кроме <b> в фрагмент
+// ничего не попадает
+func escapeHeadline(headline string) string {
+	return strings.NewReplacer(headlineStart, "<b>", headlineStop, "</b>").Replace(html.EscapeString(headline))
+}
+
+// itemType определяет, является ли itemID постом или комментарием. Вызывается перед записью,
+// поэтому читает из основной базы
+func (s *PostgresStorage) itemType(ctx context.Context, itemID string) (string, error) {
+	var itemType string
+	err := s.conn().QueryRowContext(ctx, `
+	SELECT 'POST' FROM post WHERE id = $1
+	UNION ALL
+	SELECT 'COMMENT' FROM comment WHERE id = $1
+	LIMIT 1`, itemID).Scan(&itemType)
+	if err == sql.ErrNoRows {
+		return "", errors.New("item not found")
+	}
+	return itemType, err
+}
+
+// React ставит реакцию на пост или комментарий. Повторная реакция того же вида ничего не меняет,
+// а счетчик увеличивается тем же запросом только если реакция действительно добавилась
+func (s *PostgresStorage) React(ctx context.Context, itemID, userID string, kind model.ReactionKind) error {
+	itemType, err := s.itemType(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	_, err = s.writer(ctx).ExecContext(ctx, `
+	WITH inserted AS (
+		INSERT INTO reaction (item_id, item_type, user_id, kind) VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+		RETURNING item_id, kind
+	)
+	INSERT INTO reaction_count (item_id, kind, count)
+	SELECT item_id, kind, 1 FROM inserted
+	ON CONFLICT (item_id, kind) DO UPDATE SET count = reaction_count.count + 1`, itemID, itemType, userID, string(kind))
+	return err
+}
+
+// Unreact снимает реакцию и уменьшает счетчик, если реакция была
+func (s *PostgresStorage) Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) error {
+	if _, err := s.itemType(ctx, itemID); err != nil {
+		return err
+	}
+	_, err := s.writer(ctx).ExecContext(ctx, `
+	WITH deleted AS (
+		DELETE FROM reaction WHERE item_id = $1 AND user_id = $2 AND kind = $3
+		RETURNING item_id, kind
+	)
+	UPDATE reaction_count SET count = reaction_count.count - 1
+	FROM deleted
+	WHERE reaction_count.item_id = deleted.item_id AND reaction_count.kind = deleted.kind`, itemID, userID, string(kind))
+	return err
+}
+
+// GetReactionCounts возвращает ненулевые счетчики реакций объекта в порядке model.AllReactionKind
+func (s *PostgresStorage) GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT kind, count FROM reaction_count WHERE item_id = $1 AND count > 0", itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[model.ReactionKind]int)
+	for rows.Next() {
+		var kind string
+		var count int
+		if err := rows.Scan(&kind, &count); err != nil {
+			return nil, err
+		}
+		counts[model.ReactionKind(kind)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orderedReactionCounts(counts), nil
+}
+
+// GetUserReactions возвращает реакции пользователя на объект в порядке model.AllReactionKind
+func (s *PostgresStorage) GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT kind FROM reaction WHERE item_id = $1 AND user_id = $2", itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reacted := make(map[model.ReactionKind]bool)
+	for rows.Next() {
+		var kind string
+		if err := rows.Scan(&kind); err != nil {
+			return nil, err
+		}
+		reacted[model.ReactionKind(kind)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orderedReactionKinds(reacted), nil
+}
+
+// Follow подписывает followerID на followeeID. Повторная подписка ничего не меняет
+func (s *PostgresStorage) Follow(ctx context.Context, followerID, followeeID string) error {
+	_, err := s.writer(ctx).ExecContext(ctx, "INSERT INTO follow (follower_id, followee_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", followerID, followeeID)
+	return err
+}
+
+// Unfollow отменяет подписку followerID на followeeID
+func (s *PostgresStorage) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	_, err := s.writer(ctx).ExecContext(ctx, "DELETE FROM follow WHERE follower_id = $1 AND followee_id = $2", followerID, followeeID)
+	return err
+}
+
+// GetFollowers возвращает подписчиков пользователя от новых подписок к старым
+func (s *PostgresStorage) GetFollowers(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error) {
+	return s.getFollows(ctx, "followee_id", "follower_id", userID, after, limit)
+}
+
+// GetFollowing возвращает пользователей, на которых подписан пользователь, от новых подписок к старым
+func (s *PostgresStorage) GetFollowing(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error) {
+	return s.getFollows(ctx, "follower_id", "followee_id", userID, after, limit)
+}
+
+// getFollows выбирает пользователей на другой стороне подписок userID. Имена колонок - константы
+// из GetFollowers и GetFollowing, значения передаются только параметрами
+func (s *PostgresStorage) getFollows(ctx context.Context, userColumn, otherColumn, userID string, after *cursor.Key, limit int) ([]*model.Follow, error) {
+	b := &sqlBuilder{}
+	b.where("f." + userColumn + " = " + b.arg(userID))
+	if after != nil {
+		b.where("(f.created_at, f." + otherColumn + ") < (" + b.arg(after.CreatedAt) + ", " + b.arg(after.ID) + "::uuid)")
+	}
+	query := b.build("SELECT u.id, u.username, f.created_at FROM follow f JOIN users u ON u.id = f."+otherColumn,
+		"f.created_at DESC, f."+otherColumn+" DESC", &limit, nil)
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []*model.Follow{}
+	for rows.Next() {
+		follow := &model.Follow{User: &model.User{}}
+		if err := rows.Scan(&follow.User.ID, &follow.User.Username, &follow.FollowedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, follow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return follows, nil
+}
+
+// GetFeed возвращает посты пользователей, на которых подписан userID, от новых к старым.
+// Лента собирается одним запросом по индексу post (author_id, created_at, id)
+func (s *PostgresStorage) GetFeed(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Post, error) {
+	b := &sqlBuilder{}
+	b.where("f.follower_id = " + b.arg(userID))
+	if after != nil {
+		b.where("(p.created_at, p.id) < (" + b.arg(after.CreatedAt) + ", " + b.arg(after.ID) + "::uuid)")
+	}
+	query := b.build("SELECT p.id, p.text, p.author_id, p.commentable, p.format, p.created_at FROM post p JOIN follow f ON f.followee_id = p.author_id",
+		"p.created_at DESC, p.id DESC", &limit, nil)
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		var post model.Post
+		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, &post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// CreateNotifications сохраняет уведомления одним запросом, заполняя их ID и время создания
+func (s *PostgresStorage) CreateNotifications(ctx context.Context, notifications []*model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	b := &sqlBuilder{}
+	byID := make(map[string]*model.Notification, len(notifications))
+	values := make([]string, 0, len(notifications))
+	for _, notification := range notifications {
+		notification.ID = uuid.New().String()
+		byID[notification.ID] = notification
+		values = append(values, "("+b.arg(notification.ID)+", "+b.arg(notification.RecipientID)+", "+b.arg(notification.ActorID)+", "+
+			b.arg(string(notification.Kind))+", "+b.arg(notification.PostID)+", "+b.arg(notification.CommentID)+"::uuid)")
+	}
+	rows, err := s.writer(ctx).QueryContext(ctx, "INSERT INTO notification (id, recipient_id, actor_id, kind, post_id, comment_id) VALUES "+
+		strings.Join(values, ", ")+" RETURNING id, created_at", b.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			return err
+		}
+		if notification, ok := byID[id]; ok {
+			notification.CreatedAt = createdAt
+		}
+	}
+	return rows.Err()
+}
+
+// GetNotifications возвращает уведомления пользователя от новых к старым
+func (s *PostgresStorage) GetNotifications(ctx context.Context, userID string, unreadOnly bool, after *cursor.Key, limit int) ([]*model.Notification, error) {
+	b := &sqlBuilder{}
+	b.where("recipient_id = " + b.arg(userID))
+	if unreadOnly {
+		b.where("NOT read")
+	}
+	if after != nil {
+		b.where("(created_at, id) < (" + b.arg(after.CreatedAt) + ", " + b.arg(after.ID) + "::uuid)")
+	}
+	query := b.build("SELECT id, kind, recipient_id, actor_id, post_id, comment_id, read, created_at FROM notification",
+		"created_at DESC, id DESC", &limit, nil)
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.RecipientID, &n.ActorID, &n.PostID, &n.CommentID, &n.Read, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// CountUnreadNotifications возвращает число непрочитанных уведомлений пользователя
+func (s *PostgresStorage) CountUnreadNotifications(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.reader(ctx).QueryRowContext(ctx, "SELECT count(*) FROM notification WHERE recipient_id = $1 AND NOT read", userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationsRead отмечает прочитанными уведомления пользователя с указанными ID,
+// а если ids равен nil - все его уведомления. Возвращает число отмеченных уведомлений
+func (s *PostgresStorage) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	b := &sqlBuilder{}
+	b.where("recipient_id = " + b.arg(userID))
+	b.where("NOT read")
+	if ids != nil {
+		// Как и в памяти, неизвестные ID пропускаются, а ID не в формате UUID неизвестны заранее
+		b.where("id = ANY(" + b.arg(uuidArray(ids)) + "::uuid[])")
+	}
+	result, err := s.writer(ctx).ExecContext(ctx, b.build("UPDATE notification SET read = TRUE", "", nil, nil), b.args...)
+	if err != nil {
+		return 0, err
+	}
+	marked, err := result.RowsAffected()
+	return int(marked), err
+}
+
+// SaveMentions заменяет упоминания в посте или комментарии. Старые упоминания удаляются
+// в той же транзакции, поэтому при редактировании текста не остается устаревших смещений
+func (s *PostgresStorage) SaveMentions(ctx context.Context, itemID string, mentions []*model.Mention) error {
+	itemType, err := s.itemType(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(tx *PostgresStorage) error {
+		if _, err := tx.conn().ExecContext(ctx, "DELETE FROM mention WHERE item_id = $1", itemID); err != nil {
+			return err
+		}
+		if len(mentions) == 0 {
+			return nil
+		}
+		b := &sqlBuilder{}
+		values := make([]string, 0, len(mentions))
+		for _, m := range mentions {
+			values = append(values, "("+b.arg(itemID)+", "+b.arg(itemType)+", "+b.arg(m.UserID)+"::uuid, "+b.arg(m.Offset)+", "+b.arg(m.Length)+")")
+		}
+		_, err := tx.conn().ExecContext(ctx, "INSERT INTO mention (item_id, item_type, user_id, start_offset, length) VALUES "+strings.Join(values, ", "), b.args...)
+		return err
+	})
+}
+
+// GetMentions возвращает упоминания в посте или комментарии в порядке следования в тексте
+func (s *PostgresStorage) GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, "SELECT user_id, start_offset, length FROM mention WHERE item_id = $1 ORDER BY start_offset", itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []*model.Mention{}
+	for rows.Next() {
+		var m model.Mention
+		if err := rows.Scan(&m.UserID, &m.Offset, &m.Length); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// Запрос постов и комментариев с упоминанием пользователя. Объекты отбираются по индексу
+// упоминаний, а порядок и курсор определяются временем создания самого объекта
+const mentionedInQuery = `
+WITH mentioned AS (
+	SELECT DISTINCT item_id, item_type FROM mention WHERE user_id = $1
+)
+SELECT kind, id, body, author_id, post_id, parent_comment_id, commentable, format, created_at
+FROM (
+	SELECT 'POST' AS kind, p.id, p.text AS body, p.author_id, p.id AS post_id, NULL::uuid AS parent_comment_id,
+		p.commentable, p.format, p.created_at
+	FROM mentioned m JOIN post p ON m.item_type = 'POST' AND p.id = m.item_id
+	UNION ALL
+	SELECT 'COMMENT', c.id, c.comment, c.author_id, c.post_id, c.parent_comment_id, false, c.format, c.created_at
+	FROM mentioned m JOIN comment c ON m.item_type = 'COMMENT' AND c.id = m.item_id
+) items`
+
+// GetMentionedIn возвращает посты и комментарии с упоминанием пользователя от новых к старым
+func (s *PostgresStorage) GetMentionedIn(ctx context.Context, userID string, after *cursor.Key, limit int) ([]model.MentionSource, error) {
+	b := &sqlBuilder{}
+	b.arg(userID)
+	if after != nil {
+		b.where("(created_at, id) < (" + b.arg(after.CreatedAt) + ", " + b.arg(after.ID) + "::uuid)")
+	}
+	query := b.build(mentionedInQuery, "created_at DESC, id DESC", &limit, nil)
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := []model.MentionSource{}
+	for rows.Next() {
+		var (
+			kind, id, body, authorID, postID string
+			parentCommentID                  *string
+			commentable                      bool
+			format                           model.TextFormat
+			createdAt                        time.Time
+		)
+		if err := rows.Scan(&kind, &id, &body, &authorID, &postID, &parentCommentID, &commentable, &format, &createdAt); err != nil {
+			return nil, err
+		}
+		if kind == "POST" {
+			nodes = append(nodes, &model.Post{ID: id, Text: body, AuthorID: authorID, Commentable: commentable, Format: format, CreatedAt: createdAt})
+		} else {
+			nodes = append(nodes, &model.CommentResponse{ID: id, Comment: body, AuthorID: authorID, PostID: postID, ParentCommentID: parentCommentID, Format: format, CreatedAt: createdAt})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// SetPostTags заменяет теги поста: теги из текста и явно переданные, которые отмечаются
+// в колонке explicit. Теги, которые уже были у поста, не пересоздаются и сохраняют время
+// добавления, поэтому редактирование не поднимает их в популярных
+func (s *PostgresStorage) SetPostTags(ctx context.Context, postID string, fromText, explicit []string) error {
+	tags := hashtag.Merge(fromText, explicit)
+	return s.inTx(ctx, func(tx *PostgresStorage) error {
+		// Блокировка строки поста сериализует одновременное изменение тегов одного поста
+		var createdAt time.Time
+		err := tx.conn().QueryRowContext(ctx, "SELECT created_at FROM post WHERE id = $1 FOR UPDATE", postID).Scan(&createdAt)
+		if err == sql.ErrNoRows {
+			return errors.New("post not found")
+		}
 		if err != nil {
+			return err
+		}
+		if _, err := tx.conn().ExecContext(ctx, "DELETE FROM post_tag WHERE post_id = $1 AND NOT tag = ANY($2::text[])", postID, pq.Array(tags)); err != nil {
+			return err
+		}
+		_, err = tx.conn().ExecContext(ctx, `
+		INSERT INTO post_tag (post_id, tag, post_created_at, explicit)
+		SELECT $1, tag, $3, tag = ANY($4::text[]) FROM unnest($2::text[]) AS tag
+		ON CONFLICT (post_id, tag) DO UPDATE SET explicit = EXCLUDED.explicit`, postID, pq.Array(tags), createdAt, pq.Array(explicit))
+		return err
+	})
+}
+
+// GetPostTags возвращает теги поста по алфавиту
+func (s *PostgresStorage) GetPostTags(ctx context.Context, postID string) ([]string, error) {
+	return s.postTags(ctx, "SELECT tag FROM post_tag WHERE post_id = $1 ORDER BY tag", postID)
+}
+
+// GetPostExplicitTags возвращает явно переданные теги поста по алфавиту
+func (s *PostgresStorage) GetPostExplicitTags(ctx context.Context, postID string) ([]string, error) {
+	return s.postTags(ctx, "SELECT tag FROM post_tag WHERE post_id = $1 AND explicit ORDER BY tag", postID)
+}
+
+func (s *PostgresStorage) postTags(ctx context.Context, query, postID string) ([]string, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
 			return nil, err
 		}
-		return &model.CommentResponse{ID: id, Comment: commentText, AuthorID: userID, PostID: postID}, nil
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetPostsByTag возвращает посты с тегом от новых к старым. Страница выбирается по индексу
+// post_tag (tag, post_created_at, post_id)
+func (s *PostgresStorage) GetPostsByTag(ctx context.Context, tag string, after *cursor.Key, limit int) ([]*model.Post, error) {
+	b := &sqlBuilder{}
+	b.where("t.tag = " + b.arg(tag))
+	if after != nil {
+		b.where("(t.post_created_at, t.post_id) < (" + b.arg(after.CreatedAt) + ", " + b.arg(after.ID) + "::uuid)")
+	}
+	query := b.build("SELECT p.id, p.text, p.author_id, p.commentable, p.format, p.created_at FROM post_tag t JOIN post p ON p.id = t.post_id",
+		"t.post_created_at DESC, t.post_id DESC", &limit, nil)
+
+	rows, err := s.reader(ctx).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*model.Post{}
+	for rows.Next() {
+		var post model.Post
+		if err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, &post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// GetTrendingTags возвращает теги, чаще всего добавлявшиеся к постам после since.
+// При равном числе использований теги идут по алфавиту
+func (s *PostgresStorage) GetTrendingTags(ctx context.Context, since time.Time, limit int) ([]*model.TrendingTag, error) {
+	rows, err := s.reader(ctx).QueryContext(ctx, `
+	SELECT tag, count(*) FROM post_tag
+	WHERE tagged_at > $1
+	GROUP BY tag
+	ORDER BY count(*) DESC, tag
+	LIMIT $2`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trending := []*model.TrendingTag{}
+	for rows.Next() {
+		var t model.TrendingTag
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		trending = append(trending, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+	return trending, nil
 }
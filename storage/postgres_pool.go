@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/logger"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// postgresDSN собирает строку подключения к основной базе. Значения экранируются, поэтому
+// пароль может содержать пробелы и кавычки. Параметры, которые lib/pq не знает, например
+// statement_timeout, передаются серверу как параметры сессии
+func postgresDSN(cfg *config.PostgresConfig) string {
+	params := []string{
+		"host=" + dsnValue(cfg.PostgresHost),
+		"port=" + dsnValue(cfg.PostgresPort),
+		"user=" + dsnValue(cfg.PostgresUser),
+		"password=" + dsnValue(cfg.PostgresPassword),
+		"dbname=" + dsnValue(cfg.DatabaseName),
+	}
+	params = append(params, sslParams(cfg)...)
+	return withStatementTimeout(strings.Join(params, " "), cfg.StatementTimeout)
+}
+
+// sslParams возвращает параметры TLS подключения из конфигурации
+func sslParams(cfg *config.PostgresConfig) []string {
+	params := []string{"sslmode=" + dsnValue(cfg.SSLMode)}
+	if cfg.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(cfg.SSLRootCert))
+	}
+	if cfg.SSLCert != "" {
+		params = append(params, "sslcert="+dsnValue(cfg.SSLCert))
+	}
+	if cfg.SSLKey != "" {
+		params = append(params, "sslkey="+dsnValue(cfg.SSLKey))
+	}
+	return params
+}
+
+// replicaDSN приводит строку подключения к реплике к виду key=value (URL postgres:// тоже
+// допускается). Настройки TLS и ограничение времени запроса берутся из конфигурации основной
+// базы и ставятся перед параметрами реплики: lib/pq берет последнее значение ключа, поэтому
+// заданное в самой строке подключения не переопределяется
+func replicaDSN(dsn string, cfg *config.PostgresConfig) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		parsed, err := pq.ParseURL(dsn)
+		if err != nil {
+			return "", err
+		}
+		dsn = parsed
+	}
+	defaults := withStatementTimeout(strings.Join(sslParams(cfg), " "), cfg.StatementTimeout)
+	return defaults + " " + dsn, nil
+}
+
+// withStatementTimeout добавляет к строке подключения statement_timeout. Значение передается
+// и при 0: так ограничение выключается, даже если оно задано в настройках сервера
+func withStatementTimeout(dsn string, timeout time.Duration) string {
+	return fmt.Sprintf("%s statement_timeout=%d", dsn, timeout.Milliseconds())
+}
+
+// dsnValue экранирует значение для строки подключения вида key=value
+func dsnValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+// openPostgres открывает пул соединений с настройками из конфигурации.
+// Каждый SQL запрос оборачивается в спан OpenTelemetry
+func openPostgres(dsn string, cfg *config.PostgresConfig) (*sql.DB, error) {
+	db, err := otelsql.Open("postgres", dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+type userIDKey struct{}
+
+// WithUserID сохраняет в контексте ID пользователя, от имени которого выполняется запрос.
+// По нему хранилище направляет чтения недавно писавшего пользователя в основную базу
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+func userIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+// Сколько реплика пропускается после ошибки соединения
+const replicaRetryAfter = 5 * time.Second
+
+// replica - пул соединений с репликой. После ошибки соединения реплика считается
+// недоступной до downUntil, и ее чтения идут в основную базу
+type replica struct {
+	db        *sql.DB
+	downUntil atomic.Int64
+}
+
+// markDown исключает реплику из чтений на replicaRetryAfter
+func (r *replica) markDown() {
+	r.downUntil.Store(time.Now().Add(replicaRetryAfter).UnixNano())
+}
+
+func (r *replica) available(now time.Time) bool {
+	return now.UnixNano() >= r.downUntil.Load()
+}
+
+// replicaSet распределяет чтения по репликам по кругу и помнит, когда пользователи
+// в последний раз писали в основную базу. Пока не прошло окно window, чтения такого
+// пользователя идут в основную базу, чтобы он видел свои изменения несмотря на отставание реплик.
+// Время записи хранится в памяти процесса, поэтому при нескольких экземплярах приложения
+// гарантия действует для запросов, попавших в тот же экземпляр
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	window   time.Duration
+
+	mu        sync.Mutex
+	writes    map[string]time.Time
+	lastSweep time.Time
+}
+
+func newReplicaSet(dbs []*sql.DB, window time.Duration) *replicaSet {
+	replicas := make([]*replica, 0, len(dbs))
+	for _, db := range dbs {
+		replicas = append(replicas, &replica{db: db})
+	}
+	return &replicaSet{replicas: replicas, window: window, writes: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// pick возвращает следующую доступную реплику или nil, если доступных нет
+func (r *replicaSet) pick() *replica {
+	now := time.Now()
+	for range r.replicas {
+		next := r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+		if next.available(now) {
+			return next
+		}
+	}
+	return nil
+}
+
+// wrote запоминает время записи пользователя и заодно удаляет устаревшие записи
+func (r *replicaSet) wrote(userID string) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes[userID] = now
+	if now.Sub(r.lastSweep) > r.window {
+		for id, at := range r.writes {
+			if now.Sub(at) > r.window {
+				delete(r.writes, id)
+			}
+		}
+		r.lastSweep = now
+	}
+}
+
+// recentlyWrote сообщает, что пользователь писал в основную базу в пределах окна
+func (r *replicaSet) recentlyWrote(userID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.writes[userID]
+	return ok && time.Since(at) <= r.window
+}
+
+// reader возвращает соединение для чтения: транзакцию внутри нее, реплику, если они настроены,
+// или основную базу, если реплик нет, все они недоступны или текущий пользователь недавно писал
+func (s *PostgresStorage) reader(ctx context.Context) querier {
+	if s.tx != nil || s.replicas == nil {
+		return s.conn()
+	}
+	if userID := userIDFromContext(ctx); userID != "" && s.replicas.recentlyWrote(userID) {
+		return s.DB
+	}
+	replica := s.replicas.pick()
+	if replica == nil {
+		return s.DB
+	}
+	return &replicaReader{replica: replica, primary: s.DB}
+}
+
+// writer возвращает соединение для записи и отмечает, что текущий пользователь писал
+func (s *PostgresStorage) writer(ctx context.Context) querier {
+	s.markWrite(ctx)
+	return s.conn()
+}
+
+func (s *PostgresStorage) markWrite(ctx context.Context) {
+	if s.replicas == nil || s.replicas.window <= 0 {
+		return
+	}
+	if userID := userIDFromContext(ctx); userID != "" {
+		s.replicas.wrote(userID)
+	}
+}
+
+// replicaReader выполняет запрос на реплике, а при ошибке соединения повторяет его
+// в основной базе и исключает реплику из чтений на replicaRetryAfter
+type replicaReader struct {
+	replica *replica
+	primary *sql.DB
+}
+
+func (r *replicaReader) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	result, err := r.replica.db.ExecContext(ctx, query, args...)
+	if r.failed(ctx, err) {
+		return r.primary.ExecContext(ctx, query, args...)
+	}
+	return result, err
+}
+
+func (r *replicaReader) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := r.replica.db.QueryContext(ctx, query, args...)
+	if r.failed(ctx, err) {
+		return r.primary.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+func (r *replicaReader) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	row := r.replica.db.QueryRowContext(ctx, query, args...)
+	if r.failed(ctx, row.Err()) {
+		return r.primary.QueryRowContext(ctx, query, args...)
+	}
+	return row
+}
+
+// failed сообщает, что реплика недоступна и запрос нужно повторить в основной базе
+func (r *replicaReader) failed(ctx context.Context, err error) bool {
+	if !IsUnavailable(err) {
+		return false
+	}
+	r.replica.markDown()
+	logger.FromContext(ctx).Warn("replica is unavailable, reading from primary", "error", err)
+	return true
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Код ошибки Postgres при нарушении внешнего ключа
+const foreignKeyViolation = "23503"
+
+// Число строк, читаемых одним запросом при выгрузке. Выгрузка идет страницами по ключу,
+// чтобы отдельный запрос не упирался в statement_timeout и не держал соединение
+const scanPageSize = 1000
+
+// RestoreUser сохраняет пользователя из выгрузки с его ID, ролью и хэшем пароля. Пользователь
+// с тем же ID и именем пропускается, с тем же ID и другим именем - ошибка ErrRestoreConflict
+func (s *PostgresStorage) RestoreUser(ctx context.Context, user *model.User) (bool, error) {
+	result, err := s.writer(ctx).ExecContext(ctx, "INSERT INTO users (id, username, password, role, disabled) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO NOTHING",
+		user.ID, user.Username, user.Password, user.Role, user.Disabled)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation &&
+		(pqErr.Constraint == "users_username_key" || pqErr.Constraint == "users_username_lower_idx") {
+		return false, ErrUsernameTaken
+	}
+	created, err := inserted(result, err)
+	if err != nil || created {
+		return created, err
+	}
+	var existing string
+	if err := s.conn().QueryRowContext(ctx, "SELECT username FROM users WHERE id = $1", user.ID).Scan(&existing); err != nil {
+		return false, err
+	}
+	if existing != user.Username {
+		return false, fmt.Errorf("%w: user %s is %s", ErrRestoreConflict, user.ID, existing)
+	}
+	return false, nil
+}
+
+// RestorePost сохраняет пост из выгрузки с его ID и временем создания
+func (s *PostgresStorage) RestorePost(ctx context.Context, post *model.Post) (bool, error) {
+	result, err := s.writer(ctx).ExecContext(ctx, "INSERT INTO post (id, text, author_id, commentable, format, created_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (id) DO NOTHING",
+		post.ID, post.Text, post.AuthorID, post.Commentable, post.Format, post.CreatedAt)
+	return inserted(result, err)
+}
+
+// RestoreComment сохраняет комментарий из выгрузки с его ID, родителем и временем создания.
+// Запрет комментирования поста не проверяется: комментарий мог быть оставлен до запрета
+func (s *PostgresStorage) RestoreComment(ctx context.Context, comment *model.CommentResponse) (bool, error) {
+	result, err := s.writer(ctx).ExecContext(ctx, "INSERT INTO comment (id, comment, author_id, post_id, parent_comment_id, format, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING",
+		comment.ID, comment.Comment, comment.AuthorID, comment.PostID, comment.ParentCommentID, comment.Format, comment.CreatedAt)
+	return inserted(result, err)
+}
+
+// inserted сообщает, добавил ли INSERT ... ON CONFLICT DO NOTHING строку. Нарушение внешнего
+// ключа возвращается как ErrMissingReference с именем ограничения
+func inserted(result sql.Result, err error) (bool, error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return false, fmt.Errorf("%w: %s", ErrMissingReference, pqErr.Constraint)
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ScanUsers передает fn пользователей по возрастанию ID
+func (s *PostgresStorage) ScanUsers(ctx context.Context, fn func(*model.User) error) error {
+	after := uuid.Nil.String()
+	for {
+		page, err := scanPage(ctx, s.reader(ctx), "SELECT id, username, password, role, disabled FROM users WHERE id > $1 ORDER BY id LIMIT $2",
+			[]any{after, scanPageSize}, func(rows *sql.Rows) (*model.User, error) {
+				var user model.User
+				err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Disabled)
+				return &user, err
+			})
+		if err != nil {
+			return err
+		}
+		for _, user := range page {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// ScanPosts передает fn посты по возрастанию ID
+func (s *PostgresStorage) ScanPosts(ctx context.Context, fn func(*model.Post) error) error {
+	after := uuid.Nil.String()
+	for {
+		page, err := scanPage(ctx, s.reader(ctx), "SELECT id, text, author_id, commentable, format, created_at FROM post WHERE id > $1 ORDER BY id LIMIT $2",
+			[]any{after, scanPageSize}, func(rows *sql.Rows) (*model.Post, error) {
+				var post model.Post
+				err := rows.Scan(&post.ID, &post.Text, &post.AuthorID, &post.Commentable, &post.Format, &post.CreatedAt)
+				return &post, err
+			})
+		if err != nil {
+			return err
+		}
+		for _, post := range page {
+			if err := fn(post); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// ScanComments передает fn комментарии по времени создания, затем по ID
+func (s *PostgresStorage) ScanComments(ctx context.Context, fn func(*model.CommentResponse) error) error {
+	afterCreatedAt, afterID := time.Time{}, uuid.Nil.String()
+	for {
+		page, err := scanPage(ctx, s.reader(ctx), "SELECT id, comment, author_id, post_id, parent_comment_id, format, created_at FROM comment WHERE (created_at, id) > ($1, $2) ORDER BY created_at, id LIMIT $3",
+			[]any{afterCreatedAt, afterID, scanPageSize}, func(rows *sql.Rows) (*model.CommentResponse, error) {
+				var comment model.CommentResponse
+				err := rows.Scan(&comment.ID, &comment.Comment, &comment.AuthorID, &comment.PostID, &comment.ParentCommentID, &comment.Format, &comment.CreatedAt)
+				return &comment, err
+			})
+		if err != nil {
+			return err
+		}
+		for _, comment := range page {
+			if err := fn(comment); err != nil {
+				return err
+			}
+		}
+		if len(page) < scanPageSize {
+			return nil
+		}
+		last := page[len(page)-1]
+		afterCreatedAt, afterID = last.CreatedAt, last.ID
+	}
+}
+
+// scanPage читает одну страницу выгрузки. Строки читаются до вызова fn, поэтому соединение
+// не занято, пока вызывающий пишет объекты
+func scanPage[T any](ctx context.Context, db querier, query string, args []any, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	page := make([]T, 0, scanPageSize)
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, item)
+	}
+	return page, rows.Err()
+}
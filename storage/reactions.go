@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"math"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// orderedReactionCounts превращает счетчики в список в порядке model.AllReactionKind, пропуская нулевые
+func orderedReactionCounts(counts map[model.ReactionKind]int) []*model.ReactionCount {
+	result := []*model.ReactionCount{}
+	for _, kind := range model.AllReactionKind {
+		if count := counts[kind]; count > 0 {
+			result = append(result, &model.ReactionCount{Kind: kind, Count: count})
+		}
+	}
+	return result
+}
+
+// orderedReactionKinds превращает множество реакций в список в порядке model.AllReactionKind
+func orderedReactionKinds(kinds map[model.ReactionKind]bool) []model.ReactionKind {
+	result := []model.ReactionKind{}
+	for _, kind := range model.AllReactionKind {
+		if kinds[kind] {
+			result = append(result, kind)
+		}
+	}
+	return result
+}
+
+// Реакции, из которых складывается рейтинг комментария: голоса "за" и "против"
+const (
+	upvoteReaction   = model.ReactionKindLike
+	downvoteReaction = model.ReactionKindDislike
+)
+
+// commentScore - рейтинг комментария для сортировки TOP
+func commentScore(up, down int) float64 {
+	return float64(up - down)
+}
+
+// commentControversy - спорность комментария для сортировки CONTROVERSIAL: тем больше,
+// чем больше голосов и чем ближе друг к другу количества голосов "за" и "против"
+func commentControversy(up, down int) float64 {
+	if up <= 0 || down <= 0 {
+		return 0
+	}
+	balance := float64(min(up, down)) / float64(max(up, down))
+	return math.Pow(float64(up+down), balance)
+}
+
+// Выборка комментариев вместе со счетчиками голосов, по которым считается рейтинг
+const commentsWithVotesQuery = `SELECT comment.id, comment.comment, comment.author_id, comment.post_id, comment.parent_comment_id, comment.format, comment.created_at
+FROM comment
+LEFT JOIN reaction_count up ON up.item_id = comment.id AND up.kind = '` + string(upvoteReaction) + `'
+LEFT JOIN reaction_count down ON down.item_id = comment.id AND down.kind = '` + string(downvoteReaction) + `'`
+
+// Сортировки комментариев в SQL. Выражения повторяют commentScore и commentControversy,
+// а последним ключом всегда идет ID, чтобы порядок был полностью определен
+var commentOrderSQL = map[model.CommentSort]string{
+	model.CommentSortNew: "comment.created_at DESC, comment.id",
+	model.CommentSortOld: "comment.created_at, comment.id",
+	model.CommentSortTop: "COALESCE(up.count, 0) - COALESCE(down.count, 0) DESC, comment.created_at DESC, comment.id",
+	model.CommentSortControversial: `CASE WHEN COALESCE(up.count, 0) > 0 AND COALESCE(down.count, 0) > 0
+		THEN power(up.count + down.count, LEAST(up.count, down.count)::float8 / GREATEST(up.count, down.count))
+		ELSE 0 END DESC, comment.created_at DESC, comment.id`,
+}
+
+// commentOrder возвращает ORDER BY для сортировки. Без сортировки комментарии идут от старых к новым
+func commentOrder(sort model.CommentSort) string {
+	if order, ok := commentOrderSQL[sort]; ok {
+		return order
+	}
+	return commentOrderSQL[model.CommentSortOld]
+}
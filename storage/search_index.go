@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+)
+
+// Максимальное количество слов во фрагменте текста, как у ts_headline по умолчанию
+const snippetMaxWords = 35
+
+// Стоп-слова не индексируются - так же поступают конфигурации english и russian в PostgreSQL
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {}, "for": {}, "in": {},
+	"is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "the": {}, "to": {}, "with": {},
+	"и": {}, "в": {}, "во": {}, "на": {}, "не": {}, "что": {}, "с": {}, "со": {}, "по": {}, "а": {},
+	"но": {}, "к": {}, "у": {}, "из": {}, "за": {}, "о": {}, "об": {}, "как": {}, "это": {},
+}
+
+// Окончания, которые отрезает упрощенный стеммер. Список упорядочен по убыванию длины,
+// поэтому отрезается самое длинное подходящее окончание
+var stemSuffixes = []string{
+	"иями",
+	"ing", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ией",
+	"ed", "es", "ов", "ев", "ей", "ой", "ый", "ий", "ая", "яя", "ое", "ее", "ые", "ие", "ом", "ем", "ам", "ям", "ах", "ях", "ию", "ия",
+	"s", "а", "я", "о", "е", "ы", "и", "у", "ю", "ь",
+}
+
+// searchIndex - простой инвертированный индекс для InMemoryStorage. Он повторяет поведение
+// поиска в PostgreSQL настолько, чтобы тесты давали сравнимые результаты: слова приводятся
+// к нижнему регистру, стоп-слова выбрасываются, окончания отрезаются, документ находится,
+// только если в нем есть все слова запроса. Индекс не потокобезопасен - его защищает мьютекс хранилища
+type searchIndex struct {
+	postings map[string]map[string]int // терм -> id документа -> количество вхождений
+	docs     map[string]searchDoc
+}
+
+type searchDoc struct {
+	kind  model.SearchType
+	text  string
+	terms int
+}
+
+// searchResult - найденный индексом документ
+type searchResult struct {
+	id    string
+	kind  model.SearchType
+	score float64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string]searchDoc),
+	}
+}
+
+// add индексирует текст поста или комментария
+func (idx *searchIndex) add(id string, kind model.SearchType, text string) {
+	terms := tokenize(text)
+	idx.docs[id] = searchDoc{kind: kind, text: text, terms: len(terms)}
+	for _, term := range terms {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			idx.postings[term] = docs
+		}
+		docs[id]++
+	}
+}
+
+// remove удаляет документ из индекса, например перед повторной индексацией измененного текста
+func (idx *searchIndex) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range tokenize(doc.text) {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// search возвращает документы нужных типов, содержащие все термы запроса, по убыванию релевантности
+func (idx *searchIndex) search(query string, types []model.SearchType) []searchResult {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	candidates := idx.postings[terms[0]]
+	var results []searchResult
+	for id := range candidates {
+		doc := idx.docs[id]
+		if !containsType(types, doc.kind) {
+			continue
+		}
+		score, matched := 0.0, true
+		for _, term := range terms {
+			tf := idx.postings[term][id]
+			if tf == 0 {
+				matched = false
+				break
+			}
+			// Частые в документе слова весят больше, частые во всем индексе - меньше
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(idx.postings[term])))
+			score += idf * float64(tf) / float64(tf+doc.terms)
+		}
+		if matched {
+			results = append(results, searchResult{id: id, kind: doc.kind, score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].id < results[j].id
+	})
+	return results
+}
+
+// snippet возвращает фрагмент текста документа с выделенными словами запроса
+func (idx *searchIndex) snippet(id, query string) string {
+	return highlight(idx.docs[id].text, uniqueTerms(tokenize(query)))
+}
+
+// textSegment - слово или разделитель между словами
+type textSegment struct {
+	text string
+	word bool
+}
+
+// segments разбивает текст на слова и разделители, сохраняя исходный текст целиком
+func segments(text string) []textSegment {
+	var result []textSegment
+	start, inWord := 0, false
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if i > 0 && isWordRune != inWord {
+			result = append(result, textSegment{text: text[start:i], word: inWord})
+			start = i
+		}
+		inWord = isWordRune
+	}
+	if start < len(text) {
+		result = append(result, textSegment{text: text[start:], word: inWord})
+	}
+	return result
+}
+
+// tokenize превращает текст в список термов
+func tokenize(text string) []string {
+	var terms []string
+	for _, segment := range segments(text) {
+		if term, ok := normalizeWord(segment); ok {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// normalizeWord приводит слово к терму. Разделители и стоп-слова термами не являются
+func normalizeWord(segment textSegment) (string, bool) {
+	if !segment.word {
+		return "", false
+	}
+	word := strings.ToLower(segment.text)
+	if _, stop := stopWords[word]; stop {
+		return "", false
+	}
+	return stem(word), true
+}
+
+// stem отрезает окончание, оставляя основу не короче трех букв
+func stem(word string) string {
+	length := utf8.RuneCountInString(word)
+	for _, suffix := range stemSuffixes {
+		if strings.HasSuffix(word, suffix) && length-utf8.RuneCountInString(suffix) >= 3 {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// highlight оборачивает слова запроса в <b></b> и обрезает текст до окна вокруг первого совпадения.
+// Текст экранируется, чтобы фрагмент можно было вставить как HTML
+func highlight(text string, terms []string) string {
+	wanted := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		wanted[term] = struct{}{}
+	}
+
+	segs := segments(text)
+	firstMatch, words := -1, 0
+	matches := make([]bool, len(segs))
+	for i, segment := range segs {
+		if !segment.word {
+			continue
+		}
+		if term, ok := normalizeWord(segment); ok {
+			if _, ok := wanted[term]; ok {
+				matches[i] = true
+				if firstMatch < 0 {
+					firstMatch = words
+				}
+			}
+		}
+		words++
+	}
+
+	// Окно из snippetMaxWords слов, в котором первое совпадение стоит ближе к началу
+	from := 0
+	if firstMatch > snippetMaxWords/3 {
+		from = firstMatch - snippetMaxWords/3
+	}
+	to := from + snippetMaxWords
+
+	var b strings.Builder
+	word := -1
+	for i, segment := range segs {
+		if segment.word {
+			word++
+		}
+		if word < from || word >= to {
+			continue
+		}
+		if matches[i] {
+			b.WriteString("<b>" + html.EscapeString(segment.text) + "</b>")
+		} else {
+			b.WriteString(html.EscapeString(segment.text))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// uniqueTerms убирает повторы, сохраняя порядок
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]struct{}, len(terms))
+	result := terms[:0]
+	for _, term := range terms {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		result = append(result, term)
+	}
+	return result
+}
+
+// containsType проверяет, входит ли тип в фильтр. Пустой фильтр пропускает все типы
+func containsType(types []model.SearchType, kind model.SearchType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
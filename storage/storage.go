@@ -2,34 +2,108 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/VadimRight/GraphQLOzon/internal/config"
+	"github.com/VadimRight/GraphQLOzon/internal/cursor"
 	"github.com/VadimRight/GraphQLOzon/model"
 )
 
+// ErrUsernameTaken возвращается UserCreate, если имя уже занято. Имена сравниваются без учета регистра
+var ErrUsernameTaken = errors.New("user already exists")
+
+// ErrRestoreConflict возвращается RestoreUser, если пользователь с таким ID уже есть, но под другим именем
+var ErrRestoreConflict = errors.New("object with the same ID differs")
+
+// ErrMissingReference возвращается Restore*, если автор, пост или родительский комментарий не найден
+var ErrMissingReference = errors.New("referenced object not found")
+
 type Storage interface {
+	// WithTx выполняет fn атомарно: изменения, сделанные через tx, сохраняются, только если fn
+	// вернула nil, иначе отменяются все. Внутри fn все обращения к хранилищу должны идти через tx
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
 	// Пользователи
 	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
 	UserCreate(ctx context.Context, username string, password string) (*model.User, error)
 	GetUserByID(ctx context.Context, userID string) (*model.User, error)
 	GetAllUsers(ctx context.Context) ([]*model.User, error)
+	SetUserRole(ctx context.Context, userID string, role model.UserRole) (*model.User, error)
+	SetUserDisabled(ctx context.Context, userID string, disabled bool) (*model.User, error)
+	// SetUserPassword заменяет хэш пароля пользователя
+	SetUserPassword(ctx context.Context, userID string, password string) (*model.User, error)
+	// IsUserDisabled читает блокировку мимо кэша и реплик, чтобы она действовала сразу
+	IsUserDisabled(ctx context.Context, userID string) (bool, error)
 
 	// Посты
 	GetPostsByUserID(ctx context.Context, userID string, limit, offset *int) ([]*model.Post, error)
-	GetAllPosts(ctx context.Context, limit, offset *int) ([]*model.Post, error)
+	GetAllPosts(ctx context.Context, filter *model.PostFilter, limit, offset *int) ([]*model.Post, error)
 	GetPostByID(ctx context.Context, postID string) (*model.Post, error)
-	CreatePost(ctx context.Context, id, text, authorID string, commentable bool) (*model.Post, error)
+	CreatePost(ctx context.Context, id, text, authorID string, commentable bool, format model.TextFormat) (*model.Post, error)
+	UpdatePost(ctx context.Context, id, text string, commentable bool, format model.TextFormat) (*model.Post, error)
+	DeletePost(ctx context.Context, id string) error
 
 	// Комментарии
-	GetAllComments(ctx context.Context, limit, offset *int) ([]*model.CommentResponse, error)
-	GetCommentsByPostID(ctx context.Context, postID string, limit, offset *int) ([]*model.CommentResponse, error) // Обновлено
-	GetCommentsByParentID(ctx context.Context, parentID string, limit, offset *int) ([]*model.CommentResponse, error)
+	GetAllComments(ctx context.Context, filter *model.CommentFilter, limit, offset *int) ([]*model.CommentResponse, error)
+	GetCommentsByPostID(ctx context.Context, postID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error)
+	GetCommentsByParentID(ctx context.Context, parentID string, sort model.CommentSort, limit, offset *int) ([]*model.CommentResponse, error)
 	GetCommentsByUserID(ctx context.Context, userID string) ([]*model.CommentResponse, error)
 	GetCommentByID(ctx context.Context, id string) (*model.CommentResponse, error)
-	CreateComment(ctx context.Context, commentText, itemId, userID string) (*model.CommentResponse, error)
+	CreateComment(ctx context.Context, commentText, itemId, userID string, format model.TextFormat) (*model.CommentResponse, error)
+
+	// Подписки и лента. Списки упорядочены от новых к старым и начинаются после ключа after, если он задан
+	Follow(ctx context.Context, followerID, followeeID string) error
+	Unfollow(ctx context.Context, followerID, followeeID string) error
+	GetFollowers(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error)
+	GetFollowing(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Follow, error)
+	GetFeed(ctx context.Context, userID string, after *cursor.Key, limit int) ([]*model.Post, error)
+
+	// Реакции
+	React(ctx context.Context, itemID, userID string, kind model.ReactionKind) error
+	Unreact(ctx context.Context, itemID, userID string, kind model.ReactionKind) error
+	GetReactionCounts(ctx context.Context, itemID string) ([]*model.ReactionCount, error)
+	GetUserReactions(ctx context.Context, itemID, userID string) ([]model.ReactionKind, error)
+
+	// Теги постов. Теги из текста и явно переданные хранятся вместе, явные отмечаются отдельно
+	SetPostTags(ctx context.Context, postID string, fromText, explicit []string) error
+	GetPostTags(ctx context.Context, postID string) ([]string, error)
+	GetPostExplicitTags(ctx context.Context, postID string) ([]string, error)
+	GetPostsByTag(ctx context.Context, tag string, after *cursor.Key, limit int) ([]*model.Post, error)
+	GetTrendingTags(ctx context.Context, since time.Time, limit int) ([]*model.TrendingTag, error)
+
+	// Упоминания пользователей
+	SaveMentions(ctx context.Context, itemID string, mentions []*model.Mention) error
+	GetMentions(ctx context.Context, itemID string) ([]*model.Mention, error)
+	GetMentionedIn(ctx context.Context, userID string, after *cursor.Key, limit int) ([]model.MentionSource, error)
+
+	// Уведомления
+	CreateNotifications(ctx context.Context, notifications []*model.Notification) error
+	GetNotifications(ctx context.Context, userID string, unreadOnly bool, after *cursor.Key, limit int) ([]*model.Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID string) (int, error)
+	MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error)
+
+	// Полнотекстовый поиск
+	Search(ctx context.Context, query string, types []model.SearchType, limit, offset int) ([]*model.SearchHit, error)
+
+	// Восстановление из выгрузки: объекты сохраняются с исходными ID и временем создания.
+	// Объект с уже существующим ID не меняется, тогда возвращается false. Пост и родительский
+	// комментарий восстанавливаемого комментария уже должны быть сохранены, иначе возвращается
+	// ErrMissingReference. Пользователь с существующим ID, но другим именем - ErrRestoreConflict
+	RestoreUser(ctx context.Context, user *model.User) (bool, error)
+	RestorePost(ctx context.Context, post *model.Post) (bool, error)
+	RestoreComment(ctx context.Context, comment *model.CommentResponse) (bool, error)
+
+	// Выгрузка: fn вызывается для каждого объекта, пользователи и посты идут по возрастанию ID,
+	// комментарии - по времени создания, поэтому родитель идет раньше ответа. Пользователи
+	// передаются с хэшами паролей. Объекты читаются страницами, а не все сразу
+	ScanUsers(ctx context.Context, fn func(*model.User) error) error
+	ScanPosts(ctx context.Context, fn func(*model.Post) error) error
+	ScanComments(ctx context.Context, fn func(*model.CommentResponse) error) error
 }
 
-// Функция возвращающая тип хранилища, запускаемого в приложении - либо Postgres, либо in-memory
+// Функция возвращающая тип хранилища, запускаемого в приложении - либо Postgres, либо in-memory.
+// Если кэш включен, хранилище оборачивается CachedStorage
 func StorageType(cfg *config.Config) Storage {
 	storageType := cfg.Storage.StorageType
 	var storage Storage
@@ -38,5 +112,8 @@ func StorageType(cfg *config.Config) Storage {
 	} else {
 		storage = InitPostgresDatabase(cfg)
 	}
+	if cfg.Cache.Enabled {
+		storage = NewCachedStorage(storage, NewLRUCache(cfg.Cache.Size, cfg.Cache.TTL))
+	}
 	return storage
 }
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryWithTxRollback(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := s.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	post, err := s.CreatePost(ctx, "post-1", "hello world", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	require.NoError(t, s.SetPostTags(ctx, post.ID, []string{"hello"}, nil))
+	require.NoError(t, s.React(ctx, post.ID, bob.ID, model.ReactionKindLike))
+
+	errFailed := errors.New("failed")
+	err = s.WithTx(ctx, func(tx Storage) error {
+		_, err := tx.UpdatePost(ctx, post.ID, "goodbye", false, model.TextFormatMarkdown)
+		require.NoError(t, err)
+		require.NoError(t, tx.SetPostTags(ctx, post.ID, []string{"bye"}, nil))
+		require.NoError(t, tx.Unreact(ctx, post.ID, bob.ID, model.ReactionKindLike))
+		require.NoError(t, tx.Follow(ctx, bob.ID, alice.ID))
+		_, err = tx.UserCreate(ctx, "carol", "hash")
+		require.NoError(t, err)
+		// Вложенный вызов выполняется в той же транзакции
+		return tx.WithTx(ctx, func(tx Storage) error {
+			_, err := tx.CreatePost(ctx, "post-2", "another post", alice.ID, true, model.TextFormatPlain)
+			require.NoError(t, err)
+			return errFailed
+		})
+	})
+	assert.ErrorIs(t, err, errFailed)
+
+	got, err := s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", got.Text)
+	assert.True(t, got.Commentable)
+	assert.Equal(t, model.TextFormatPlain, got.Format)
+	tags, err := s.GetPostTags(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello"}, tags)
+	counts, err := s.GetReactionCounts(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.ReactionCount{{Kind: model.ReactionKindLike, Count: 1}}, counts)
+	following, err := s.GetFollowing(ctx, bob.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+	users, err := s.GetAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	_, err = s.GetPostByID(ctx, "post-2")
+	assert.Error(t, err)
+
+	// Поисковый индекс тоже откатывается
+	hits, err := s.Search(ctx, "hello", nil, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+	hits, err = s.Search(ctx, "goodbye", nil, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// При панике изменения тоже отменяются, а хранилище остается доступным
+	assert.Panics(t, func() {
+		_ = s.WithTx(ctx, func(tx Storage) error {
+			require.NoError(t, tx.DeletePost(ctx, post.ID))
+			panic("boom")
+		})
+	})
+	_, err = s.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+
+	// Без ошибки изменения сохраняются
+	err = s.WithTx(ctx, func(tx Storage) error {
+		return tx.Follow(ctx, bob.ID, alice.ID)
+	})
+	require.NoError(t, err)
+	following, err = s.GetFollowing(ctx, bob.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Len(t, following, 1)
+}
+
+func TestInMemoryDeletePost(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	bob, err := s.UserCreate(ctx, "bob", "hash")
+	require.NoError(t, err)
+	post, err := s.CreatePost(ctx, "post-1", "post about cats", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	other, err := s.CreatePost(ctx, "post-2", "post about dogs", alice.ID, true, model.TextFormatPlain)
+	require.NoError(t, err)
+	require.NoError(t, s.SetPostTags(ctx, post.ID, []string{"cats"}, nil))
+	comment, err := s.CreateComment(ctx, "nice cats", post.ID, bob.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+	reply, err := s.CreateComment(ctx, "thanks", comment.ID, alice.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+	require.NoError(t, s.React(ctx, reply.ID, bob.ID, model.ReactionKindLike))
+	require.NoError(t, s.SaveMentions(ctx, comment.ID, []*model.Mention{{UserID: &alice.ID, Offset: 0, Length: 4}}))
+	require.NoError(t, s.CreateNotifications(ctx, []*model.Notification{
+		{Kind: model.NotificationKindComment, RecipientID: alice.ID, ActorID: bob.ID, PostID: post.ID, CommentID: &comment.ID},
+	}))
+	_, err = s.CreateComment(ctx, "dogs too", other.ID, bob.ID, model.TextFormatPlain)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeletePost(ctx, post.ID))
+
+	_, err = s.GetPostByID(ctx, post.ID)
+	assert.Error(t, err)
+	_, err = s.GetCommentByID(ctx, reply.ID)
+	assert.Error(t, err)
+	comments, err := s.GetAllComments(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, other.ID, comments[0].PostID)
+	posts, err := s.GetPostsByTag(ctx, "cats", nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+	mentioned, err := s.GetMentionedIn(ctx, alice.ID, nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, mentioned)
+	unread, err := s.CountUnreadNotifications(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Zero(t, unread)
+	counts, err := s.GetReactionCounts(ctx, reply.ID)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+	hits, err := s.Search(ctx, "cats", nil, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	assert.Error(t, s.DeletePost(ctx, post.ID))
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VadimRight/GraphQLOzon/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryUserCreateIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+
+	const workers = 50
+	names := []string{"alice", "Alice", "ALICE"}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+		taken   int
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			<-start
+			_, err := s.UserCreate(ctx, name, "hash")
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				created++
+			} else {
+				assert.ErrorIs(t, err, ErrUsernameTaken)
+				taken++
+			}
+		}(names[i%len(names)])
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, 1, created)
+	assert.Equal(t, workers-1, taken)
+
+	users, err := s.GetAllUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+
+	// Поиск по имени тоже не учитывает регистр
+	user, err := s.GetUserByUsername(ctx, strings.ToUpper(users[0].Username))
+	require.NoError(t, err)
+	assert.Equal(t, users[0].ID, user.ID)
+}
+
+func TestUserRoleAndDisabled(t *testing.T) {
+	ctx := context.Background()
+	cache := newMapCache()
+	s := NewCachedStorage(NewInMemoryStorage(), cache)
+	alice, err := s.UserCreate(ctx, "alice", "hash")
+	require.NoError(t, err)
+	assert.Equal(t, model.UserRoleUser, alice.Role)
+	assert.False(t, alice.Disabled)
+
+	// Изменение удаляет пользователя из кэша, поэтому следующее чтение видит новые значения
+	_, err = s.GetUserByID(ctx, alice.ID)
+	require.NoError(t, err)
+	updated, err := s.SetUserRole(ctx, alice.ID, model.UserRoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserRoleAdmin, updated.Role)
+	assert.False(t, cache.has(userKey(alice.ID)))
+	_, err = s.SetUserDisabled(ctx, alice.ID, true)
+	require.NoError(t, err)
+	user, err := s.GetUserByID(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.UserRoleAdmin, user.Role)
+	assert.True(t, user.Disabled)
+
+	_, err = s.SetUserDisabled(ctx, "missing", true)
+	assert.Error(t, err)
+
+	// Новый хэш пароля виден при входе, а старый больше не подходит
+	_, err = s.SetUserPassword(ctx, alice.ID, "new-hash")
+	require.NoError(t, err)
+	user, err = s.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", user.Password)
+	assert.False(t, cache.has(userKey(alice.ID)))
+	_, err = s.SetUserPassword(ctx, "missing", "hash")
+	assert.Error(t, err)
+}
+
+func TestInMemoryRestore(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	created, err := s.RestoreUser(ctx, &model.User{ID: "u1", Username: "alice", Password: "hash", Role: model.UserRoleAdmin})
+	require.NoError(t, err)
+	assert.True(t, created)
+	_, err = s.RestoreUser(ctx, &model.User{ID: "u2", Username: "ALICE", Password: "hash", Role: model.UserRoleUser})
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+
+	_, err = s.RestorePost(ctx, &model.Post{ID: "p1", Text: "hello", AuthorID: "u2", CreatedAt: createdAt})
+	assert.ErrorIs(t, err, ErrMissingReference)
+	created, err = s.RestorePost(ctx, &model.Post{ID: "p1", Text: "hello", AuthorID: "u1", Commentable: true, Format: model.TextFormatPlain, CreatedAt: createdAt})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	parent := "c1"
+	_, err = s.RestoreComment(ctx, &model.CommentResponse{ID: "c2", Comment: "reply", AuthorID: "u1", PostID: "p1", ParentCommentID: &parent, CreatedAt: createdAt})
+	assert.ErrorIs(t, err, ErrMissingReference)
+	_, err = s.RestoreComment(ctx, &model.CommentResponse{ID: "c1", Comment: "first", AuthorID: "u1", PostID: "p1", Format: model.TextFormatPlain, CreatedAt: createdAt})
+	require.NoError(t, err)
+	_, err = s.RestoreComment(ctx, &model.CommentResponse{ID: "c2", Comment: "reply", AuthorID: "u1", PostID: "p1", ParentCommentID: &parent, Format: model.TextFormatPlain, CreatedAt: createdAt.Add(time.Minute)})
+	require.NoError(t, err)
+
+	// Восстановленные объекты сохраняют ID и время создания
+	user, err := s.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, model.UserRoleAdmin, user.Role)
+	post, err := s.GetPostByID(ctx, "p1")
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(post.CreatedAt))
+	comment, err := s.GetCommentByID(ctx, "c2")
+	require.NoError(t, err)
+	assert.Equal(t, &parent, comment.ParentCommentID)
+
+	// Повторное восстановление с тем же ID ничего не меняет, а пользователь с тем же ID
+	// и другим именем - конфликт
+	created, err = s.RestoreUser(ctx, &model.User{ID: "u1", Username: "alice", Password: "other", Role: model.UserRoleUser})
+	require.NoError(t, err)
+	assert.False(t, created)
+	_, err = s.RestoreUser(ctx, &model.User{ID: "u1", Username: "bob", Password: "other", Role: model.UserRoleUser})
+	assert.ErrorIs(t, err, ErrRestoreConflict)
+	created, err = s.RestorePost(ctx, &model.Post{ID: "p1", Text: "changed", AuthorID: "u1", CreatedAt: createdAt})
+	require.NoError(t, err)
+	assert.False(t, created)
+	created, err = s.RestoreComment(ctx, &model.CommentResponse{ID: "c1", Comment: "changed", AuthorID: "u1", PostID: "p1", CreatedAt: createdAt})
+	require.NoError(t, err)
+	assert.False(t, created)
+	post, err = s.GetPostByID(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", post.Text)
+
+	// Комментарии выгружаются по времени создания, пользователи - по ID с хэшами паролей
+	var ids []string
+	require.NoError(t, s.ScanComments(ctx, func(comment *model.CommentResponse) error {
+		ids = append(ids, comment.ID)
+		return nil
+	}))
+	assert.Equal(t, []string{"c1", "c2"}, ids)
+	require.NoError(t, s.ScanUsers(ctx, func(user *model.User) error {
+		assert.Equal(t, "hash", user.Password)
+		return nil
+	}))
+}